@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
@@ -19,6 +21,10 @@ import (
 
 const _tableNameEnv = "POLL_TABLE_NAME"
 
+// _maxChangesPerIncrement keeps each increment within a single DynamoDB transaction, a free-text
+// change can move two terms and every change is recorded alongside them
+const _maxChangesPerIncrement = 8
+
 var db dynamodb.Client
 var ivsClient ivs.Client
 
@@ -35,14 +41,85 @@ func init() {
 type TotalsPerPoll = map[string]map[string]int
 
 type incomingVote struct {
-	PollID string `json:"pollId"`
-	Answer string `json:"answer"`
+	ID      string   `json:"id"`
+	PollID  string   `json:"pollId"`
+	UserID  string   `json:"userId"`
+	Answer  string   `json:"answer"`
+	Answers []string `json:"answers"`
+	Ranking []string `json:"ranking"`
+	Value   *int     `json:"value"`
+	// Weight is how many votes the vote counts as, votes without a weight count once
+	Weight  int    `json:"weight"`
+	VotedAt string `json:"votedAt"`
+	// IPHash, Fingerprint and FraudStatus are used to spot and hold back stuffed votes
+	IPHash      string `json:"ipHash"`
+	Fingerprint string `json:"fingerprint"`
+	FraudStatus string `json:"fraudStatus"`
+}
+
+// counted checks whether the vote counts towards the poll's totals, quarantined votes are held
+// back until they have been reviewed
+func (v incomingVote) counted() bool {
+	return v.FraudStatus != repository.VoteFraudStatusQuarantined
 }
 
-func handle(ctx context.Context, event events.DynamoDBEvent) error {
+// selectedOptions returns every option the vote counts towards. Ranked ballots only count
+// towards their first preference, the full ballots are tallied from the stored votes. Scale votes
+// count towards their value's bucket in the histogram.
+func (v incomingVote) selectedOptions() []string {
+	if v.Value != nil {
+		return []string{repository.ScaleTotalsKey(*v.Value)}
+	}
+
+	if len(v.Ranking) > 0 {
+		return v.Ranking[:1]
+	}
+
+	var selected []string
+	if v.Answer != "" {
+		selected = append(selected, v.Answer)
+	}
+
+	return append(selected, v.Answers...)
+}
+
+func (v incomingVote) weight() int {
+	if v.Weight < 1 {
+		return 1
+	}
+
+	return v.Weight
+}
+
+// voteChange describes how a single stream record changed a user's vote. Previous is nil for new
+// votes and Current is nil for retracted votes.
+type voteChange struct {
+	// SequenceNumber identifies the stream record, it is recorded once the change has been counted
+	SequenceNumber string
+	Previous       *incomingVote
+	Current        *incomingVote
+}
+
+// dynamoDBEventResponse reports the stream records that failed so only they and the records after
+// them are retried. The version of aws-lambda-go in use doesn't have this type yet.
+type dynamoDBEventResponse struct {
+	BatchItemFailures []dynamoDBBatchItemFailure `json:"batchItemFailures"`
+}
+
+type dynamoDBBatchItemFailure struct {
+	ItemIdentifier string `json:"itemIdentifier"`
+}
+
+type voteCounter interface {
+	scorer
+	CountedVoteChanges(ctx context.Context, pollID string, changeIDs []string) (map[string]bool, error)
+	IncrementPollTotals(ctx context.Context, pollID string, changeIDs []string, answerIncrements map[string]int, weightedIncrements map[string]int) error
+}
+
+func handle(ctx context.Context, event events.DynamoDBEvent) (dynamoDBEventResponse, error) {
 	tableName, ok := os.LookupEnv(_tableNameEnv)
 	if !ok {
-		return fmt.Errorf("error environment variable %s not set", _tableNameEnv)
+		return dynamoDBEventResponse{}, fmt.Errorf("error environment variable %s not set", _tableNameEnv)
 	}
 
 	repo := repository.New(tableName, &db)
@@ -50,48 +127,239 @@ func handle(ctx context.Context, event events.DynamoDBEvent) error {
 
 	svc := service.New(repo, broadcaster)
 
-	votesPerPoll := splitVotesByPollID(event.Records)
+	changesPerPoll, unparsed := splitVoteChangesByPollID(event.Records)
+
+	res := countVoteChanges(ctx, svc, changesPerPoll)
+	res.BatchItemFailures = append(unparsed, res.BatchItemFailures...)
 
-	for pollID, votes := range votesPerPoll {
-		answerTotals := aggregatePollVoteTotals(votes)
+	return res, nil
+}
 
-		if err := svc.IncrementPollTotals(ctx, pollID, answerTotals); err != nil {
+// countVoteChanges counts the changes to each poll's votes. Changes that can't be counted are
+// reported as failures so they are retried, every change is only ever counted once so changes that
+// were counted before a failure are safe to retry along with it.
+func countVoteChanges(ctx context.Context, svc voteCounter, changesPerPoll map[string][]voteChange) dynamoDBEventResponse {
+	res := dynamoDBEventResponse{BatchItemFailures: []dynamoDBBatchItemFailure{}}
+
+	for pollID, changes := range changesPerPoll {
+		if err := countPollVoteChanges(ctx, svc, pollID, changes); err != nil {
 			log.Printf("error incrementing poll totals: %s", err)
+
+			for _, c := range changes {
+				res.BatchItemFailures = append(res.BatchItemFailures, dynamoDBBatchItemFailure{ItemIdentifier: c.SequenceNumber})
+			}
+		}
+	}
+
+	return res
+}
+
+func countPollVoteChanges(ctx context.Context, svc voteCounter, pollID string, changes []voteChange) error {
+	counted, err := svc.CountedVoteChanges(ctx, pollID, sequenceNumbers(changes))
+	if err != nil {
+		return err
+	}
+
+	// changes that were counted before the batch was retried are skipped, including scoring them
+	var uncounted []voteChange
+	for _, c := range changes {
+		if !counted[c.SequenceNumber] {
+			uncounted = append(uncounted, c)
+		}
+	}
+
+	scoreNewVotes(ctx, svc, uncounted)
+
+	for start := 0; start < len(uncounted); start += _maxChangesPerIncrement {
+		end := start + _maxChangesPerIncrement
+		if end > len(uncounted) {
+			end = len(uncounted)
+		}
+
+		chunk := uncounted[start:end]
+
+		err := svc.IncrementPollTotals(ctx, pollID, sequenceNumbers(chunk), aggregatePollVoteCounts(chunk), aggregatePollVoteTotals(chunk))
+		if err != nil {
+			if errors.Is(err, repository.ErrPollNotFound) {
+				// the votes are being removed along with their poll
+				return nil
+			}
+
+			return err
 		}
 	}
 
 	return nil
 }
 
-// splitVotesByPollID loops over the incoming events and splits them by their poll ID
-func splitVotesByPollID(records []events.DynamoDBEventRecord) map[string][]incomingVote {
-	eventsPerPoll := make(map[string][]incomingVote)
+func sequenceNumbers(changes []voteChange) []string {
+	ids := make([]string, 0, len(changes))
+	for _, c := range changes {
+		ids = append(ids, c.SequenceNumber)
+	}
+
+	return ids
+}
+
+// splitVoteChangesByPollID loops over the incoming events and splits them by their poll ID. Records
+// that can't be read as a vote are returned as failures so they are retried rather than lost.
+func splitVoteChangesByPollID(records []events.DynamoDBEventRecord) (map[string][]voteChange, []dynamoDBBatchItemFailure) {
+	changesPerPoll := make(map[string][]voteChange)
+	unparsed := []dynamoDBBatchItemFailure{}
 
 	for _, record := range records {
+		if isExpiry(record) {
+			// votes expire along with their poll so there are no totals left to update
+			continue
+		}
+
+		change, err := parseVoteChange(record)
+		if err != nil {
+			log.Printf("error unmarshalling stream event %s into a vote: %s", record.EventID, err)
+			unparsed = append(unparsed, dynamoDBBatchItemFailure{ItemIdentifier: record.Change.SequenceNumber})
+			continue
+		}
+
+		if change.isQuarantine() {
+			// the vote was held back when it was made so it was never counted
+			continue
+		}
+
+		pollID := change.pollID()
+		changesPerPoll[pollID] = append(changesPerPoll[pollID], change)
+	}
+
+	return changesPerPoll, unparsed
+}
+
+// isExpiry checks whether a record is DynamoDB removing an item because its TTL has passed
+func isExpiry(record events.DynamoDBEventRecord) bool {
+	return record.EventName == string(events.DynamoDBOperationTypeRemove) &&
+		record.UserIdentity != nil &&
+		record.UserIdentity.Type == "Service" &&
+		record.UserIdentity.PrincipalID == "dynamodb.amazonaws.com"
+}
+
+func parseVoteChange(record events.DynamoDBEventRecord) (voteChange, error) {
+	change := voteChange{SequenceNumber: record.Change.SequenceNumber}
+
+	if record.EventName == string(events.DynamoDBOperationTypeModify) || record.EventName == string(events.DynamoDBOperationTypeRemove) {
+		var v incomingVote
+		if err := utils.UnmarshalStreamImage(record.Change.OldImage, &v); err != nil {
+			return voteChange{}, err
+		}
+		change.Previous = &v
+	}
+
+	if record.EventName == string(events.DynamoDBOperationTypeInsert) || record.EventName == string(events.DynamoDBOperationTypeModify) {
 		var v incomingVote
 		if err := utils.UnmarshalStreamImage(record.Change.NewImage, &v); err != nil {
-			log.Printf("error unmarshalling stream event into a vote: %s", err)
+			return voteChange{}, err
+		}
+		change.Current = &v
+	}
+
+	if change.Previous == nil && change.Current == nil {
+		return voteChange{}, fmt.Errorf("unsupported stream event %s", record.EventName)
+	}
+
+	return change, nil
+}
+
+// isNewVote checks whether the change brought in a vote that hasn't been seen before, as opposed
+// to an existing vote being updated
+func (c voteChange) isNewVote() bool {
+	return c.Current != nil && (c.Previous == nil || c.Previous.ID != c.Current.ID)
+}
+
+// isQuarantine checks whether the change is a new vote being quarantined after it was scored
+func (c voteChange) isQuarantine() bool {
+	return c.Previous != nil && c.Current != nil &&
+		c.Previous.ID == c.Current.ID &&
+		c.Previous.FraudStatus == "" &&
+		c.Current.FraudStatus == repository.VoteFraudStatusQuarantined
+}
+
+// scoreNewVotes checks each new vote for vote stuffing, votes that are quarantined are marked so
+// they aren't counted. Votes that can't be scored are counted rather than lost.
+func scoreNewVotes(ctx context.Context, svc scorer, changes []voteChange) {
+	for _, c := range changes {
+		if !c.isNewVote() {
+			continue
+		}
+
+		var votedAt *time.Time
+		if t, err := repository.ParseTimestamp(c.Current.VotedAt); err == nil {
+			votedAt = &t
+		}
+
+		quarantined, err := svc.ScoreVote(ctx, service.PollVote{
+			ID:          c.Current.ID,
+			PollID:      c.Current.PollID,
+			UserID:      c.Current.UserID,
+			VotedAt:     votedAt,
+			IPHash:      c.Current.IPHash,
+			Fingerprint: c.Current.Fingerprint,
+		})
+		if err != nil {
+			if !errors.Is(err, service.ErrRecordNotFound) {
+				log.Printf("error scoring vote %s: %s", c.Current.ID, err)
+			}
+
 			continue
 		}
 
-		if _, ok := eventsPerPoll[v.PollID]; !ok {
-			eventsPerPoll[v.PollID] = make([]incomingVote, 0)
+		if quarantined {
+			c.Current.FraudStatus = repository.VoteFraudStatusQuarantined
 		}
-		eventsPerPoll[v.PollID] = append(eventsPerPoll[v.PollID], v)
 	}
+}
+
+type scorer interface {
+	ScoreVote(ctx context.Context, vote service.PollVote) (bool, error)
+}
+
+func (c voteChange) pollID() string {
+	if c.Current != nil {
+		return c.Current.PollID
+	}
+
+	return c.Previous.PollID
+}
 
-	return eventsPerPoll
+// aggregatePollVoteTotals works out how much each answer's weighted total needs to move by, changed
+// votes take their weight away from the old answers and add it to the new answers
+func aggregatePollVoteTotals(changes []voteChange) map[string]int {
+	return aggregateVoteChanges(changes, incomingVote.weight)
 }
 
-func aggregatePollVoteTotals(votes []incomingVote) map[string]int {
+// aggregatePollVoteCounts works out how much each answer's raw vote count needs to move by,
+// ignoring the weight of each vote
+func aggregatePollVoteCounts(changes []voteChange) map[string]int {
+	return aggregateVoteChanges(changes, func(incomingVote) int { return 1 })
+}
+
+func aggregateVoteChanges(changes []voteChange, amount func(incomingVote) int) map[string]int {
 	aggregateTotals := make(map[string]int)
 
-	for _, v := range votes {
-		if _, ok := aggregateTotals[v.Answer]; !ok {
-			aggregateTotals[v.Answer] = 0
+	for _, c := range changes {
+		if c.Previous != nil && c.Previous.counted() {
+			for _, answer := range c.Previous.selectedOptions() {
+				aggregateTotals[answer] = aggregateTotals[answer] - amount(*c.Previous)
+			}
+		}
+
+		if c.Current != nil && c.Current.counted() {
+			for _, answer := range c.Current.selectedOptions() {
+				aggregateTotals[answer] = aggregateTotals[answer] + amount(*c.Current)
+			}
 		}
+	}
 
-		aggregateTotals[v.Answer] = aggregateTotals[v.Answer] + 1
+	for answer, total := range aggregateTotals {
+		if total == 0 {
+			delete(aggregateTotals, answer)
+		}
 	}
 
 	return aggregateTotals
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestSplitVoteChangesByPollID(t *testing.T) {
+	raw, err := os.ReadFile("events/valid.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var event events.DynamoDBEvent
+	if err := json.Unmarshal(raw, &event); err != nil {
+		t.Fatal(err)
+	}
+
+	changesPerPoll, unparsed := splitVoteChangesByPollID(event.Records)
+	if len(unparsed) != 0 {
+		t.Fatalf("expected every record to be parsed, got failures %v", unparsed)
+	}
+
+	changes := changesPerPoll["b3b3c767-a5e9-45f5-bc82-ffdab15a6855"]
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d", len(changes))
+	}
+
+	if changes[0].Previous != nil || changes[0].Current == nil {
+		t.Errorf("expected an insert to only have a current vote")
+	}
+
+	if changes[1].Previous == nil || changes[1].Current == nil {
+		t.Errorf("expected a modify to have both a previous and current vote")
+	}
+
+	if changes[2].Previous == nil || changes[2].Current != nil {
+		t.Errorf("expected a remove to only have a previous vote")
+	}
+}
+
+func TestAggregatePollVoteTotals(t *testing.T) {
+	changes := []voteChange{
+		{Current: &incomingVote{Answer: "a"}},
+		{Current: &incomingVote{Answer: "a"}},
+		{Previous: &incomingVote{Answer: "a"}, Current: &incomingVote{Answer: "b"}},
+		{Previous: &incomingVote{Answer: "c"}},
+		{Previous: &incomingVote{Answer: "b"}, Current: &incomingVote{Answer: "b"}},
+		{Current: &incomingVote{Answers: []string{"b", "d"}}},
+		{Previous: &incomingVote{Answers: []string{"a", "d"}}, Current: &incomingVote{Answers: []string{"d", "e"}}},
+		{Current: &incomingVote{Ranking: []string{"e", "a", "b"}}},
+	}
+
+	expected := map[string]int{"b": 2, "c": -1, "d": 1, "e": 2}
+
+	if totals := aggregatePollVoteTotals(changes); !reflect.DeepEqual(totals, expected) {
+		t.Fatalf("expected %v, got %v", expected, totals)
+	}
+}
+
+func TestAggregateMultiSelectPollVoteTotals(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   voteChange
+		expected map[string]int
+	}{
+		{
+			name:     "new vote counts every selected option",
+			change:   voteChange{Current: &incomingVote{Answers: []string{"a", "b", "c"}}},
+			expected: map[string]int{"a": 1, "b": 1, "c": 1},
+		},
+		{
+			name:     "changed vote moves every selected option",
+			change:   voteChange{Previous: &incomingVote{Answers: []string{"a", "b"}}, Current: &incomingVote{Answers: []string{"c", "d"}}},
+			expected: map[string]int{"a": -1, "b": -1, "c": 1, "d": 1},
+		},
+		{
+			name:     "changed vote keeping some options",
+			change:   voteChange{Previous: &incomingVote{Answers: []string{"a", "b"}}, Current: &incomingVote{Answers: []string{"b", "c"}}},
+			expected: map[string]int{"a": -1, "c": 1},
+		},
+		{
+			name:     "retracted vote uncounts every selected option",
+			change:   voteChange{Previous: &incomingVote{Answers: []string{"a", "b", "c"}}},
+			expected: map[string]int{"a": -1, "b": -1, "c": -1},
+		},
+		{
+			name:     "weighted vote counts its weight for every selected option",
+			change:   voteChange{Current: &incomingVote{Answers: []string{"a", "b"}, Weight: 3}},
+			expected: map[string]int{"a": 3, "b": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if totals := aggregatePollVoteTotals([]voteChange{tt.change}); !reflect.DeepEqual(totals, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, totals)
+			}
+		})
+	}
+}
+
+func TestAggregateScalePollVoteTotals(t *testing.T) {
+	one, four, five := 1, 4, 5
+
+	changes := []voteChange{
+		{Current: &incomingVote{Value: &four}},
+		{Current: &incomingVote{Value: &five}},
+		{Previous: &incomingVote{Value: &five}, Current: &incomingVote{Value: &one}},
+	}
+
+	expected := map[string]int{"1": 1, "4": 1}
+
+	if totals := aggregatePollVoteTotals(changes); !reflect.DeepEqual(totals, expected) {
+		t.Fatalf("expected %v, got %v", expected, totals)
+	}
+}
+
+func TestAggregateWeightedPollVoteTotals(t *testing.T) {
+	changes := []voteChange{
+		{Current: &incomingVote{Answer: "a", Weight: 3}},
+		{Current: &incomingVote{Answer: "a"}},
+		{Previous: &incomingVote{Answer: "a", Weight: 3}, Current: &incomingVote{Answer: "b", Weight: 3}},
+		{Previous: &incomingVote{Answer: "b", Weight: 5}, Current: &incomingVote{Answer: "b", Weight: 2}},
+	}
+
+	// b's weight changing from 5 to 2 cancels out the 3 moved over from a
+	expectedTotals := map[string]int{"a": 1}
+
+	if totals := aggregatePollVoteTotals(changes); !reflect.DeepEqual(totals, expectedTotals) {
+		t.Fatalf("expected weighted totals %v, got %v", expectedTotals, totals)
+	}
+
+	expectedCounts := map[string]int{"a": 1, "b": 1}
+
+	if counts := aggregatePollVoteCounts(changes); !reflect.DeepEqual(counts, expectedCounts) {
+		t.Fatalf("expected raw counts %v, got %v", expectedCounts, counts)
+	}
+}
+
+func TestSplitVoteChangesReportsUnparsedRecords(t *testing.T) {
+	records := []events.DynamoDBEventRecord{
+		{
+			EventName: string(events.DynamoDBOperationTypeInsert),
+			Change: events.DynamoDBStreamRecord{
+				SequenceNumber: "1",
+				NewImage:       map[string]events.DynamoDBAttributeValue{"pollId": events.NewStringAttribute("poll"), "answer": events.NewStringAttribute("a")},
+			},
+		},
+		{
+			EventName: string(events.DynamoDBOperationTypeInsert),
+			Change: events.DynamoDBStreamRecord{
+				SequenceNumber: "2",
+				NewImage:       map[string]events.DynamoDBAttributeValue{"pollId": events.NewStringAttribute("poll"), "value": events.NewStringAttribute("high")},
+			},
+		},
+	}
+
+	changesPerPoll, unparsed := splitVoteChangesByPollID(records)
+
+	expected := []dynamoDBBatchItemFailure{{ItemIdentifier: "2"}}
+	if !reflect.DeepEqual(unparsed, expected) {
+		t.Fatalf("expected failures %v, got %v", expected, unparsed)
+	}
+
+	if len(changesPerPoll["poll"]) != 1 {
+		t.Errorf("expected the readable record to still be counted, got %d changes", len(changesPerPoll["poll"]))
+	}
+}
+
+func TestSplitVoteChangesIgnoresExpiredVotes(t *testing.T) {
+	oldImage := map[string]events.DynamoDBAttributeValue{
+		"pollId": events.NewStringAttribute("poll"),
+		"answer": events.NewStringAttribute("a"),
+	}
+
+	records := []events.DynamoDBEventRecord{
+		{
+			EventName: string(events.DynamoDBOperationTypeRemove),
+			Change:    events.DynamoDBStreamRecord{OldImage: oldImage},
+		},
+		{
+			EventName:    string(events.DynamoDBOperationTypeRemove),
+			Change:       events.DynamoDBStreamRecord{OldImage: oldImage},
+			UserIdentity: &events.DynamoDBUserIdentity{Type: "Service", PrincipalID: "dynamodb.amazonaws.com"},
+		},
+	}
+
+	changesPerPoll, _ := splitVoteChangesByPollID(records)
+	if changes := changesPerPoll["poll"]; len(changes) != 1 {
+		t.Fatalf("expected only the retraction to be counted, got %d changes", len(changes))
+	}
+}
+
+func TestSplitVoteChangesIgnoresQuarantines(t *testing.T) {
+	image := func(fraudStatus string) map[string]events.DynamoDBAttributeValue {
+		item := map[string]events.DynamoDBAttributeValue{
+			"id":     events.NewStringAttribute("vote"),
+			"pollId": events.NewStringAttribute("poll"),
+			"answer": events.NewStringAttribute("a"),
+		}
+
+		if fraudStatus != "" {
+			item["fraudStatus"] = events.NewStringAttribute(fraudStatus)
+		}
+
+		return item
+	}
+
+	records := []events.DynamoDBEventRecord{
+		{
+			EventName: string(events.DynamoDBOperationTypeModify),
+			Change:    events.DynamoDBStreamRecord{OldImage: image(""), NewImage: image("quarantined")},
+		},
+		{
+			EventName: string(events.DynamoDBOperationTypeModify),
+			Change:    events.DynamoDBStreamRecord{OldImage: image("quarantined"), NewImage: image("released")},
+		},
+	}
+
+	changesPerPoll, _ := splitVoteChangesByPollID(records)
+
+	changes := changesPerPoll["poll"]
+	if len(changes) != 1 {
+		t.Fatalf("expected only the release to be kept, got %d changes", len(changes))
+	}
+
+	expected := map[string]int{"a": 1}
+
+	if totals := aggregatePollVoteTotals(changes); !reflect.DeepEqual(totals, expected) {
+		t.Fatalf("expected %v, got %v", expected, totals)
+	}
+}
+
+type fakeScorer struct {
+	quarantine map[string]bool
+}
+
+func (f fakeScorer) ScoreVote(ctx context.Context, vote service.PollVote) (bool, error) {
+	return f.quarantine[vote.ID], nil
+}
+
+func TestScoreNewVotesHoldsBackQuarantinedVotes(t *testing.T) {
+	changes := []voteChange{
+		{Current: &incomingVote{ID: "1", Answer: "a"}},
+		{Current: &incomingVote{ID: "2", Answer: "a"}},
+		// changing a vote replaces it with a new one, which is scored like any other new vote
+		{Previous: &incomingVote{ID: "3", Answer: "c"}, Current: &incomingVote{ID: "4", Answer: "b"}},
+		{Previous: &incomingVote{ID: "5", Answer: "c"}, Current: &incomingVote{ID: "6", Answer: "d"}},
+		// releasing a quarantined vote keeps its ID, so it isn't scored again
+		{
+			Previous: &incomingVote{ID: "7", Answer: "e", FraudStatus: repository.VoteFraudStatusQuarantined},
+			Current:  &incomingVote{ID: "7", Answer: "e", FraudStatus: repository.VoteFraudStatusReleased},
+		},
+	}
+
+	scoreNewVotes(context.Background(), fakeScorer{quarantine: map[string]bool{"2": true, "4": true, "7": true}}, changes)
+
+	expected := map[string]int{"a": 1, "c": -2, "d": 1, "e": 1}
+
+	if totals := aggregatePollVoteTotals(changes); !reflect.DeepEqual(totals, expected) {
+		t.Fatalf("expected %v, got %v", expected, totals)
+	}
+}
+
+// fakeVoteCounter remembers which changes have been counted, polls in failing can't be incremented
+type fakeVoteCounter struct {
+	fakeScorer
+	counted map[string]bool
+	totals  map[string]map[string]int
+	failing map[string]bool
+}
+
+func (f *fakeVoteCounter) CountedVoteChanges(ctx context.Context, pollID string, changeIDs []string) (map[string]bool, error) {
+	return f.counted, nil
+}
+
+func (f *fakeVoteCounter) IncrementPollTotals(ctx context.Context, pollID string, changeIDs []string, answerIncrements map[string]int, weightedIncrements map[string]int) error {
+	if f.failing[pollID] {
+		return errors.New("throttled")
+	}
+
+	for _, id := range changeIDs {
+		f.counted[id] = true
+	}
+
+	if f.totals[pollID] == nil {
+		f.totals[pollID] = make(map[string]int)
+	}
+
+	for answer, incr := range answerIncrements {
+		f.totals[pollID][answer] += incr
+	}
+
+	return nil
+}
+
+func TestCountVoteChanges(t *testing.T) {
+	svc := &fakeVoteCounter{
+		counted: map[string]bool{"1": true},
+		totals:  make(map[string]map[string]int),
+		failing: map[string]bool{"broken": true},
+	}
+
+	changesPerPoll := map[string][]voteChange{
+		"poll": {
+			{SequenceNumber: "1", Current: &incomingVote{ID: "1", Answer: "a"}},
+			{SequenceNumber: "2", Current: &incomingVote{ID: "2", Answer: "a"}},
+		},
+		"broken": {
+			{SequenceNumber: "3", Current: &incomingVote{ID: "3", Answer: "a"}},
+		},
+	}
+
+	res := countVoteChanges(context.Background(), svc, changesPerPoll)
+
+	expectedFailures := []dynamoDBBatchItemFailure{{ItemIdentifier: "3"}}
+	if !reflect.DeepEqual(res.BatchItemFailures, expectedFailures) {
+		t.Fatalf("expected failures %v, got %v", expectedFailures, res.BatchItemFailures)
+	}
+
+	expectedTotals := map[string]map[string]int{"poll": {"a": 1}}
+	if !reflect.DeepEqual(svc.totals, expectedTotals) {
+		t.Fatalf("expected the already counted change to be skipped, got %v", svc.totals)
+	}
+
+	// once the failing poll recovers the retried batch only counts what's left
+	svc.failing = nil
+
+	if res := countVoteChanges(context.Background(), svc, changesPerPoll); len(res.BatchItemFailures) != 0 {
+		t.Fatalf("expected no failures, got %v", res.BatchItemFailures)
+	}
+
+	expectedTotals = map[string]map[string]int{"poll": {"a": 1}, "broken": {"a": 1}}
+	if !reflect.DeepEqual(svc.totals, expectedTotals) {
+		t.Fatalf("expected %v, got %v", expectedTotals, svc.totals)
+	}
+}
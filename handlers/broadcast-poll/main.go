@@ -6,6 +6,7 @@ import (
 	"log"
 
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/utils"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -27,6 +28,7 @@ func init() {
 type poll struct {
 	ID         string `json:"id"`
 	ChannelARN string `json:"channelARN"`
+	Status     string `json:"status"`
 }
 
 func handle(ctx context.Context, event events.DynamoDBEvent) error {
@@ -41,6 +43,11 @@ func handle(ctx context.Context, event events.DynamoDBEvent) error {
 
 		log.Printf("received a new poll %s for channel %s", p.ID, p.ChannelARN)
 
+		if p.Status == string(service.PollStatusDraft) {
+			// draft polls are broadcast when they are opened
+			continue
+		}
+
 		metadata := broadcast.CreateMetadata(p)
 
 		jsonMetadata, err := json.Marshal(metadata)
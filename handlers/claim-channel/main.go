@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+// claimChannelEvent is sent by an operator invoking the function directly, it isn't exposed
+// through the API because nothing in a request proves the caller streams on the channel, so only
+// people with access to the AWS account can hand channels to creators
+type claimChannelEvent struct {
+	ChannelARN string `json:"channelArn"`
+	CreatorID  string `json:"creatorId"`
+}
+
+type claimChannelResult struct {
+	ChannelARN string `json:"channelArn"`
+	OwnerID    string `json:"ownerId"`
+}
+
+func handle(ctx context.Context, event claimChannelEvent) (claimChannelResult, error) {
+	if event.ChannelARN == "" {
+		return claimChannelResult{}, errors.New("error channelArn is required")
+	}
+
+	if event.CreatorID == "" {
+		return claimChannelResult{}, errors.New("error creatorId is required")
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return claimChannelResult{}, fmt.Errorf("error environment variable %s not set", _tableNameEnv)
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	owner, err := svc.ClaimChannel(ctx, event.ChannelARN, event.CreatorID)
+	if err != nil {
+		if err == service.ErrChannelAlreadyOwned {
+			return claimChannelResult{}, fmt.Errorf("error channel %s is owned by another creator", event.ChannelARN)
+		}
+
+		return claimChannelResult{}, fmt.Errorf("error claiming channel: %w", err)
+	}
+
+	log.Printf("gave channel %s to creator %s", owner.ChannelARN, owner.OwnerID)
+
+	return claimChannelResult{
+		ChannelARN: owner.ChannelARN,
+		OwnerID:    owner.OwnerID,
+	}, nil
+}
+
+func main() {
+	lambda.Start(handle)
+}
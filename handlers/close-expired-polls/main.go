@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+func handle(ctx context.Context, event events.CloudWatchEvent) error {
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return fmt.Errorf("error environment variable %s not set", _tableNameEnv)
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	closed, err := svc.CloseExpiredPolls(ctx)
+	for _, p := range closed {
+		log.Printf("closed expired poll %s for channel %s", p.ID, p.ChannelARN)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error closing expired polls: %w", err)
+	}
+
+	return nil
+}
+
+func main() {
+	lambda.Start(handle)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+// createAPIKeyEvent is sent by an operator invoking the function directly, it isn't exposed
+// through the API so only people with access to the AWS account can issue keys
+type createAPIKeyEvent struct {
+	CreatorID string `json:"creatorId"`
+}
+
+type createAPIKeyResult struct {
+	CreatorID string `json:"creatorId"`
+	APIKey    string `json:"apiKey"`
+}
+
+func handle(ctx context.Context, event createAPIKeyEvent) (createAPIKeyResult, error) {
+	if event.CreatorID == "" {
+		return createAPIKeyResult{}, errors.New("error creatorId is required")
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return createAPIKeyResult{}, fmt.Errorf("error environment variable %s not set", _tableNameEnv)
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	apiKey, err := svc.CreateAPIKey(ctx, event.CreatorID)
+	if err != nil {
+		return createAPIKeyResult{}, fmt.Errorf("error creating api key: %w", err)
+	}
+
+	log.Printf("created api key for creator %s", event.CreatorID)
+
+	return createAPIKeyResult{
+		CreatorID: event.CreatorID,
+		APIKey:    apiKey,
+	}, nil
+}
+
+func main() {
+	lambda.Start(handle)
+}
@@ -7,9 +7,13 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/ratelimit"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/validator"
@@ -22,6 +26,9 @@ import (
 
 const _tableNameEnv = "POLL_TABLE_NAME"
 
+// _retentionDaysEnv optionally sets how many days polls and their votes are kept for
+const _retentionDaysEnv = "POLL_RETENTION_DAYS"
+
 var db dynamodb.Client
 var ivsClient ivs.Client
 
@@ -36,9 +43,38 @@ func init() {
 }
 
 type createPollRequest struct {
-	Question   string   `json:"question" validate:"required,min=1,max=100"`
-	Options    []string `json:"options" validate:"required,dive,required,min=1,max=100"`
-	ChannelARN string   `json:"channelARN" validate:"required"`
+	Type     string `json:"type" validate:"omitempty,oneof=single-choice multi-select ranked-choice quiz scale free-text prediction"`
+	Question string `json:"question" validate:"required,min=1,max=100"`
+	// Options are required for every type of poll except scale and free-text polls
+	Options []string `json:"options" validate:"omitempty,dive,required,min=1,max=100"`
+	// CorrectOptions are the indexes of the options that answer a quiz correctly
+	CorrectOptions []int `json:"correctOptions" validate:"omitempty,dive,min=0"`
+	// MinSelections and MaxSelections limit how many options a multi-select vote can choose
+	MinSelections int `json:"minSelections" validate:"omitempty,min=1"`
+	MaxSelections int `json:"maxSelections" validate:"omitempty,min=1"`
+	// ScaleMin and ScaleMax are the inclusive range of values a scale poll accepts, e.g. 1 to 5 stars
+	ScaleMin int `json:"scaleMin"`
+	ScaleMax int `json:"scaleMax"`
+	// PredictionPoints are awarded to everyone who predicts the winning option, defaulting to 100
+	PredictionPoints int `json:"predictionPoints" validate:"omitempty,min=1,max=100000"`
+	// WeightRules maps a viewer tier to how many votes each of their votes counts as, e.g. {"subscriber": 2}
+	WeightRules map[string]int `json:"weightRules" validate:"omitempty,max=20,dive,keys,required,max=50,endkeys,min=1,max=100"`
+	ChannelARN  string         `json:"channelARN" validate:"required"`
+	// SessionID adds a quiz to a session so it counts towards the session leaderboard
+	SessionID  string `json:"sessionId" validate:"omitempty,uuid"`
+	Draft      bool   `json:"draft"`
+	VotePolicy string `json:"votePolicy" validate:"omitempty,oneof=single changeable unlimited"`
+	// DurationSeconds gives viewers a fixed amount of time to vote from when the poll opens
+	DurationSeconds int `json:"durationSeconds" validate:"omitempty,min=1,max=86400"`
+	// ClosesAt is an absolute deadline for voting
+	ClosesAt *time.Time `json:"closesAt" validate:"excluded_with=DurationSeconds"`
+	// VoteRateLimit replaces the default limit on how quickly each viewer can vote on the poll
+	VoteRateLimit *voteRateLimit `json:"voteRateLimit" validate:"omitempty"`
+}
+
+type voteRateLimit struct {
+	Requests   int `json:"requests" validate:"required,min=1,max=1000"`
+	PerSeconds int `json:"perSeconds" validate:"required,min=1,max=3600"`
 }
 
 type createPollResponse struct {
@@ -56,45 +92,174 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		return api.ServerError(fmt.Errorf("error creating validator: %s", err))
 	}
 
+	var opts []service.Option
+	if retentionDays, ok := os.LookupEnv(_retentionDaysEnv); ok && retentionDays != "" {
+		days, err := strconv.Atoi(retentionDays)
+		if err != nil || days < 0 {
+			return api.ServerError(fmt.Errorf("error environment variable %s is not a number of days: %s", _retentionDaysEnv, retentionDays))
+		}
+
+		opts = append(opts, service.WithRetention(time.Duration(days)*24*time.Hour))
+	}
+
 	repo := repository.New(tableName, &db)
 	broadcaster := broadcast.New(&ivsClient)
 
-	svc := service.New(repo, broadcaster)
+	svc := service.New(repo, broadcaster, opts...)
+
+	apiKey, err := auth.APIKey(request)
+	if err != nil {
+		return api.ClientError(http.StatusUnauthorized, "an api key is required")
+	}
+
+	creator, err := svc.AuthenticateCreator(ctx, apiKey)
+	if err != nil {
+		if err == service.ErrInvalidAPIKey {
+			return api.ClientError(http.StatusUnauthorized, "api key is not valid")
+		}
 
-	var createPollReq createPollRequest
-	if err := json.Unmarshal([]byte(request.Body), &createPollReq); err != nil {
-		return api.ServerError(fmt.Errorf("error unmarshalling request body: %s", err))
+		return api.ServerError(fmt.Errorf("error authenticating creator: %s", err))
 	}
 
-	if err := validate.Struct(createPollReq); err != nil {
-		errMap := validator.ExtractErrorMap(trans, err)
+	// retries sent with the same Idempotency-Key get the poll created by the first request
+	return api.Idempotent(ctx, idempotencyStore{svc}, "POLLS#"+creator.ID, request, func() (events.APIGatewayProxyResponse, error) {
+		var createPollReq createPollRequest
+		if err := json.Unmarshal([]byte(request.Body), &createPollReq); err != nil {
+			return api.ServerError(fmt.Errorf("error unmarshalling request body: %s", err))
+		}
+
+		if err := validate.Struct(createPollReq); err != nil {
+			errMap := validator.ExtractErrorMap(trans, err)
+
+			jsonErrMap, err := json.Marshal(errMap)
+			if err != nil {
+				return api.ServerError(fmt.Errorf("error: %w", err))
+			}
+
+			return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
+		}
+
+		if err := svc.AuthorizeChannel(ctx, createPollReq.ChannelARN, creator.ID); err != nil {
+			if err == service.ErrNotChannelOwner {
+				return api.ClientError(http.StatusForbidden, "only the channel's owner can create polls for it")
+			}
+
+			return api.ServerError(fmt.Errorf("error authorizing creator: %s", err))
+		}
+
+		var rateLimit *ratelimit.Limit
+		if createPollReq.VoteRateLimit != nil {
+			rateLimit = &ratelimit.Limit{
+				Requests: createPollReq.VoteRateLimit.Requests,
+				Per:      time.Duration(createPollReq.VoteRateLimit.PerSeconds) * time.Second,
+			}
+		}
 
-		jsonErrMap, err := json.Marshal(errMap)
+		poll, err := svc.CreatePoll(ctx, service.NewPoll{
+			Type:             service.PollType(createPollReq.Type),
+			Question:         createPollReq.Question,
+			Options:          createPollReq.Options,
+			CorrectOptions:   createPollReq.CorrectOptions,
+			MinSelections:    createPollReq.MinSelections,
+			MaxSelections:    createPollReq.MaxSelections,
+			ScaleMin:         createPollReq.ScaleMin,
+			ScaleMax:         createPollReq.ScaleMax,
+			PredictionPoints: createPollReq.PredictionPoints,
+			WeightRules:      createPollReq.WeightRules,
+			ChannelARN:       createPollReq.ChannelARN,
+			SessionID:        createPollReq.SessionID,
+			Draft:            createPollReq.Draft,
+			Duration:         time.Duration(createPollReq.DurationSeconds) * time.Second,
+			ClosesAt:         createPollReq.ClosesAt,
+			VotePolicy:       service.VotePolicy(createPollReq.VotePolicy),
+			CreatorID:        creator.ID,
+			VoteRateLimit:    rateLimit,
+		})
 		if err != nil {
-			return api.ServerError(fmt.Errorf("error: %w", err))
+			if err == service.ErrDeadlineInPast {
+				return api.ClientError(http.StatusBadRequest, `{"closesAt":"closesAt must be in the future"}`)
+			}
+
+			if err == service.ErrInvalidCorrectOptions {
+				return api.ClientError(http.StatusBadRequest, `{"correctOptions":"quizzes need at least one correct option and other polls cannot have any"}`)
+			}
+
+			if err == service.ErrInvalidOptions {
+				return api.ClientError(http.StatusBadRequest, `{"options":"options are required, except for scale and free-text polls which cannot have any"}`)
+			}
+
+			if err == service.ErrInvalidWeightRules {
+				return api.ClientError(http.StatusBadRequest, `{"weightRules":"weight rules are only supported by single-choice, multi-select, quiz and prediction polls"}`)
+			}
+
+			if err == service.ErrInvalidScale {
+				return api.ClientError(http.StatusBadRequest, `{"scaleMax":"scale polls need scaleMin below scaleMax and at most 100 steps apart"}`)
+			}
+
+			if err == service.ErrSessionNotFound {
+				return api.ClientError(http.StatusBadRequest, `{"sessionId":"session does not exist"}`)
+			}
+
+			if err == service.ErrSessionChannelMismatch {
+				return api.ClientError(http.StatusBadRequest, `{"sessionId":"session belongs to a different channel"}`)
+			}
+
+			if err == service.ErrInvalidSelectionLimits {
+				return api.ClientError(http.StatusBadRequest, `{"maxSelections":"maxSelections must be at least minSelections and no more than the number of options"}`)
+			}
+
+			return api.ServerError(fmt.Errorf("error creating poll: %s", err))
 		}
 
-		return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
-	}
+		res, err := json.Marshal(createPollResponse{ID: poll.ID})
+		if err != nil {
+			return api.ServerError(fmt.Errorf("error marshalling poll response: %s", err))
+		}
 
-	poll, err := svc.CreatePoll(ctx, service.NewPoll{
-		Question:   createPollReq.Question,
-		Options:    createPollReq.Options,
-		ChannelARN: createPollReq.ChannelARN,
+		return events.APIGatewayProxyResponse{
+			Body:       string(res),
+			StatusCode: http.StatusAccepted,
+		}, nil
 	})
+}
+
+// idempotencyService is the part of the service that remembers the responses to retried requests
+type idempotencyService interface {
+	BeginIdempotentRequest(ctx context.Context, scope string, key string, request string) (*service.IdempotentResponse, error)
+	CompleteIdempotentRequest(ctx context.Context, scope string, key string, response service.IdempotentResponse) error
+	AbandonIdempotentRequest(ctx context.Context, scope string, key string) error
+}
+
+// idempotencyStore adapts the service's idempotency keys to the api package's IdempotencyStore
+type idempotencyStore struct {
+	idempotencyService
+}
+
+func (s idempotencyStore) BeginIdempotentRequest(ctx context.Context, scope string, key string, request string) (*api.IdempotentResponse, error) {
+	replay, err := s.idempotencyService.BeginIdempotentRequest(ctx, scope, key, request)
 	if err != nil {
-		return api.ServerError(fmt.Errorf("error creating poll: %s", err))
+		if err == service.ErrIdempotencyKeyInUse {
+			return nil, api.ErrIdempotencyKeyInUse
+		}
+
+		if err == service.ErrIdempotencyKeyReused {
+			return nil, api.ErrIdempotencyKeyReused
+		}
+
+		return nil, err
 	}
 
-	res, err := json.Marshal(createPollResponse{ID: poll.ID})
-	if err != nil {
-		return api.ServerError(fmt.Errorf("error marshalling poll response: %s", err))
+	if replay == nil {
+		return nil, nil
 	}
 
-	return events.APIGatewayProxyResponse{
-		Body:       string(res),
-		StatusCode: http.StatusAccepted,
-	}, nil
+	response := api.IdempotentResponse(*replay)
+
+	return &response, nil
+}
+
+func (s idempotencyStore) CompleteIdempotentRequest(ctx context.Context, scope string, key string, response api.IdempotentResponse) error {
+	return s.idempotencyService.CompleteIdempotentRequest(ctx, scope, key, service.IdempotentResponse(response))
 }
 
 func main() {
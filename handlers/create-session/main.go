@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/validator"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+type createSessionRequest struct {
+	Name       string `json:"name" validate:"required,min=1,max=100"`
+	ChannelARN string `json:"channelARN" validate:"required"`
+	// PointsPerCorrectAnswer defaults to 100
+	PointsPerCorrectAnswer int `json:"pointsPerCorrectAnswer" validate:"omitempty,min=1,max=10000"`
+	// SpeedBonus is the most extra points a correct answer can earn for being answered quickly
+	SpeedBonus int `json:"speedBonus" validate:"omitempty,min=0,max=10000"`
+}
+
+type createSessionResponse struct {
+	ID string `json:"id"`
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	validate, trans, err := validator.NewValidator("en")
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error creating validator: %s", err))
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	apiKey, err := auth.APIKey(request)
+	if err != nil {
+		return api.ClientError(http.StatusUnauthorized, "an api key is required")
+	}
+
+	creator, err := svc.AuthenticateCreator(ctx, apiKey)
+	if err != nil {
+		if err == service.ErrInvalidAPIKey {
+			return api.ClientError(http.StatusUnauthorized, "api key is not valid")
+		}
+
+		return api.ServerError(fmt.Errorf("error authenticating creator: %s", err))
+	}
+
+	var createSessionReq createSessionRequest
+	if err := json.Unmarshal([]byte(request.Body), &createSessionReq); err != nil {
+		return api.ServerError(fmt.Errorf("error unmarshalling request body: %s", err))
+	}
+
+	if err := validate.Struct(createSessionReq); err != nil {
+		errMap := validator.ExtractErrorMap(trans, err)
+
+		jsonErrMap, err := json.Marshal(errMap)
+		if err != nil {
+			return api.ServerError(fmt.Errorf("error: %w", err))
+		}
+
+		return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
+	}
+
+	if err := svc.AuthorizeChannel(ctx, createSessionReq.ChannelARN, creator.ID); err != nil {
+		if err == service.ErrNotChannelOwner {
+			return api.ClientError(http.StatusForbidden, "only the channel's owner can create sessions for it")
+		}
+
+		return api.ServerError(fmt.Errorf("error authorizing creator: %s", err))
+	}
+
+	session, err := svc.CreateSession(ctx, service.NewSession{
+		Name:                   createSessionReq.Name,
+		ChannelARN:             createSessionReq.ChannelARN,
+		PointsPerCorrectAnswer: createSessionReq.PointsPerCorrectAnswer,
+		SpeedBonus:             createSessionReq.SpeedBonus,
+	})
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error creating session: %s", err))
+	}
+
+	res, err := json.Marshal(createSessionResponse{ID: session.ID})
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error marshalling session response: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: http.StatusAccepted,
+	}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollID, ok := request.PathParameters["id"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'id' required")
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	apiKey, err := auth.APIKey(request)
+	if err != nil {
+		return api.ClientError(http.StatusUnauthorized, "an api key is required")
+	}
+
+	creator, err := svc.AuthenticateCreator(ctx, apiKey)
+	if err != nil {
+		if err == service.ErrInvalidAPIKey {
+			return api.ClientError(http.StatusUnauthorized, "api key is not valid")
+		}
+
+		return api.ServerError(fmt.Errorf("error authenticating creator: %s", err))
+	}
+
+	if err := svc.AuthorizePoll(ctx, pollID, creator.ID); err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "poll not found")
+		}
+
+		if err == service.ErrNotChannelOwner {
+			return api.ClientError(http.StatusForbidden, "only the channel's owner can manage its polls")
+		}
+
+		return api.ServerError(fmt.Errorf("error authorizing creator: %s", err))
+	}
+
+	if err := svc.DeletePoll(ctx, pollID); err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "poll not found")
+		}
+
+		return api.ServerError(fmt.Errorf("error deleting poll: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusNoContent,
+		Headers: map[string]string{
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
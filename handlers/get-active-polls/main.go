@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+type activePoll struct {
+	ID                   string         `json:"id"`
+	Type                 string         `json:"type"`
+	Question             string         `json:"question"`
+	Options              []pollOption   `json:"options"`
+	MinSelections        int            `json:"minSelections"`
+	MaxSelections        int            `json:"maxSelections"`
+	ScaleMin             *int           `json:"scaleMin,omitempty"`
+	ScaleMax             *int           `json:"scaleMax,omitempty"`
+	Status               string         `json:"status"`
+	VotePolicy           string         `json:"votePolicy"`
+	OpenedAt             *time.Time     `json:"openedAt,omitempty"`
+	ClosesAt             *time.Time     `json:"closesAt,omitempty"`
+	AggregatedVoteTotals map[string]int `json:"aggregatedVoteTotals"`
+	WeightedVoteTotals   map[string]int `json:"weightedVoteTotals,omitempty"`
+	// Scale summarises the votes on a scale poll
+	Scale *service.ScaleSummary `json:"scale,omitempty"`
+	// Terms are the most used answers to a free-text poll, most used first
+	Terms []service.TermCount `json:"terms,omitempty"`
+}
+
+// pollOption never says whether a quiz option is correct as open quizzes haven't been revealed
+type pollOption struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type getActivePollsResponse struct {
+	Data []activePoll `json:"data"`
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	rawChannelARN, ok := request.PathParameters["channelArn"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'channelArn' required")
+	}
+
+	// channel ARNs contain a slash so clients have to encode them to fit in the path
+	channelARN, err := url.PathUnescape(rawChannelARN)
+	if err != nil {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'channelArn' is not correctly encoded")
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	polls, err := svc.ListActivePolls(ctx, channelARN)
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error listing active polls: %s", err))
+	}
+
+	data := []activePoll{}
+	for _, p := range polls {
+		data = append(data, mapPollToActivePoll(p))
+	}
+
+	res, err := json.Marshal(getActivePollsResponse{Data: data})
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error marshalling active polls response: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                "application/json",
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
+
+func mapPollToActivePoll(p service.Poll) activePoll {
+	options := []pollOption{}
+	for _, o := range p.Options {
+		options = append(options, pollOption{ID: o.ID, Label: o.Label})
+	}
+
+	poll := activePoll{
+		ID:                   p.ID,
+		Type:                 string(p.Type),
+		Question:             p.Question,
+		Options:              options,
+		MinSelections:        p.MinSelections,
+		MaxSelections:        p.MaxSelections,
+		Status:               string(p.Status),
+		VotePolicy:           string(p.VotePolicy),
+		OpenedAt:             p.OpenedAt,
+		ClosesAt:             p.ClosesAt,
+		AggregatedVoteTotals: p.AggregatedVoteTotals,
+		WeightedVoteTotals:   p.WeightedVoteTotals,
+		Scale:                p.ScaleSummary(),
+	}
+
+	if p.Type == service.PollTypeScale {
+		poll.ScaleMin = &p.ScaleMin
+		poll.ScaleMax = &p.ScaleMax
+	}
+
+	if p.Type == service.PollTypeFreeText {
+		poll.Terms = p.TopTerms
+	}
+
+	return poll
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+type myVote struct {
+	ID      string     `json:"id"`
+	PollID  string     `json:"pollId"`
+	Answer  string     `json:"answer,omitempty"`
+	Answers []string   `json:"answers,omitempty"`
+	Ranking []string   `json:"ranking,omitempty"`
+	Value   *int       `json:"value,omitempty"`
+	VotedAt *time.Time `json:"votedAt,omitempty"`
+}
+
+type getMyVoteResponse struct {
+	Data myVote `json:"data"`
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollID, ok := request.PathParameters["id"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'id' required")
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	verifier, err := auth.NewVerifierFromEnv()
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error creating token verifier: %s", err))
+	}
+
+	claims, err := verifier.Authenticate(request)
+	if err != nil {
+		return api.ClientError(http.StatusUnauthorized, "a valid bearer token is required")
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	vote, err := svc.GetPollVote(ctx, pollID, claims.Subject)
+	if err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "poll not found")
+		}
+
+		if err == service.ErrVoteNotFound {
+			return api.ClientError(http.StatusNotFound, "you have not voted on this poll")
+		}
+
+		return api.ServerError(fmt.Errorf("error getting vote: %s", err))
+	}
+
+	res, err := json.Marshal(getMyVoteResponse{
+		Data: myVote{
+			ID:      vote.ID,
+			PollID:  vote.PollID,
+			Answer:  vote.Answer,
+			Answers: vote.Answers,
+			Ranking: vote.Ranking,
+			Value:   vote.Value,
+			VotedAt: vote.VotedAt,
+		},
+	})
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error marshalling vote response: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                "application/json",
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+type pollResults struct {
+	ID     string        `json:"id"`
+	Rounds []runoffRound `json:"rounds"`
+	Winner string        `json:"winner,omitempty"`
+	Tied   []string      `json:"tied,omitempty"`
+}
+
+type runoffRound struct {
+	Round      int            `json:"round"`
+	Totals     map[string]int `json:"totals"`
+	Exhausted  int            `json:"exhausted"`
+	Eliminated []string       `json:"eliminated,omitempty"`
+}
+
+type getPollResultsResponse struct {
+	Data pollResults `json:"data"`
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollID, ok := request.PathParameters["id"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'id' required")
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	results, err := svc.GetPollResults(ctx, pollID)
+	if err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "poll not found")
+		}
+
+		if err == service.ErrUnsupportedPollType {
+			return api.ClientError(http.StatusBadRequest, "round by round results are only available for ranked-choice polls")
+		}
+
+		return api.ServerError(fmt.Errorf("error getting poll results: %s", err))
+	}
+
+	res, err := json.Marshal(mapResultsToResponse(pollID, results))
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error marshalling poll results response: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                "application/json",
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
+
+func mapResultsToResponse(pollID string, r service.RunoffResult) getPollResultsResponse {
+	var rounds []runoffRound
+	for _, round := range r.Rounds {
+		rounds = append(rounds, runoffRound{
+			Round:      round.Round,
+			Totals:     round.Totals,
+			Exhausted:  round.Exhausted,
+			Eliminated: round.Eliminated,
+		})
+	}
+
+	return getPollResultsResponse{
+		Data: pollResults{
+			ID:     pollID,
+			Rounds: rounds,
+			Winner: r.Winner,
+			Tied:   r.Tied,
+		},
+	}
+}
+
+func main() {
+	lambda.Start(handler)
+}
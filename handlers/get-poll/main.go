@@ -7,8 +7,10 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
@@ -36,14 +38,47 @@ func init() {
 
 type pollOverview struct {
 	ID                   string         `json:"id"`
+	Type                 string         `json:"type"`
 	Question             string         `json:"question"`
 	Options              []pollOption   `json:"options"`
+	MinSelections        int            `json:"minSelections"`
+	MaxSelections        int            `json:"maxSelections"`
+	ScaleMin             *int           `json:"scaleMin,omitempty"`
+	ScaleMax             *int           `json:"scaleMax,omitempty"`
+	SessionID            string         `json:"sessionId,omitempty"`
+	Status               string         `json:"status"`
+	Version              int            `json:"version"`
+	AnswerRevealed       bool           `json:"answerRevealed"`
+	WinningOptionID      string         `json:"winningOptionId,omitempty"`
+	VotePolicy           string         `json:"votePolicy"`
+	ClosesAt             *time.Time     `json:"closesAt,omitempty"`
 	AggregatedVoteTotals map[string]int `json:"aggregatedVoteTotals"`
+	// WeightedVoteTotals sums the weight of each vote on polls with weight rules, whilst
+	// AggregatedVoteTotals counts each vote once
+	WeightRules        map[string]int `json:"weightRules,omitempty"`
+	WeightedVoteTotals map[string]int `json:"weightedVoteTotals,omitempty"`
+	// Scale summarises the votes on a scale poll
+	Scale *service.ScaleSummary `json:"scale,omitempty"`
+	// Terms are the most used answers to a free-text poll, most used first
+	Terms []service.TermCount `json:"terms,omitempty"`
+	// HasVoted and MyAnswer are only included when the poll is requested with a bearer token
+	HasVoted *bool       `json:"hasVoted,omitempty"`
+	MyAnswer *voteAnswer `json:"myAnswer,omitempty"`
+}
+
+// voteAnswer holds whichever of the fields suits the type of poll that was voted on
+type voteAnswer struct {
+	Answer  string   `json:"answer,omitempty"`
+	Answers []string `json:"answers,omitempty"`
+	Ranking []string `json:"ranking,omitempty"`
+	Value   *int     `json:"value,omitempty"`
 }
 
 type pollOption struct {
 	ID    string `json:"id"`
 	Label string `json:"label"`
+	// Correct is only included once a quiz's answer has been revealed
+	Correct *bool `json:"correct,omitempty"`
 }
 
 type getPollResponse struct {
@@ -75,7 +110,53 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		return api.ServerError(fmt.Errorf("error getting poll item: %s", err))
 	}
 
-	res, err := json.Marshal(mapPollToResponse(poll))
+	// drafts are hidden from everyone but the channel's owner, as if they didn't exist yet
+	if poll.Status == service.PollStatusDraft {
+		apiKey, _ := auth.APIKey(request)
+
+		owner, err := svc.OwnsChannel(ctx, apiKey, poll.ChannelARN)
+		if err != nil {
+			return api.ServerError(fmt.Errorf("error checking channel owner: %s", err))
+		}
+
+		if !owner {
+			return api.ClientError(http.StatusNotFound, "poll not found")
+		}
+	}
+
+	response := mapPollToResponse(poll)
+
+	// viewers don't have to sign in to see a poll, but a token that is sent has to be valid
+	if auth.HasToken(request) {
+		verifier, err := auth.NewVerifierFromEnv()
+		if err != nil {
+			return api.ServerError(fmt.Errorf("error creating token verifier: %s", err))
+		}
+
+		claims, err := verifier.Authenticate(request)
+		if err != nil {
+			return api.ClientError(http.StatusUnauthorized, "bearer token is not valid")
+		}
+
+		vote, err := svc.GetPollVote(ctx, pollID, claims.Subject)
+		if err != nil && err != service.ErrVoteNotFound {
+			return api.ServerError(fmt.Errorf("error getting user's vote: %s", err))
+		}
+
+		hasVoted := err == nil
+		response.Data.HasVoted = &hasVoted
+
+		if hasVoted {
+			response.Data.MyAnswer = &voteAnswer{
+				Answer:  vote.Answer,
+				Answers: vote.Answers,
+				Ranking: vote.Ranking,
+				Value:   vote.Value,
+			}
+		}
+	}
+
+	res, err := json.Marshal(response)
 	if err != nil {
 		return api.ServerError(fmt.Errorf("error marshalling poll response: %s", err))
 	}
@@ -93,20 +174,49 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 func mapPollToResponse(p service.Poll) getPollResponse {
 	var po []pollOption
 	for _, opt := range p.Options {
-		po = append(po, pollOption{
+		o := pollOption{
 			ID:    opt.ID,
 			Label: opt.Label,
-		})
+		}
+
+		if p.Type == service.PollTypeQuiz && p.AnswerRevealed {
+			correct := opt.Correct
+			o.Correct = &correct
+		}
+
+		po = append(po, o)
 	}
 
-	return getPollResponse{
-		Data: pollOverview{
-			ID:                   p.ID,
-			Question:             p.Question,
-			Options:              po,
-			AggregatedVoteTotals: p.AggregatedVoteTotals,
-		},
+	overview := pollOverview{
+		ID:                   p.ID,
+		Type:                 string(p.Type),
+		Question:             p.Question,
+		Options:              po,
+		MinSelections:        p.MinSelections,
+		MaxSelections:        p.MaxSelections,
+		SessionID:            p.SessionID,
+		Status:               string(p.Status),
+		Version:              p.Version,
+		AnswerRevealed:       p.AnswerRevealed,
+		WinningOptionID:      p.WinningOptionID,
+		VotePolicy:           string(p.VotePolicy),
+		ClosesAt:             p.ClosesAt,
+		AggregatedVoteTotals: p.AggregatedVoteTotals,
+		WeightRules:          p.WeightRules,
+		WeightedVoteTotals:   p.WeightedVoteTotals,
+		Scale:                p.ScaleSummary(),
+	}
+
+	if p.Type == service.PollTypeScale {
+		overview.ScaleMin = &p.ScaleMin
+		overview.ScaleMax = &p.ScaleMax
 	}
+
+	if p.Type == service.PollTypeFreeText {
+		overview.Terms = p.TopTerms
+	}
+
+	return getPollResponse{Data: overview}
 }
 
 func main() {
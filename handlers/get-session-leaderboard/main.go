@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+const (
+	_defaultLimit = 10
+	_maxLimit     = 100
+)
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+type leaderboard struct {
+	SessionID string                 `json:"sessionId"`
+	Scores    []service.SessionScore `json:"scores"`
+	// Cursor is passed back to fetch the next page, it is omitted on the last page
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type getSessionLeaderboardResponse struct {
+	Data leaderboard `json:"data"`
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	sessionID, ok := request.PathParameters["id"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'id' required")
+	}
+
+	limit := _defaultLimit
+	if l, ok := request.QueryStringParameters["limit"]; ok {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed < 1 || parsed > _maxLimit {
+			return api.ClientError(http.StatusBadRequest, fmt.Sprintf(`{"limit":"limit must be between 1 and %d"}`, _maxLimit))
+		}
+
+		limit = parsed
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	board, err := svc.GetSessionLeaderboard(ctx, sessionID, limit, request.QueryStringParameters["cursor"])
+	if err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "session not found")
+		}
+
+		if err == service.ErrInvalidCursor {
+			return api.ClientError(http.StatusBadRequest, `{"cursor":"cursor is not valid"}`)
+		}
+
+		return api.ServerError(fmt.Errorf("error getting session leaderboard: %s", err))
+	}
+
+	res, err := json.Marshal(getSessionLeaderboardResponse{
+		Data: leaderboard{
+			SessionID: board.SessionID,
+			Scores:    board.Scores,
+			Cursor:    board.Cursor,
+		},
+	})
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error marshalling leaderboard response: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                "application/json",
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+const (
+	_defaultLimit = 10
+	_maxLimit     = 100
+)
+
+var errInvalidStatus = errors.New("status is not a poll status")
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+type pollSummary struct {
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	Question  string     `json:"question"`
+	Status    string     `json:"status"`
+	Version   int        `json:"version"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	ClosesAt  *time.Time `json:"closesAt,omitempty"`
+}
+
+type channelPolls struct {
+	ChannelARN string        `json:"channelARN"`
+	Polls      []pollSummary `json:"polls"`
+	// Cursor is passed back to fetch the next page, it is omitted on the last page
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type listChannelPollsResponse struct {
+	Data channelPolls `json:"data"`
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	rawChannelARN, ok := request.PathParameters["channelArn"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'channelArn' required")
+	}
+
+	// channel ARNs contain a slash so clients have to encode them to fit in the path
+	channelARN, err := url.PathUnescape(rawChannelARN)
+	if err != nil {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'channelArn' is not correctly encoded")
+	}
+
+	limit := _defaultLimit
+	if l, ok := request.QueryStringParameters["limit"]; ok {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed < 1 || parsed > _maxLimit {
+			return api.ClientError(http.StatusBadRequest, fmt.Sprintf(`{"limit":"limit must be between 1 and %d"}`, _maxLimit))
+		}
+
+		limit = parsed
+	}
+
+	statuses, err := parseStatuses(request.QueryStringParameters["status"])
+	if err != nil {
+		return api.ClientError(http.StatusBadRequest, `{"status":"status must be a comma separated list of draft, open and closed"}`)
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	// drafts are only listed for the channel's owner, everyone else gets its open and closed polls
+	if includesDrafts(statuses) {
+		apiKey, err := auth.APIKey(request)
+		if err != nil && len(statuses) > 0 {
+			return api.ClientError(http.StatusUnauthorized, "an api key is required to list draft polls")
+		}
+
+		owner, err := svc.OwnsChannel(ctx, apiKey, channelARN)
+		if err != nil {
+			return api.ServerError(fmt.Errorf("error checking channel owner: %s", err))
+		}
+
+		if !owner {
+			if len(statuses) > 0 {
+				return api.ClientError(http.StatusForbidden, "only the channel's owner can list its draft polls")
+			}
+
+			statuses = []service.PollStatus{service.PollStatusOpen, service.PollStatusClosed}
+		}
+	}
+
+	page, err := svc.ListChannelPolls(ctx, channelARN, statuses, limit, request.QueryStringParameters["cursor"])
+	if err != nil {
+		if err == service.ErrInvalidCursor {
+			return api.ClientError(http.StatusBadRequest, `{"cursor":"cursor is not valid"}`)
+		}
+
+		return api.ServerError(fmt.Errorf("error listing channel polls: %s", err))
+	}
+
+	data := channelPolls{
+		ChannelARN: channelARN,
+		Polls:      []pollSummary{},
+		Cursor:     page.Cursor,
+	}
+
+	for _, p := range page.Polls {
+		data.Polls = append(data.Polls, pollSummary{
+			ID:        p.ID,
+			Type:      string(p.Type),
+			Question:  p.Question,
+			Status:    string(p.Status),
+			Version:   p.Version,
+			CreatedAt: p.CreatedAt,
+			ClosesAt:  p.ClosesAt,
+		})
+	}
+
+	res, err := json.Marshal(listChannelPollsResponse{Data: data})
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error marshalling channel polls response: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                "application/json",
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
+
+// parseStatuses reads a comma separated list of poll statuses, e.g. "open,closed"
+func parseStatuses(raw string) ([]service.PollStatus, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var statuses []service.PollStatus
+	for _, s := range strings.Split(raw, ",") {
+		status := service.PollStatus(strings.TrimSpace(s))
+
+		switch status {
+		case service.PollStatusDraft, service.PollStatusOpen, service.PollStatusClosed:
+			statuses = append(statuses, status)
+		default:
+			return nil, errInvalidStatus
+		}
+	}
+
+	return statuses, nil
+}
+
+// includesDrafts checks whether listing polls in the given statuses would include drafts, which
+// every poll does when no statuses are given
+func includesDrafts(statuses []service.PollStatus) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+
+	for _, status := range statuses {
+		if status == service.PollStatusDraft {
+			return true
+		}
+	}
+
+	return false
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+)
+
+func TestParseStatuses(t *testing.T) {
+	statuses, err := parseStatuses("open, closed")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []service.PollStatus{service.PollStatusOpen, service.PollStatusClosed}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected %v, got %v", expected, statuses)
+	}
+
+	if _, err := parseStatuses("open,archived"); err != errInvalidStatus {
+		t.Errorf("expected an unknown status to be rejected, got %v", err)
+	}
+}
+
+func TestIncludesDrafts(t *testing.T) {
+	tests := []struct {
+		statuses []service.PollStatus
+		expected bool
+	}{
+		{statuses: nil, expected: true},
+		{statuses: []service.PollStatus{service.PollStatusOpen, service.PollStatusDraft}, expected: true},
+		{statuses: []service.PollStatus{service.PollStatusOpen, service.PollStatusClosed}, expected: false},
+	}
+
+	for _, tt := range tests {
+		if includesDrafts(tt.statuses) != tt.expected {
+			t.Errorf("expected includesDrafts(%v) to be %t", tt.statuses, tt.expected)
+		}
+	}
+}
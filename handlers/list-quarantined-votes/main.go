@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+const (
+	_defaultLimit = 20
+	_maxLimit     = 100
+)
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+type quarantinedVote struct {
+	ID      string   `json:"id"`
+	UserID  string   `json:"userId"`
+	Answer  string   `json:"answer,omitempty"`
+	Answers []string `json:"answers,omitempty"`
+	Ranking []string `json:"ranking,omitempty"`
+	Value   *int     `json:"value,omitempty"`
+	// FraudSignals are the reasons the vote was quarantined
+	FraudSignals []string   `json:"fraudSignals"`
+	VotedAt      *time.Time `json:"votedAt,omitempty"`
+}
+
+type quarantinedVotes struct {
+	PollID string            `json:"pollId"`
+	Votes  []quarantinedVote `json:"votes"`
+	// Cursor is passed back to fetch the next page, it is omitted on the last page
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type listQuarantinedVotesResponse struct {
+	Data quarantinedVotes `json:"data"`
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollID, ok := request.PathParameters["id"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'id' required")
+	}
+
+	limit := _defaultLimit
+	if l, ok := request.QueryStringParameters["limit"]; ok {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed < 1 || parsed > _maxLimit {
+			return api.ClientError(http.StatusBadRequest, fmt.Sprintf(`{"limit":"limit must be between 1 and %d"}`, _maxLimit))
+		}
+
+		limit = parsed
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	apiKey, err := auth.APIKey(request)
+	if err != nil {
+		return api.ClientError(http.StatusUnauthorized, "an api key is required")
+	}
+
+	creator, err := svc.AuthenticateCreator(ctx, apiKey)
+	if err != nil {
+		if err == service.ErrInvalidAPIKey {
+			return api.ClientError(http.StatusUnauthorized, "api key is not valid")
+		}
+
+		return api.ServerError(fmt.Errorf("error authenticating creator: %s", err))
+	}
+
+	if err := svc.AuthorizePoll(ctx, pollID, creator.ID); err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "poll not found")
+		}
+
+		if err == service.ErrNotChannelOwner {
+			return api.ClientError(http.StatusForbidden, "only the channel's owner can manage its polls")
+		}
+
+		return api.ServerError(fmt.Errorf("error authorizing creator: %s", err))
+	}
+
+	page, err := svc.ListQuarantinedVotes(ctx, pollID, limit, request.QueryStringParameters["cursor"])
+	if err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "poll not found")
+		}
+
+		if err == service.ErrInvalidCursor {
+			return api.ClientError(http.StatusBadRequest, `{"cursor":"cursor is not valid"}`)
+		}
+
+		return api.ServerError(fmt.Errorf("error listing quarantined votes: %s", err))
+	}
+
+	data := quarantinedVotes{
+		PollID: pollID,
+		Votes:  []quarantinedVote{},
+		Cursor: page.Cursor,
+	}
+
+	for _, v := range page.Votes {
+		data.Votes = append(data.Votes, quarantinedVote{
+			ID:           v.ID,
+			UserID:       v.UserID,
+			Answer:       v.Answer,
+			Answers:      v.Answers,
+			Ranking:      v.Ranking,
+			Value:        v.Value,
+			FraudSignals: v.FraudSignals,
+			VotedAt:      v.VotedAt,
+		})
+	}
+
+	res, err := json.Marshal(listQuarantinedVotesResponse{Data: data})
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error marshalling quarantined votes response: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                "application/json",
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
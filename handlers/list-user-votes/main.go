@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+const (
+	_defaultLimit = 20
+	_maxLimit     = 100
+)
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+type votedPoll struct {
+	PollID     string `json:"pollId"`
+	PollType   string `json:"pollType"`
+	PollStatus string `json:"pollStatus"`
+	Question   string `json:"question"`
+	// Choices are the options the user chose, in order of preference for ranked-choice polls
+	Choices []choice `json:"choices,omitempty"`
+	// Value is the user's answer to a scale poll
+	Value *int `json:"value,omitempty"`
+	// Text is the user's answer to a free-text poll
+	Text string `json:"text,omitempty"`
+	// Correct is only included once a quiz's answer has been revealed
+	Correct *bool      `json:"correct,omitempty"`
+	VotedAt *time.Time `json:"votedAt,omitempty"`
+}
+
+type choice struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type voteHistory struct {
+	UserID string      `json:"userId"`
+	Votes  []votedPoll `json:"votes"`
+	// Cursor is passed back to fetch the next page, it is omitted on the last page
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type listUserVotesResponse struct {
+	Data voteHistory `json:"data"`
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, ok := request.PathParameters["id"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'id' required")
+	}
+
+	limit := _defaultLimit
+	if l, ok := request.QueryStringParameters["limit"]; ok {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed < 1 || parsed > _maxLimit {
+			return api.ClientError(http.StatusBadRequest, fmt.Sprintf(`{"limit":"limit must be between 1 and %d"}`, _maxLimit))
+		}
+
+		limit = parsed
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	verifier, err := auth.NewVerifierFromEnv()
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error creating token verifier: %s", err))
+	}
+
+	claims, err := verifier.Authenticate(request)
+	if err != nil {
+		return api.ClientError(http.StatusUnauthorized, "a valid bearer token is required")
+	}
+
+	// voting histories are private, viewers can only list their own
+	if claims.Subject != userID {
+		return api.ClientError(http.StatusForbidden, "you can only list your own votes")
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	history, err := svc.ListUserVotes(ctx, userID, limit, request.QueryStringParameters["cursor"])
+	if err != nil {
+		if err == service.ErrInvalidCursor {
+			return api.ClientError(http.StatusBadRequest, `{"cursor":"cursor is not valid"}`)
+		}
+
+		return api.ServerError(fmt.Errorf("error listing user votes: %s", err))
+	}
+
+	data := voteHistory{
+		UserID: history.UserID,
+		Votes:  []votedPoll{},
+		Cursor: history.Cursor,
+	}
+
+	for _, entry := range history.Entries {
+		data.Votes = append(data.Votes, mapEntryToVotedPoll(entry))
+	}
+
+	res, err := json.Marshal(listUserVotesResponse{Data: data})
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error marshalling vote history response: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                "application/json",
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
+
+// mapEntryToVotedPoll swaps the option IDs in a vote for the labels viewers saw when voting
+func mapEntryToVotedPoll(entry service.VoteHistoryEntry) votedPoll {
+	poll, vote := entry.Poll, entry.Vote
+
+	voted := votedPoll{
+		PollID:     poll.ID,
+		PollType:   string(poll.Type),
+		PollStatus: string(poll.Status),
+		Question:   poll.Question,
+		Value:      vote.Value,
+		VotedAt:    vote.VotedAt,
+	}
+
+	if poll.Type == service.PollTypeFreeText {
+		voted.Text = vote.Answer
+		return voted
+	}
+
+	labels := make(map[string]string, len(poll.Options))
+	for _, o := range poll.Options {
+		labels[o.ID] = o.Label
+	}
+
+	var chosen []string
+	switch {
+	case len(vote.Ranking) > 0:
+		chosen = vote.Ranking
+	case len(vote.Answers) > 0:
+		chosen = vote.Answers
+	case vote.Answer != "":
+		chosen = []string{vote.Answer}
+	}
+
+	for _, id := range chosen {
+		voted.Choices = append(voted.Choices, choice{ID: id, Label: labels[id]})
+	}
+
+	if poll.Type == service.PollTypeQuiz && poll.AnswerRevealed {
+		voted.Correct = vote.Correct
+	}
+
+	return voted
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+)
+
+func TestMapEntryToVotedPoll(t *testing.T) {
+	correct := true
+
+	entry := service.VoteHistoryEntry{
+		Poll: service.Poll{
+			ID:       "poll",
+			Type:     service.PollTypeRankedChoice,
+			Question: "Which map next?",
+			Options:  []service.PollOption{{ID: "a", Label: "Dust"}, {ID: "b", Label: "Inferno"}},
+		},
+		Vote: service.PollVote{Ranking: []string{"b", "a"}, Correct: &correct},
+	}
+
+	voted := mapEntryToVotedPoll(entry)
+
+	expected := []choice{{ID: "b", Label: "Inferno"}, {ID: "a", Label: "Dust"}}
+	if !reflect.DeepEqual(voted.Choices, expected) {
+		t.Errorf("expected the ranking to be labelled in order, got %+v", voted.Choices)
+	}
+
+	if voted.Correct != nil {
+		t.Errorf("expected correctness to only be shown for revealed quizzes")
+	}
+}
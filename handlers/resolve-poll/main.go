@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/validator"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+type resolvePollRequest struct {
+	WinningOptionID string `json:"winningOptionId" validate:"required"`
+}
+
+type predictionOutcome struct {
+	ID              string `json:"id"`
+	WinningOptionID string `json:"winningOptionId"`
+	PointsPerWinner int    `json:"pointsPerWinner"`
+	Winners         int    `json:"winners"`
+	Losers          int    `json:"losers"`
+}
+
+type resolvePollResponse struct {
+	Data predictionOutcome `json:"data"`
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollID, ok := request.PathParameters["id"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'id' required")
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	validate, trans, err := validator.NewValidator("en")
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error creating validator: %s", err))
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	apiKey, err := auth.APIKey(request)
+	if err != nil {
+		return api.ClientError(http.StatusUnauthorized, "an api key is required")
+	}
+
+	creator, err := svc.AuthenticateCreator(ctx, apiKey)
+	if err != nil {
+		if err == service.ErrInvalidAPIKey {
+			return api.ClientError(http.StatusUnauthorized, "api key is not valid")
+		}
+
+		return api.ServerError(fmt.Errorf("error authenticating creator: %s", err))
+	}
+
+	if err := svc.AuthorizePoll(ctx, pollID, creator.ID); err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "poll not found")
+		}
+
+		if err == service.ErrNotChannelOwner {
+			return api.ClientError(http.StatusForbidden, "only the channel's owner can manage its polls")
+		}
+
+		return api.ServerError(fmt.Errorf("error authorizing creator: %s", err))
+	}
+
+	var resolvePollReq resolvePollRequest
+	if err := json.Unmarshal([]byte(request.Body), &resolvePollReq); err != nil {
+		return api.ServerError(fmt.Errorf("error unmarshalling request body: %s", err))
+	}
+
+	if err := validate.Struct(resolvePollReq); err != nil {
+		errMap := validator.ExtractErrorMap(trans, err)
+
+		jsonErrMap, err := json.Marshal(errMap)
+		if err != nil {
+			return api.ServerError(fmt.Errorf("error: %w", err))
+		}
+
+		return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
+	}
+
+	resolution, err := svc.ResolvePoll(ctx, pollID, resolvePollReq.WinningOptionID)
+	if err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "poll not found")
+		}
+
+		if err == service.ErrUnsupportedPollType {
+			return api.ClientError(http.StatusBadRequest, "only predictions can be resolved")
+		}
+
+		if err == service.ErrInvalidWinningOption {
+			return api.ClientError(http.StatusBadRequest, `{"winningOptionId":"winningOptionId must be one of the prediction's options"}`)
+		}
+
+		if err == service.ErrPollNotClosed {
+			return api.ClientError(http.StatusConflict, "the prediction must be closed before it is resolved")
+		}
+
+		if err == service.ErrPollAlreadyResolved {
+			return api.ClientError(http.StatusConflict, "the prediction has already been resolved")
+		}
+
+		return api.ServerError(fmt.Errorf("error resolving prediction: %s", err))
+	}
+
+	res, err := json.Marshal(resolvePollResponse{
+		Data: predictionOutcome{
+			ID:              resolution.Poll.ID,
+			WinningOptionID: resolution.Poll.WinningOptionID,
+			PointsPerWinner: resolution.Poll.PredictionPoints,
+			Winners:         resolution.Winners,
+			Losers:          resolution.Losers,
+		},
+	})
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error marshalling prediction outcome response: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                "application/json",
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
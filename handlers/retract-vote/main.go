@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/validator"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+type retractVoteRequest struct {
+	PollID string `json:"pollId" validate:"required"`
+	// UserID is taken from the bearer token, it is only here so requests that still send one can be
+	// rejected
+	UserID string `json:"userId"`
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollID, ok := request.PathParameters["id"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'id' required")
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	verifier, err := auth.NewVerifierFromEnv()
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error creating token verifier: %s", err))
+	}
+
+	claims, err := verifier.Authenticate(request)
+	if err != nil {
+		return api.ClientError(http.StatusUnauthorized, "a valid bearer token is required")
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	validate, trans, err := validator.NewValidator("en")
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error creating validator: %s", err))
+	}
+
+	// the user comes from the token so the body is optional
+	var retractVoteReq retractVoteRequest
+	if request.Body != "" {
+		if err := json.Unmarshal([]byte(request.Body), &retractVoteReq); err != nil {
+			return api.ServerError(fmt.Errorf("error unmarshalling request body: %s", err))
+		}
+	}
+	retractVoteReq.PollID = pollID
+
+	if retractVoteReq.UserID != "" {
+		return api.ClientError(http.StatusBadRequest, `{"userId":"userId is taken from the bearer token and cannot be provided"}`)
+	}
+	retractVoteReq.UserID = claims.Subject
+
+	if err = validate.Struct(retractVoteReq); err != nil {
+		errMap := validator.ExtractErrorMap(trans, err)
+
+		jsonErrMap, err := json.Marshal(errMap)
+		if err != nil {
+			return api.ServerError(fmt.Errorf("error: %w", err))
+		}
+
+		return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
+	}
+
+	err = svc.DeletePollVote(ctx, retractVoteReq.PollID, retractVoteReq.UserID)
+	if err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "vote not found")
+		}
+
+		if err == service.ErrPollNotOpen {
+			return api.ClientError(http.StatusConflict, "poll is not open for voting")
+		}
+
+		if err == service.ErrVoteNotChangeable {
+			return api.ClientError(http.StatusConflict, "votes on this poll cannot be retracted")
+		}
+
+		return api.ServerError(fmt.Errorf("error retracting poll vote: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusNoContent,
+		Headers: map[string]string{
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
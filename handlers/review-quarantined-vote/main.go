@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/validator"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+const (
+	_actionRelease = "release"
+	_actionDiscard = "discard"
+)
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+type reviewVoteRequest struct {
+	// Action releases the vote so it's counted or discards it for good
+	Action string `json:"action" validate:"required,oneof=release discard"`
+}
+
+type reviewedVote struct {
+	ID     string `json:"id"`
+	PollID string `json:"pollId"`
+	Action string `json:"action"`
+}
+
+type reviewVoteResponse struct {
+	Data reviewedVote `json:"data"`
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollID, ok := request.PathParameters["id"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'id' required")
+	}
+
+	voteID, ok := request.PathParameters["voteId"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'voteId' required")
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	validate, trans, err := validator.NewValidator("en")
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error creating validator: %s", err))
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	apiKey, err := auth.APIKey(request)
+	if err != nil {
+		return api.ClientError(http.StatusUnauthorized, "an api key is required")
+	}
+
+	creator, err := svc.AuthenticateCreator(ctx, apiKey)
+	if err != nil {
+		if err == service.ErrInvalidAPIKey {
+			return api.ClientError(http.StatusUnauthorized, "api key is not valid")
+		}
+
+		return api.ServerError(fmt.Errorf("error authenticating creator: %s", err))
+	}
+
+	if err := svc.AuthorizePoll(ctx, pollID, creator.ID); err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "poll not found")
+		}
+
+		if err == service.ErrNotChannelOwner {
+			return api.ClientError(http.StatusForbidden, "only the channel's owner can manage its polls")
+		}
+
+		return api.ServerError(fmt.Errorf("error authorizing creator: %s", err))
+	}
+
+	var reviewVoteReq reviewVoteRequest
+	if err := json.Unmarshal([]byte(request.Body), &reviewVoteReq); err != nil {
+		return api.ServerError(fmt.Errorf("error unmarshalling request body: %s", err))
+	}
+
+	if err := validate.Struct(reviewVoteReq); err != nil {
+		errMap := validator.ExtractErrorMap(trans, err)
+
+		jsonErrMap, err := json.Marshal(errMap)
+		if err != nil {
+			return api.ServerError(fmt.Errorf("error: %w", err))
+		}
+
+		return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
+	}
+
+	switch reviewVoteReq.Action {
+	case _actionRelease:
+		_, err = svc.ReleaseVote(ctx, pollID, voteID)
+	case _actionDiscard:
+		err = svc.DiscardVote(ctx, pollID, voteID)
+	}
+	if err != nil {
+		if err == service.ErrVoteNotQuarantined {
+			return api.ClientError(http.StatusNotFound, "quarantined vote not found")
+		}
+
+		return api.ServerError(fmt.Errorf("error reviewing quarantined vote: %s", err))
+	}
+
+	res, err := json.Marshal(reviewVoteResponse{
+		Data: reviewedVote{
+			ID:     voteID,
+			PollID: pollID,
+			Action: reviewVoteReq.Action,
+		},
+	})
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error marshalling reviewed vote response: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                "application/json",
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
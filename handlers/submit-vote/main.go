@@ -3,13 +3,16 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/ratelimit"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/validator"
@@ -22,6 +25,13 @@ import (
 
 const _tableNameEnv = "POLL_TABLE_NAME"
 
+const (
+	// _userRateLimitEnv and _ipRateLimitEnv limit how quickly votes can be made on a poll by each user
+	// and from each IP address, written like 10/1m. Either can be left unset to not limit votes that way.
+	_userRateLimitEnv = "VOTE_RATE_LIMIT_PER_USER"
+	_ipRateLimitEnv   = "VOTE_RATE_LIMIT_PER_IP"
+)
+
 var db dynamodb.Client
 var ivsClient ivs.Client
 
@@ -37,9 +47,26 @@ func init() {
 
 type submitVoteRequest struct {
 	PollID string `json:"pollId" validate:"required"`
-	// Hacky way to provide a user id whilst we don't have auth
-	UserID string `json:"userId" validate:"required"`
-	Answer string `json:"answer" validate:"required"`
+	// UserID is taken from the bearer token, it is only here so requests that still send one can be
+	// rejected
+	UserID string `json:"userId"`
+	Answer string `json:"answer" validate:"required_without_all=Answers Ranking Value,max=200"`
+	// Answers is used instead of Answer for multi-select polls
+	Answers []string `json:"answers" validate:"omitempty,dive,required"`
+	// Ranking is used instead of Answer for ranked-choice polls, most preferred option first
+	Ranking []string `json:"ranking" validate:"omitempty,dive,required"`
+	// Value is used instead of Answer for scale polls
+	Value *int `json:"value"`
+}
+
+type submittedVote struct {
+	ID string `json:"id"`
+	// Replaced is true when the vote changed the user's earlier vote
+	Replaced bool `json:"replaced"`
+}
+
+type submitVoteResponse struct {
+	Data submittedVote `json:"data"`
 }
 
 func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
@@ -53,48 +80,219 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
 	}
 
-	repo := repository.New(tableName, &db)
-	broadcaster := broadcast.New(&ivsClient)
+	verifier, err := auth.NewVerifierFromEnv()
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error creating token verifier: %s", err))
+	}
 
-	svc := service.New(repo, broadcaster)
+	claims, err := verifier.Authenticate(request)
+	if err != nil {
+		return api.ClientError(http.StatusUnauthorized, "a valid bearer token is required")
+	}
 
-	validate, trans, err := validator.NewValidator("en")
+	userLimit, err := rateLimitFromEnv(_userRateLimitEnv)
 	if err != nil {
-		return api.ServerError(fmt.Errorf("error creating validator: %s", err))
+		return api.ServerError(err)
 	}
 
-	var submitPollReq submitVoteRequest
-	if err := json.Unmarshal([]byte(request.Body), &submitPollReq); err != nil {
-		return api.ServerError(fmt.Errorf("error unmarshalling request body: %s", err))
+	ipLimit, err := rateLimitFromEnv(_ipRateLimitEnv)
+	if err != nil {
+		return api.ServerError(err)
 	}
-	submitPollReq.PollID = pollID
 
-	if err = validate.Struct(submitPollReq); err != nil {
-		errMap := validator.ExtractErrorMap(trans, err)
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+	limiter := ratelimit.NewDynamoDB(tableName, &db)
 
-		jsonErrMap, err := json.Marshal(errMap)
+	svc := service.New(repo, broadcaster, service.WithVoteRateLimits(limiter, service.VoteRateLimits{
+		PerUser: userLimit,
+		PerIP:   ipLimit,
+	}))
+
+	// retries sent with the same Idempotency-Key get the vote made by the first request
+	return api.Idempotent(ctx, idempotencyStore{svc}, "VOTES#"+pollID+"#"+claims.Subject, request, func() (events.APIGatewayProxyResponse, error) {
+		validate, trans, err := validator.NewValidator("en")
 		if err != nil {
-			return api.ServerError(fmt.Errorf("error: %w", err))
+			return api.ServerError(fmt.Errorf("error creating validator: %s", err))
 		}
 
-		return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
-	}
+		var submitPollReq submitVoteRequest
+		if err := json.Unmarshal([]byte(request.Body), &submitPollReq); err != nil {
+			return api.ServerError(fmt.Errorf("error unmarshalling request body: %s", err))
+		}
+		submitPollReq.PollID = pollID
+
+		if submitPollReq.UserID != "" {
+			return api.ClientError(http.StatusBadRequest, `{"userId":"userId is taken from the bearer token and cannot be provided"}`)
+		}
+		submitPollReq.UserID = claims.Subject
+
+		if err = validate.Struct(submitPollReq); err != nil {
+			errMap := validator.ExtractErrorMap(trans, err)
+
+			jsonErrMap, err := json.Marshal(errMap)
+			if err != nil {
+				return api.ServerError(fmt.Errorf("error: %w", err))
+			}
+
+			return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
+		}
+
+		vote, err := svc.CreatePollVote(ctx, service.NewPollVote{
+			PollID:  submitPollReq.PollID,
+			UserID:  submitPollReq.UserID,
+			Answer:  submitPollReq.Answer,
+			Answers: submitPollReq.Answers,
+			Ranking: submitPollReq.Ranking,
+			Value:   submitPollReq.Value,
+			// the tier decides the weight of the vote, so it comes from the token rather than the body
+			Tier: claims.Tier,
+			// a client can't spoof this as API Gateway sets it from the connection
+			SourceIP:  request.RequestContext.Identity.SourceIP,
+			UserAgent: request.RequestContext.Identity.UserAgent,
+		})
+		if err != nil {
+			var rateLimitErr *service.RateLimitError
+			if errors.As(err, &rateLimitErr) {
+				return api.TooManyRequests(rateLimitErr.RetryAfter)
+			}
+
+			if err == service.ErrRecordNotFound {
+				return api.ClientError(http.StatusNotFound, "poll not found")
+			}
+
+			if err == service.ErrPollNotOpen {
+				return api.ClientError(http.StatusConflict, "poll is not open for voting")
+			}
+
+			if err == service.ErrAlreadyVoted {
+				return api.ClientError(http.StatusConflict, "you have already voted on this poll")
+			}
+
+			if err == service.ErrPollVersionConflict {
+				return api.ClientError(http.StatusConflict, "poll was edited whilst voting, fetch it again before voting")
+			}
+
+			if err == service.ErrInvalidSelectionCount {
+				jsonErrMap, err := json.Marshal(map[string]string{
+					"answers": "number of answers must be within the poll's minSelections and maxSelections",
+				})
+				if err != nil {
+					return api.ServerError(fmt.Errorf("error: %w", err))
+				}
+
+				return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
+			}
+
+			if err == service.ErrInvalidFreeText {
+				jsonErrMap, err := json.Marshal(map[string]string{
+					"answer": "answer must contain a word or number and be at most 30 characters",
+				})
+				if err != nil {
+					return api.ServerError(fmt.Errorf("error: %w", err))
+				}
 
-	_, err = svc.CreatePollVote(ctx, service.NewPollVote{
-		PollID: submitPollReq.PollID,
-		UserID: submitPollReq.UserID,
-		Answer: submitPollReq.Answer,
+				return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
+			}
+
+			if err == service.ErrInvalidScaleValue {
+				jsonErrMap, err := json.Marshal(map[string]string{
+					"value": "value must be within the poll's scaleMin and scaleMax",
+				})
+				if err != nil {
+					return api.ServerError(fmt.Errorf("error: %w", err))
+				}
+
+				return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
+			}
+
+			if err == service.ErrInvalidAnswer {
+				jsonErrMap, err := json.Marshal(map[string]string{
+					"answer": "answers must be unique options from the poll",
+				})
+				if err != nil {
+					return api.ServerError(fmt.Errorf("error: %w", err))
+				}
+
+				return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
+			}
+
+			return api.ServerError(fmt.Errorf("error creating poll bote: %s", err))
+		}
+
+		res, err := json.Marshal(submitVoteResponse{
+			Data: submittedVote{
+				ID:       vote.ID,
+				Replaced: vote.Replaced,
+			},
+		})
+		if err != nil {
+			return api.ServerError(fmt.Errorf("error marshalling vote response: %s", err))
+		}
+
+		return events.APIGatewayProxyResponse{
+			Body:       string(res),
+			StatusCode: http.StatusAccepted,
+			Headers: map[string]string{
+				"Content-Type":                "application/json",
+				"Access-Control-Allow-Origin": "*",
+			},
+		}, nil
 	})
+}
+
+// rateLimitFromEnv reads a rate limit from the environment, an unset limit doesn't limit anything
+func rateLimitFromEnv(env string) (ratelimit.Limit, error) {
+	raw := os.Getenv(env)
+	if raw == "" {
+		return ratelimit.Limit{}, nil
+	}
+
+	limit, err := ratelimit.ParseLimit(raw)
+	if err != nil {
+		return ratelimit.Limit{}, fmt.Errorf("error environment variable %s is not a rate limit: %s", env, raw)
+	}
+
+	return limit, nil
+}
+
+// idempotencyService is the part of the service that remembers the responses to retried requests
+type idempotencyService interface {
+	BeginIdempotentRequest(ctx context.Context, scope string, key string, request string) (*service.IdempotentResponse, error)
+	CompleteIdempotentRequest(ctx context.Context, scope string, key string, response service.IdempotentResponse) error
+	AbandonIdempotentRequest(ctx context.Context, scope string, key string) error
+}
+
+// idempotencyStore adapts the service's idempotency keys to the api package's IdempotencyStore
+type idempotencyStore struct {
+	idempotencyService
+}
+
+func (s idempotencyStore) BeginIdempotentRequest(ctx context.Context, scope string, key string, request string) (*api.IdempotentResponse, error) {
+	replay, err := s.idempotencyService.BeginIdempotentRequest(ctx, scope, key, request)
 	if err != nil {
-		return api.ServerError(fmt.Errorf("error creating poll bote: %s", err))
+		if err == service.ErrIdempotencyKeyInUse {
+			return nil, api.ErrIdempotencyKeyInUse
+		}
+
+		if err == service.ErrIdempotencyKeyReused {
+			return nil, api.ErrIdempotencyKeyReused
+		}
+
+		return nil, err
+	}
+
+	if replay == nil {
+		return nil, nil
 	}
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusAccepted,
-		Headers: map[string]string{
-			"Access-Control-Allow-Origin": "*",
-		},
-	}, nil
+	response := api.IdempotentResponse(*replay)
+
+	return &response, nil
+}
+
+func (s idempotencyStore) CompleteIdempotentRequest(ctx context.Context, scope string, key string, response api.IdempotentResponse) error {
+	return s.idempotencyService.CompleteIdempotentRequest(ctx, scope, key, service.IdempotentResponse(response))
 }
 
 func main() {
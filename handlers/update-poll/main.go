@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/api"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/auth"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/service"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/validator"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ivs"
+)
+
+const _tableNameEnv = "POLL_TABLE_NAME"
+
+var db dynamodb.Client
+var ivsClient ivs.Client
+
+func init() {
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db = *dynamodb.NewFromConfig(sdkConfig)
+	ivsClient = *ivs.NewFromConfig(sdkConfig)
+}
+
+type updatePollRequest struct {
+	// Version is the version of the poll being edited, taken from the get poll endpoint
+	Version  int    `json:"version" validate:"min=0"`
+	Question string `json:"question" validate:"omitempty,max=100"`
+	// Options replace the poll's options in order, existing options are kept by including their ID
+	Options []updatePollOption `json:"options" validate:"omitempty,dive"`
+}
+
+type updatePollOption struct {
+	ID    string `json:"id" validate:"omitempty,uuid"`
+	Label string `json:"label" validate:"required,min=1,max=100"`
+	// Correct marks the right answer(s) to a quiz
+	Correct bool `json:"correct"`
+}
+
+type updatedPoll struct {
+	ID       string             `json:"id"`
+	Version  int                `json:"version"`
+	Question string             `json:"question"`
+	Options  []updatePollOption `json:"options"`
+}
+
+type updatePollResponse struct {
+	Data updatedPoll `json:"data"`
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollID, ok := request.PathParameters["id"]
+	if !ok {
+		return api.ClientError(http.StatusBadRequest, "path parameter 'id' required")
+	}
+
+	tableName, ok := os.LookupEnv(_tableNameEnv)
+	if !ok {
+		return api.ServerError(fmt.Errorf("error environment variable %s not set", _tableNameEnv))
+	}
+
+	validate, trans, err := validator.NewValidator("en")
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error creating validator: %s", err))
+	}
+
+	repo := repository.New(tableName, &db)
+	broadcaster := broadcast.New(&ivsClient)
+
+	svc := service.New(repo, broadcaster)
+
+	apiKey, err := auth.APIKey(request)
+	if err != nil {
+		return api.ClientError(http.StatusUnauthorized, "an api key is required")
+	}
+
+	creator, err := svc.AuthenticateCreator(ctx, apiKey)
+	if err != nil {
+		if err == service.ErrInvalidAPIKey {
+			return api.ClientError(http.StatusUnauthorized, "api key is not valid")
+		}
+
+		return api.ServerError(fmt.Errorf("error authenticating creator: %s", err))
+	}
+
+	if err := svc.AuthorizePoll(ctx, pollID, creator.ID); err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "poll not found")
+		}
+
+		if err == service.ErrNotChannelOwner {
+			return api.ClientError(http.StatusForbidden, "only the channel's owner can manage its polls")
+		}
+
+		return api.ServerError(fmt.Errorf("error authorizing creator: %s", err))
+	}
+
+	var updatePollReq updatePollRequest
+	if err := json.Unmarshal([]byte(request.Body), &updatePollReq); err != nil {
+		return api.ServerError(fmt.Errorf("error unmarshalling request body: %s", err))
+	}
+
+	if err := validate.Struct(updatePollReq); err != nil {
+		errMap := validator.ExtractErrorMap(trans, err)
+
+		jsonErrMap, err := json.Marshal(errMap)
+		if err != nil {
+			return api.ServerError(fmt.Errorf("error: %w", err))
+		}
+
+		return api.ClientError(http.StatusBadRequest, string(jsonErrMap))
+	}
+
+	edit := service.PollEdit{
+		Version:  updatePollReq.Version,
+		Question: updatePollReq.Question,
+	}
+
+	if updatePollReq.Options != nil {
+		edit.Options = []service.EditedOption{}
+		for _, o := range updatePollReq.Options {
+			edit.Options = append(edit.Options, service.EditedOption{
+				ID:      o.ID,
+				Label:   o.Label,
+				Correct: o.Correct,
+			})
+		}
+	}
+
+	poll, err := svc.EditPoll(ctx, pollID, edit)
+	if err != nil {
+		if err == service.ErrRecordNotFound {
+			return api.ClientError(http.StatusNotFound, "poll not found")
+		}
+
+		if err == service.ErrPollVersionConflict {
+			return api.ClientError(http.StatusConflict, "the poll has been changed since it was loaded, reload it and try again")
+		}
+
+		if err == service.ErrPollHasVotes {
+			return api.ClientError(http.StatusConflict, "polls cannot be edited once they have votes")
+		}
+
+		if err == service.ErrInvalidOptions {
+			return api.ClientError(http.StatusBadRequest, `{"options":"options must be the poll's own options or new ones, and scale and free-text polls cannot have any"}`)
+		}
+
+		if err == service.ErrInvalidCorrectOptions {
+			return api.ClientError(http.StatusBadRequest, `{"options":"quizzes need at least one correct option and other polls cannot have any"}`)
+		}
+
+		if err == service.ErrInvalidSelectionLimits {
+			return api.ClientError(http.StatusBadRequest, `{"options":"multi-select polls need at least as many options as maxSelections"}`)
+		}
+
+		return api.ServerError(fmt.Errorf("error editing poll: %s", err))
+	}
+
+	data := updatedPoll{
+		ID:       poll.ID,
+		Version:  poll.Version,
+		Question: poll.Question,
+		Options:  []updatePollOption{},
+	}
+
+	for _, o := range poll.Options {
+		data.Options = append(data.Options, updatePollOption{ID: o.ID, Label: o.Label, Correct: o.Correct})
+	}
+
+	res, err := json.Marshal(updatePollResponse{Data: data})
+	if err != nil {
+		return api.ServerError(fmt.Errorf("error marshalling poll response: %s", err))
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(res),
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                "application/json",
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
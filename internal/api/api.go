@@ -2,7 +2,10 @@ package api
 
 import (
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 )
@@ -29,3 +32,21 @@ func ClientError(status int, errorMessage string) (events.APIGatewayProxyRespons
 		StatusCode: status,
 	}, nil
 }
+
+// TooManyRequests tells the client to slow down, Retry-After is rounded up to whole seconds so
+// retrying straight away is never suggested
+func TooManyRequests(retryAfter time.Duration) (events.APIGatewayProxyResponse, error) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       http.StatusText(http.StatusTooManyRequests),
+		StatusCode: http.StatusTooManyRequests,
+		Headers: map[string]string{
+			"Retry-After":                 strconv.Itoa(seconds),
+			"Access-Control-Allow-Origin": "*",
+		},
+	}, nil
+}
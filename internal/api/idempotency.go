@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var ErrInvalidIdempotencyKey = errors.New("idempotency key must be at most 255 characters")
+var ErrIdempotencyKeyInUse = errors.New("a request with the idempotency key is still in progress")
+var ErrIdempotencyKeyReused = errors.New("idempotency key was used with a different request")
+
+const _maxIdempotencyKeyLength = 255
+
+// IdempotentResponse is the response to a request made with an idempotency key
+type IdempotentResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       string
+}
+
+// IdempotencyStore remembers the responses to requests made with an idempotency key. Begin returns
+// the response to replay when the key has already been used for the same request, otherwise nil,
+// or ErrIdempotencyKeyInUse or ErrIdempotencyKeyReused when the request can't go ahead.
+type IdempotencyStore interface {
+	BeginIdempotentRequest(ctx context.Context, scope string, key string, request string) (*IdempotentResponse, error)
+	CompleteIdempotentRequest(ctx context.Context, scope string, key string, response IdempotentResponse) error
+	AbandonIdempotentRequest(ctx context.Context, scope string, key string) error
+}
+
+// IdempotencyKey reads the Idempotency-Key header, it is empty when the client didn't send one
+func IdempotencyKey(request events.APIGatewayProxyRequest) (string, error) {
+	var key string
+	for name, value := range request.Headers {
+		if strings.EqualFold(name, "Idempotency-Key") {
+			key = strings.TrimSpace(value)
+		}
+	}
+
+	if len(key) > _maxIdempotencyKeyLength {
+		return "", ErrInvalidIdempotencyKey
+	}
+
+	return key, nil
+}
+
+// Idempotent makes a request at most once for each idempotency key in a scope, retries get the
+// original response back. Requests without an Idempotency-Key header are always made. Server errors
+// and rate limited requests aren't remembered so they can be retried with the same key.
+func Idempotent(ctx context.Context, store IdempotencyStore, scope string, request events.APIGatewayProxyRequest, next func() (events.APIGatewayProxyResponse, error)) (events.APIGatewayProxyResponse, error) {
+	key, err := IdempotencyKey(request)
+	if err != nil {
+		return ClientError(http.StatusBadRequest, "Idempotency-Key header must be at most 255 characters")
+	}
+
+	if key == "" {
+		return next()
+	}
+
+	replay, err := store.BeginIdempotentRequest(ctx, scope, key, request.Body)
+	if err != nil {
+		if err == ErrIdempotencyKeyInUse {
+			return ClientError(http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+		}
+
+		if err == ErrIdempotencyKeyReused {
+			return ClientError(http.StatusUnprocessableEntity, "Idempotency-Key has already been used for a different request")
+		}
+
+		return ServerError(fmt.Errorf("error beginning idempotent request: %s", err))
+	}
+
+	if replay != nil {
+		headers := map[string]string{"Idempotent-Replayed": "true"}
+		for name, value := range replay.Headers {
+			headers[name] = value
+		}
+
+		return events.APIGatewayProxyResponse{
+			Body:       replay.Body,
+			StatusCode: replay.StatusCode,
+			Headers:    headers,
+		}, nil
+	}
+
+	res, err := next()
+	if err != nil || res.StatusCode >= http.StatusInternalServerError || res.StatusCode == http.StatusTooManyRequests {
+		if err := store.AbandonIdempotentRequest(ctx, scope, key); err != nil {
+			log.Printf("error abandoning idempotent request: %s", err)
+		}
+
+		return res, err
+	}
+
+	if err := store.CompleteIdempotentRequest(ctx, scope, key, IdempotentResponse{
+		StatusCode: res.StatusCode,
+		Headers:    res.Headers,
+		Body:       res.Body,
+	}); err != nil {
+		// the request has been made so its response is still returned, a retry will be told the key
+		// is in use until the key is treated as stale
+		log.Printf("error completing idempotent request: %s", err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var ErrNoSigningKeys = errors.New("key set does not contain any RSA signing keys")
+
+type jsonWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// ParseJWKS reads the RSA signing keys from a JSON Web Key Set, keyed by their key ID. Keys for
+// encryption or other algorithms are skipped.
+func ParseJWKS(raw []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("unmarshalling key set: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.KeyType != "RSA" || k.Use == "enc" || (k.Algorithm != "" && k.Algorithm != "RS256") {
+			continue
+		}
+
+		key, err := parseRSAKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("parsing key %s: %w", k.KeyID, err)
+		}
+
+		keys[k.KeyID] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoSigningKeys
+	}
+
+	return keys, nil
+}
+
+func parseRSAKey(k jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.Modulus)
+	if err != nil {
+		return nil, fmt.Errorf("decoding modulus: %w", err)
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(k.Exponent)
+	if err != nil {
+		return nil, fmt.Errorf("decoding exponent: %w", err)
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("exponent is out of range")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrMissingToken = errors.New("request does not have a bearer token")
+var ErrInvalidToken = errors.New("token is malformed or its signature is not valid")
+var ErrTokenExpired = errors.New("token has expired or is not valid yet")
+var ErrUnsupportedAlgorithm = errors.New("token is signed with an algorithm that is not accepted")
+
+// tokens are allowed to be this far either side of their validity window to cope with the
+// issuer's clock drifting from ours
+const _clockSkew = 30 * time.Second
+
+// Claims are the registered JWT claims the service relies on, Subject is the viewer's user ID and
+// Tier is the viewer's tier set by the issuer, e.g. subscriber
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Tier      string   `json:"tier"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+}
+
+// audience can be a single string or a list of strings in a token
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+
+	*a = many
+
+	return nil
+}
+
+func (a audience) contains(aud string) bool {
+	for _, candidate := range a {
+		if candidate == aud {
+			return true
+		}
+	}
+
+	return false
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Verifier checks the signature and claims of JWTs. Tokens can only use an algorithm that a key
+// has been configured for, so an HS256 token can never be verified against an RSA public key.
+type Verifier struct {
+	hmacSecret []byte
+	rsaKeys    map[string]*rsa.PublicKey
+	issuer     string
+	audience   string
+	now        func() time.Time
+}
+
+type Option func(*Verifier)
+
+// WithHMACSecret accepts HS256 tokens signed with the shared secret
+func WithHMACSecret(secret []byte) Option {
+	return func(v *Verifier) {
+		v.hmacSecret = secret
+	}
+}
+
+// WithRSAKeys accepts RS256 tokens signed by any of the keys, keyed by their key ID
+func WithRSAKeys(keys map[string]*rsa.PublicKey) Option {
+	return func(v *Verifier) {
+		for kid, key := range keys {
+			v.rsaKeys[kid] = key
+		}
+	}
+}
+
+// WithIssuer only accepts tokens from the given issuer
+func WithIssuer(issuer string) Option {
+	return func(v *Verifier) {
+		v.issuer = issuer
+	}
+}
+
+// WithAudience only accepts tokens issued for the given audience
+func WithAudience(audience string) Option {
+	return func(v *Verifier) {
+		v.audience = audience
+	}
+}
+
+// WithClock replaces the clock used to check whether tokens have expired
+func WithClock(now func() time.Time) Option {
+	return func(v *Verifier) {
+		v.now = now
+	}
+}
+
+func NewVerifier(opts ...Option) *Verifier {
+	v := &Verifier{
+		rsaKeys: make(map[string]*rsa.PublicKey),
+		now:     time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// Verify checks a compact serialised JWT and returns its claims. Tokens must have a subject and an
+// expiry.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	if err := v.verifySignature(h, parts[0]+"."+parts[1], signature); err != nil {
+		return Claims{}, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	if claims.Subject == "" || claims.ExpiresAt == 0 {
+		return Claims{}, ErrInvalidToken
+	}
+
+	now := v.now()
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(_clockSkew)) {
+		return Claims{}, ErrTokenExpired
+	}
+
+	if claims.NotBefore != 0 && now.Add(_clockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return Claims{}, ErrTokenExpired
+	}
+
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return Claims{}, ErrInvalidToken
+	}
+
+	if v.audience != "" && !claims.Audience.contains(v.audience) {
+		return Claims{}, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) verifySignature(h header, signed string, signature []byte) error {
+	switch h.Algorithm {
+	case "HS256":
+		if len(v.hmacSecret) == 0 {
+			return ErrUnsupportedAlgorithm
+		}
+
+		mac := hmac.New(sha256.New, v.hmacSecret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrInvalidToken
+		}
+
+		return nil
+	case "RS256":
+		if len(v.rsaKeys) == 0 {
+			return ErrUnsupportedAlgorithm
+		}
+
+		key := v.rsaKey(h.KeyID)
+		if key == nil {
+			return ErrInvalidToken
+		}
+
+		digest := sha256.Sum256([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return ErrInvalidToken
+		}
+
+		return nil
+	default:
+		return ErrUnsupportedAlgorithm
+	}
+}
+
+// rsaKey finds the key a token was signed with, tokens without a key ID can only be verified when
+// there is just one key to choose from
+func (v *Verifier) rsaKey(kid string) *rsa.PublicKey {
+	if kid != "" {
+		return v.rsaKeys[kid]
+	}
+
+	if len(v.rsaKeys) == 1 {
+		for _, key := range v.rsaKeys {
+			return key
+		}
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, out)
+}
@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var _now = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshalling segment: %v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func signHS256(t *testing.T, secret []byte, claims map[string]interface{}) string {
+	t.Helper()
+
+	signed := encodeSegment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	signed := encodeSegment(t, map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid}) + "." + encodeSegment(t, claims)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":  "user-1",
+		"tier": "subscriber",
+		"iss":  "https://auth.example.com",
+		"aud":  []string{"polls"},
+		"iat":  _now.Add(-time.Minute).Unix(),
+		"exp":  _now.Add(time.Hour).Unix(),
+	}
+}
+
+func jwksFor(t *testing.T, kid string, key *rsa.PublicKey) []byte {
+	t.Helper()
+
+	raw, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("marshalling key set: %v", err)
+	}
+
+	return raw
+}
+
+func TestVerifyHS256(t *testing.T) {
+	secret := []byte("local-development-secret")
+	verifier := NewVerifier(
+		WithHMACSecret(secret),
+		WithIssuer("https://auth.example.com"),
+		WithAudience("polls"),
+		WithClock(func() time.Time { return _now }),
+	)
+
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr error
+	}{
+		{
+			name:  "valid token",
+			token: func() string { return signHS256(t, secret, validClaims()) },
+		},
+		{
+			name:    "wrong secret",
+			token:   func() string { return signHS256(t, []byte("another-secret"), validClaims()) },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "expired",
+			token: func() string {
+				claims := validClaims()
+				claims["exp"] = _now.Add(-time.Minute).Unix()
+				return signHS256(t, secret, claims)
+			},
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name: "expired within the allowed clock skew",
+			token: func() string {
+				claims := validClaims()
+				claims["exp"] = _now.Add(-10 * time.Second).Unix()
+				return signHS256(t, secret, claims)
+			},
+		},
+		{
+			name: "not valid yet",
+			token: func() string {
+				claims := validClaims()
+				claims["nbf"] = _now.Add(time.Minute).Unix()
+				return signHS256(t, secret, claims)
+			},
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name: "no expiry",
+			token: func() string {
+				claims := validClaims()
+				delete(claims, "exp")
+				return signHS256(t, secret, claims)
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "no subject",
+			token: func() string {
+				claims := validClaims()
+				delete(claims, "sub")
+				return signHS256(t, secret, claims)
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				claims := validClaims()
+				claims["iss"] = "https://somebody-else.example.com"
+				return signHS256(t, secret, claims)
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "single audience string",
+			token: func() string {
+				claims := validClaims()
+				claims["aud"] = "polls"
+				return signHS256(t, secret, claims)
+			},
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				claims := validClaims()
+				claims["aud"] = []string{"chat"}
+				return signHS256(t, secret, claims)
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "unsigned",
+			token: func() string {
+				return encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, validClaims()) + "."
+			},
+			wantErr: ErrUnsupportedAlgorithm,
+		},
+		{
+			name:    "malformed",
+			token:   func() string { return "not-a-token" },
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(tt.token())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr == nil && claims.Subject != "user-1" {
+				t.Errorf("expected subject user-1, got %s", claims.Subject)
+			}
+
+			if tt.wantErr == nil && claims.Tier != "subscriber" {
+				t.Errorf("expected tier subscriber, got %s", claims.Tier)
+			}
+		})
+	}
+}
+
+func TestVerifyRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	keys, err := ParseJWKS(jwksFor(t, "key-1", &key.PublicKey))
+	if err != nil {
+		t.Fatalf("parsing key set: %v", err)
+	}
+
+	verifier := NewVerifier(WithRSAKeys(keys), WithClock(func() time.Time { return _now }))
+
+	if claims, err := verifier.Verify(signRS256(t, key, "key-1", validClaims())); err != nil || claims.Subject != "user-1" {
+		t.Fatalf("expected the token to be verified, got %v", err)
+	}
+
+	if _, err := verifier.Verify(signRS256(t, key, "", validClaims())); err != nil {
+		t.Errorf("expected a token without a key ID to use the only key, got %v", err)
+	}
+
+	if _, err := verifier.Verify(signRS256(t, other, "key-1", validClaims())); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected a token signed by another key to be rejected, got %v", err)
+	}
+
+	if _, err := verifier.Verify(signRS256(t, key, "key-2", validClaims())); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected a token with an unknown key ID to be rejected, got %v", err)
+	}
+
+	// an HS256 token signed with the public key must not be accepted when only RSA keys are configured
+	if _, err := verifier.Verify(signHS256(t, key.PublicKey.N.Bytes(), validClaims())); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("expected an HS256 token to be rejected, got %v", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	secret := []byte("local-development-secret")
+	verifier := NewVerifier(WithHMACSecret(secret), WithClock(func() time.Time { return _now }))
+	token := signHS256(t, secret, validClaims())
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantErr error
+	}{
+		{
+			name:    "bearer token",
+			headers: map[string]string{"Authorization": "Bearer " + token},
+		},
+		{
+			name:    "lower case header and scheme",
+			headers: map[string]string{"authorization": "bearer " + token},
+		},
+		{
+			name:    "no header",
+			headers: map[string]string{},
+			wantErr: ErrMissingToken,
+		},
+		{
+			name:    "basic auth",
+			headers: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+			wantErr: ErrMissingToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Authenticate(events.APIGatewayProxyRequest{Headers: tt.headers})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr == nil && claims.Subject != "user-1" {
+				t.Errorf("expected subject user-1, got %s", claims.Subject)
+			}
+		})
+	}
+}
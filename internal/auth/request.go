@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var ErrNotConfigured = errors.New("no token signing keys have been configured")
+var ErrMissingAPIKey = errors.New("request does not have an api key")
+
+const (
+	// _hmacSecretEnv is a shared secret for verifying HS256 tokens
+	_hmacSecretEnv = "AUTH_HS256_SECRET"
+	// _jwksFileEnv is the path to a JSON Web Key Set file for verifying RS256 tokens
+	_jwksFileEnv = "AUTH_JWKS_FILE"
+	_issuerEnv   = "AUTH_ISSUER"
+	_audienceEnv = "AUTH_AUDIENCE"
+)
+
+// NewVerifierFromEnv configures a Verifier from the environment, at least one of an HS256 secret
+// or a JWKS file has to be set
+func NewVerifierFromEnv() (*Verifier, error) {
+	var opts []Option
+
+	if secret := os.Getenv(_hmacSecretEnv); secret != "" {
+		opts = append(opts, WithHMACSecret([]byte(secret)))
+	}
+
+	if path := os.Getenv(_jwksFileEnv); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading key set file: %w", err)
+		}
+
+		keys, err := ParseJWKS(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, WithRSAKeys(keys))
+	}
+
+	if len(opts) == 0 {
+		return nil, ErrNotConfigured
+	}
+
+	if issuer := os.Getenv(_issuerEnv); issuer != "" {
+		opts = append(opts, WithIssuer(issuer))
+	}
+
+	if audience := os.Getenv(_audienceEnv); audience != "" {
+		opts = append(opts, WithAudience(audience))
+	}
+
+	return NewVerifier(opts...), nil
+}
+
+// Authenticate verifies the bearer token in a request's Authorization header
+func (v *Verifier) Authenticate(request events.APIGatewayProxyRequest) (Claims, error) {
+	token, err := bearerToken(request.Headers)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	return v.Verify(token)
+}
+
+// HasToken checks whether a request has tried to authenticate, so endpoints that work without a
+// user can still reject bad tokens
+func HasToken(request events.APIGatewayProxyRequest) bool {
+	_, err := bearerToken(request.Headers)
+
+	return err == nil
+}
+
+// APIKey reads the API key creators send in the X-Api-Key header
+func APIKey(request events.APIGatewayProxyRequest) (string, error) {
+	apiKey := strings.TrimSpace(headerValue(request.Headers, "X-Api-Key"))
+	if apiKey == "" {
+		return "", ErrMissingAPIKey
+	}
+
+	return apiKey, nil
+}
+
+// bearerToken reads the token from an Authorization header
+func bearerToken(headers map[string]string) (string, error) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(headerValue(headers, "Authorization")), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", ErrMissingToken
+	}
+
+	return strings.TrimSpace(token), nil
+}
+
+// headerValue finds a header regardless of case as API Gateway passes them through as the client sent
+// them
+func headerValue(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+
+	return ""
+}
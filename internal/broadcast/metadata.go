@@ -1,17 +1,36 @@
 package broadcast
 
-const _envelopeType = "poll"
 const _envelopeVersion = "2022-06-05"
 
+// EnvelopeType tells players what kind of data a metadata envelope carries
+type EnvelopeType string
+
+const (
+	EnvelopePoll               EnvelopeType = "poll"
+	EnvelopePollOpened         EnvelopeType = "poll-opened"
+	EnvelopePollUpdated        EnvelopeType = "poll-updated"
+	EnvelopePollDefinition     EnvelopeType = "poll-definition"
+	EnvelopePollClosed         EnvelopeType = "poll-closed"
+	EnvelopePollRunoff         EnvelopeType = "poll-runoff"
+	EnvelopeQuizAnswer         EnvelopeType = "quiz-answer"
+	EnvelopeLeaderboard        EnvelopeType = "leaderboard"
+	EnvelopeWordCloud          EnvelopeType = "word-cloud"
+	EnvelopePredictionResolved EnvelopeType = "prediction-resolved"
+)
+
 type Metadata struct {
-	Type    string      `json:"type"`
-	Version string      `json:"version"`
-	Data    interface{} `json:"data"`
+	Type    EnvelopeType `json:"type"`
+	Version string       `json:"version"`
+	Data    interface{}  `json:"data"`
 }
 
 func CreateMetadata(data interface{}) Metadata {
+	return CreateTypedMetadata(EnvelopePoll, data)
+}
+
+func CreateTypedMetadata(envelopeType EnvelopeType, data interface{}) Metadata {
 	return Metadata{
-		Type:    _envelopeType,
+		Type:    envelopeType,
 		Version: _envelopeVersion,
 		Data:    data,
 	}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// a bucket that keeps changing between reading and writing it is being hammered, after this many
+// attempts the request is turned away rather than retried again
+const _maxAttempts = 3
+
+// _contendedRetryAfter is how long a request turned away because of contention is asked to wait
+const _contendedRetryAfter = time.Second
+
+type databaseBucket struct {
+	PK       string  `dynamodbav:"PK"`
+	SK       string  `dynamodbav:"SK"`
+	ItemType string  `dynamodbav:"itemType"`
+	Tokens   float64 `dynamodbav:"tokens"`
+	// UpdatedAt is in milliseconds since the epoch, it is also used to detect concurrent updates
+	UpdatedAt int64 `dynamodbav:"updatedAt"`
+	// ExpiresAt is when the bucket would be full again, after which it can be removed by the TTL
+	ExpiresAt int64 `dynamodbav:"expiresAt"`
+}
+
+type dynamoLimiter struct {
+	tableName *string
+	db        *dynamodb.Client
+	now       func() time.Time
+}
+
+// NewDynamoDB keeps buckets as items in the table so every Lambda instance shares them
+func NewDynamoDB(tableName string, db *dynamodb.Client) *dynamoLimiter {
+	return &dynamoLimiter{
+		tableName: aws.String(tableName),
+		db:        db,
+		now:       time.Now,
+	}
+}
+
+func buildBucketDatabaseKey(key string) string {
+	return fmt.Sprintf("RATELIMIT#%s", key)
+}
+
+func (d *dynamoLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	for attempt := 0; attempt < _maxAttempts; attempt++ {
+		current, err := d.getBucket(ctx, key)
+		if err != nil {
+			return Result{}, err
+		}
+
+		now := d.now()
+
+		var previous *bucket
+		var previousUpdatedAt int64
+		if current != nil {
+			previous = &bucket{Tokens: current.Tokens, UpdatedAt: time.UnixMilli(current.UpdatedAt)}
+			previousUpdatedAt = current.UpdatedAt
+		}
+
+		next, result := limit.take(previous, now)
+		if !result.Allowed {
+			// nothing was taken so there is nothing to save
+			return result, nil
+		}
+
+		err = d.putBucket(ctx, key, next, limit, current != nil, previousUpdatedAt)
+		if err == nil {
+			return result, nil
+		}
+
+		var conditionErr *types.ConditionalCheckFailedException
+		if !errors.As(err, &conditionErr) {
+			return Result{}, fmt.Errorf("calling PutItem for rate limit bucket: %w", err)
+		}
+	}
+
+	return Result{RetryAfter: _contendedRetryAfter}, nil
+}
+
+func (d *dynamoLimiter) getBucket(ctx context.Context, key string) (*databaseBucket, error) {
+	pk := buildBucketDatabaseKey(key)
+
+	input := &dynamodb.GetItemInput{
+		TableName: d.tableName,
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: pk,
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: pk,
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	result, err := d.db.GetItem(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("calling GetItem for rate limit bucket: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var b databaseBucket
+	if err := attributevalue.UnmarshalMap(result.Item, &b); err != nil {
+		return nil, fmt.Errorf("unmarshalling rate limit bucket: %w", err)
+	}
+
+	return &b, nil
+}
+
+// putBucket saves the bucket as long as nobody else has changed it since it was read
+func (d *dynamoLimiter) putBucket(ctx context.Context, key string, b bucket, limit Limit, exists bool, previousUpdatedAt int64) error {
+	pk := buildBucketDatabaseKey(key)
+
+	refill := time.Duration((float64(limit.Requests) - b.Tokens) / limit.refillRate() * float64(time.Second))
+
+	item, err := attributevalue.MarshalMap(databaseBucket{
+		PK:        pk,
+		SK:        pk,
+		ItemType:  "RateLimit",
+		Tokens:    b.Tokens,
+		UpdatedAt: b.UpdatedAt.UnixMilli(),
+		ExpiresAt: b.UpdatedAt.Add(refill).Add(time.Minute).Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling rate limit bucket: %w", err)
+	}
+
+	condition := expression.AttributeNotExists(expression.Name("PK"))
+	if exists {
+		condition = expression.Name("updatedAt").Equal(expression.Value(previousUpdatedAt))
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                 d.tableName,
+		Item:                      item,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	_, err = d.db.PutItem(ctx, input)
+
+	return err
+}
@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+	now     func() time.Time
+}
+
+// NewMemory keeps buckets in memory, it is only suitable for tests and local development as each
+// Lambda instance would otherwise have its own buckets
+func NewMemory(now func() time.Time) *memoryLimiter {
+	return &memoryLimiter{
+		buckets: make(map[string]bucket),
+		now:     now,
+	}
+}
+
+func (m *memoryLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var current *bucket
+	if b, ok := m.buckets[key]; ok {
+		current = &b
+	}
+
+	next, result := limit.take(current, m.now())
+	m.buckets[key] = next
+
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("limit must look like 10/1m")
+
+// Limit lets Requests through every Per. Requests can be made in a burst as long as there are
+// enough left in the bucket, which refills evenly over Per.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit reads a limit written as requests/duration, e.g. 10/1m or 100/30s
+func ParseLimit(s string) (Limit, error) {
+	rawRequests, rawPer, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, ErrInvalidLimit
+	}
+
+	requests, err := strconv.Atoi(rawRequests)
+	if err != nil {
+		return Limit{}, ErrInvalidLimit
+	}
+
+	per, err := time.ParseDuration(rawPer)
+	if err != nil {
+		return Limit{}, ErrInvalidLimit
+	}
+
+	limit := Limit{Requests: requests, Per: per}
+	if !limit.IsValid() {
+		return Limit{}, ErrInvalidLimit
+	}
+
+	return limit, nil
+}
+
+func (l Limit) IsValid() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// Result says whether a request was let through, and if it wasn't how long until it would be
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter takes a token from the bucket for key each time a request is allowed
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// bucket holds the tokens left for a key as of UpdatedAt, it has to be refilled up to now before
+// it is used
+type bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+func (l Limit) refillRate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// take refills the bucket for the time that has passed and takes a token out of it if there is
+// one. A missing bucket starts full.
+func (l Limit) take(b *bucket, now time.Time) (bucket, Result) {
+	tokens := float64(l.Requests)
+	if b != nil {
+		elapsed := now.Sub(b.UpdatedAt).Seconds()
+		if elapsed < 0 {
+			elapsed = 0
+		}
+
+		tokens = math.Min(float64(l.Requests), b.Tokens+elapsed*l.refillRate())
+	}
+
+	if tokens < 1 {
+		wait := (1 - tokens) / l.refillRate()
+
+		return bucket{Tokens: tokens, UpdatedAt: now}, Result{
+			RetryAfter: time.Duration(math.Ceil(wait * float64(time.Second))),
+		}
+	}
+
+	tokens--
+
+	return bucket{Tokens: tokens, UpdatedAt: now}, Result{
+		Allowed:   true,
+		Remaining: int(tokens),
+	}
+}
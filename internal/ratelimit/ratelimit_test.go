@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "10/1m", want: Limit{Requests: 10, Per: time.Minute}},
+		{in: " 5/30s ", want: Limit{Requests: 5, Per: 30 * time.Second}},
+		{in: "10", wantErr: true},
+		{in: "ten/1m", wantErr: true},
+		{in: "10/minute", wantErr: true},
+		{in: "0/1m", wantErr: true},
+		{in: "10/0s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLimit(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewMemory(func() time.Time { return now })
+	limit := Limit{Requests: 3, Per: 3 * time.Second}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow(ctx, "user", limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("expected request %d to be allowed with %d remaining, got %+v", i, 2-i, result)
+		}
+	}
+
+	result, _ := limiter.Allow(ctx, "user", limit)
+	if result.Allowed || result.RetryAfter != time.Second {
+		t.Fatalf("expected the burst to be exhausted with a second to wait, got %+v", result)
+	}
+
+	if result, _ := limiter.Allow(ctx, "another-user", limit); !result.Allowed {
+		t.Errorf("expected other keys to have their own bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if result, _ := limiter.Allow(ctx, "user", limit); result.Allowed || result.RetryAfter != 500*time.Millisecond {
+		t.Errorf("expected half a token to have been refilled, got %+v", result)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if result, _ := limiter.Allow(ctx, "user", limit); !result.Allowed {
+		t.Errorf("expected a token to have been refilled after a second, got %+v", result)
+	}
+
+	now = now.Add(time.Hour)
+	if result, _ := limiter.Allow(ctx, "user", limit); !result.Allowed || result.Remaining != 2 {
+		t.Errorf("expected the bucket to refill no further than its size, got %+v", result)
+	}
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+var ErrPointsAlreadyAwarded = errors.New("user has already been awarded points for the poll")
+
+// DatabasePointsAward records that a viewer has been awarded their points for a poll, so awarding
+// points again after a failure part way through a poll's voters can skip the ones already done
+type DatabasePointsAward struct {
+	PK       string `dynamodbav:"PK"`
+	SK       string `dynamodbav:"SK"`
+	ItemType string `dynamodbav:"itemType"`
+	// ExpiresAt is the TTL of the award in seconds since the epoch, it is kept for as long as its poll
+	ExpiresAt int64 `dynamodbav:"expiresAt,omitempty"`
+}
+
+func buildPointsAwardDatabaseKey(userID string) string {
+	return fmt.Sprintf("AWARD#%s", userID)
+}
+
+// awardPoints applies an update to a viewer's points in the same transaction as recording that they
+// have been awarded points for the poll. It fails with ErrPointsAlreadyAwarded if they already have.
+func (r *repo) awardPoints(ctx context.Context, pollID string, userID string, expiresAt *time.Time, update types.Update) error {
+	award := DatabasePointsAward{
+		PK:       buildPollDatabaseKey(pollID),
+		SK:       buildPointsAwardDatabaseKey(userID),
+		ItemType: "PointsAward",
+	}
+
+	if expiresAt != nil {
+		award.ExpiresAt = expiresAt.Unix()
+	}
+
+	item, err := attributevalue.MarshalMap(award)
+	if err != nil {
+		return fmt.Errorf("marshalling points award: %w", err)
+	}
+
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.AttributeNotExists(expression.Name("PK"))).
+		Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:                r.tableName,
+					Item:                     item,
+					ConditionExpression:      expr.Condition(),
+					ExpressionAttributeNames: expr.Names(),
+				},
+			},
+			{Update: &update},
+		},
+	}
+
+	if _, err := r.db.TransactWriteItems(ctx, input); err != nil {
+		var cancelledErr *types.TransactionCanceledException
+		if errors.As(err, &cancelledErr) && len(cancelledErr.CancellationReasons) > 0 &&
+			aws.StringValue(cancelledErr.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return ErrPointsAlreadyAwarded
+		}
+
+		return fmt.Errorf("calling TransactWriteItems for points award: %w", err)
+	}
+
+	return nil
+}
+
+// CompletePollAwards records that every voter on a poll has been awarded their points
+func (r *repo) CompletePollAwards(ctx context.Context, id string, awardedAt time.Time) error {
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("awardedAt"), expression.Value(formatTimestamp(awardedAt)))).
+		WithCondition(expression.AttributeExists(expression.Name("PK"))).
+		Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 r.tableName,
+		Key:                       buildPollItemKey(id),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	if _, err := r.db.UpdateItem(ctx, input); err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrPollNotFound
+		}
+
+		return fmt.Errorf("calling UpdateItem for poll awards: %w", err)
+	}
+
+	return nil
+}
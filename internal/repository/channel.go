@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// ListPollsByChannel pages through a channel's polls from newest to oldest, optionally only
+// including polls in one of the given statuses. The status filter is applied after each page is
+// read so a page can hold fewer than limit polls even when there are more to come. The returned
+// cursor is empty once the last page has been read.
+func (r *repo) ListPollsByChannel(ctx context.Context, channelARN string, statuses []string, limit int, cursor string) ([]DatabasePoll, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	builder := expression.NewBuilder().
+		WithKeyCondition(expression.Key("channelARN").Equal(expression.Value(channelARN)))
+
+	if len(statuses) > 0 {
+		var others []expression.OperandBuilder
+		for _, s := range statuses[1:] {
+			others = append(others, expression.Value(s))
+		}
+
+		builder = builder.WithFilter(expression.Name("status").In(expression.Value(statuses[0]), others...))
+	}
+
+	expr, err := builder.Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 r.tableName,
+		IndexName:                 aws.String(_channelPollsIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(int32(limit)),
+		ExclusiveStartKey:         startKey,
+	}
+
+	res, err := r.db.Query(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("querying channel polls: %w", err)
+	}
+
+	var polls []DatabasePoll
+	if err := attributevalue.UnmarshalListOfMaps(res.Items, &polls); err != nil {
+		return nil, "", fmt.Errorf("unmarshalling channel polls: %w", err)
+	}
+
+	nextCursor, err := encodeCursor(res.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return polls, nextCursor, nil
+}
+
+// ListActivePolls finds every open poll on a channel, most recently opened first. Polls opened
+// before the active polls index was added are not included.
+func (r *repo) ListActivePolls(ctx context.Context, channelARN string) ([]DatabasePoll, error) {
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key("activeChannel").Equal(expression.Value(channelARN))).
+		Build()
+	if err != nil {
+		return nil, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 r.tableName,
+		IndexName:                 aws.String(_activePollsIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(false),
+	}
+
+	var polls []DatabasePoll
+	paginator := dynamodb.NewQueryPaginator(r.db, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("querying active polls: %w", err)
+		}
+
+		var pagePolls []DatabasePoll
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pagePolls); err != nil {
+			return nil, fmt.Errorf("unmarshalling active polls: %w", err)
+		}
+
+		polls = append(polls, pagePolls...)
+	}
+
+	return polls, nil
+}
+
+// ClaimDefinitionBroadcast records that an open poll's full definition is being re-broadcast, as
+// long as it hasn't been since the given time. ok is false when the poll isn't open or the
+// definition was broadcast more recently, so concurrent callers can't both broadcast it.
+func (r *repo) ClaimDefinitionBroadcast(ctx context.Context, id string, now time.Time, since time.Time) (poll DatabasePoll, ok bool, err error) {
+	return r.claimBroadcast(ctx, id, "definitionBroadcastAt", now, since)
+}
+
+// ClaimRunoffBroadcast records that the instant-runoff results of an open ranked-choice poll are
+// being broadcast, as long as they haven't been since the given time
+func (r *repo) ClaimRunoffBroadcast(ctx context.Context, id string, now time.Time, since time.Time) (poll DatabasePoll, ok bool, err error) {
+	return r.claimBroadcast(ctx, id, "runoffBroadcastAt", now, since)
+}
+
+// claimBroadcast sets the time a broadcast was last sent for an open poll, unless it was sent more
+// recently than since
+func (r *repo) claimBroadcast(ctx context.Context, id string, attribute string, now time.Time, since time.Time) (poll DatabasePoll, ok bool, err error) {
+	dueCondition := expression.AttributeNotExists(expression.Name(attribute)).
+		Or(expression.Name(attribute).LessThanEqual(expression.Value(formatTimestamp(since))))
+
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name(attribute), expression.Value(formatTimestamp(now)))).
+		WithCondition(expression.Name("status").Equal(expression.Value(PollStatusOpen)).And(dueCondition)).
+		Build()
+	if err != nil {
+		return DatabasePoll{}, false, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 r.tableName,
+		Key:                       buildPollItemKey(id),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	res, err := r.db.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return DatabasePoll{}, false, nil
+		}
+
+		return DatabasePoll{}, false, fmt.Errorf("calling UpdateItem for %s: %w", attribute, err)
+	}
+
+	if err := attributevalue.UnmarshalMap(res.Attributes, &poll); err != nil {
+		return DatabasePoll{}, false, fmt.Errorf("unmarshalling poll item: %w", err)
+	}
+
+	return poll, true, nil
+}
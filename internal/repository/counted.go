@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+var ErrVoteChangeAlreadyCounted = errors.New("vote change has already been counted")
+
+// DynamoDB accepts at most 25 items in a single TransactWriteItems call
+const _maxTransactItems = 25
+
+// CountedVoteChanges identify the changes to votes behind an increment. They are recorded in the
+// same transaction as the increment so a change redelivered by the vote stream is never counted
+// twice.
+type CountedVoteChanges struct {
+	IDs []string
+	// ExpiresAt is when the record of the changes is removed, it must outlive the vote stream's
+	// retention
+	ExpiresAt time.Time
+}
+
+// DatabaseCountedVoteChange records that a change to a vote has been counted in its poll's totals
+type DatabaseCountedVoteChange struct {
+	PK       string `dynamodbav:"PK"`
+	SK       string `dynamodbav:"SK"`
+	ItemType string `dynamodbav:"itemType"`
+	// ExpiresAt is the TTL of the record in seconds since the epoch
+	ExpiresAt int64 `dynamodbav:"expiresAt"`
+}
+
+func buildCountedVoteChangeDatabaseKey(changeID string) string {
+	return fmt.Sprintf("COUNTED#%s", changeID)
+}
+
+// ListCountedVoteChanges returns which of the changes to votes on a poll have already been counted
+func (r *repo) ListCountedVoteChanges(ctx context.Context, pollID string, changeIDs []string) ([]string, error) {
+	var counted []string
+
+	for start := 0; start < len(changeIDs); start += _maxBatchGetItems {
+		end := start + _maxBatchGetItems
+		if end > len(changeIDs) {
+			end = len(changeIDs)
+		}
+
+		var keys []map[string]types.AttributeValue
+		for _, id := range changeIDs[start:end] {
+			keys = append(keys, map[string]types.AttributeValue{
+				"PK": &types.AttributeValueMemberS{
+					Value: buildPollDatabaseKey(pollID),
+				},
+				"SK": &types.AttributeValueMemberS{
+					Value: buildCountedVoteChangeDatabaseKey(id),
+				},
+			})
+		}
+
+		requestItems := map[string]types.KeysAndAttributes{*r.tableName: {Keys: keys, ConsistentRead: aws.Bool(true)}}
+		for attempt := 1; len(requestItems) > 0; attempt++ {
+			if attempt > _maxBatchAttempts {
+				return nil, fmt.Errorf("counted vote changes were still unprocessed after %d attempts", _maxBatchAttempts)
+			}
+
+			if attempt > 1 {
+				time.Sleep(time.Duration(attempt-1) * _batchRetryBackoff)
+			}
+
+			res, err := r.db.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: requestItems})
+			if err != nil {
+				return nil, fmt.Errorf("calling BatchGetItem for counted vote changes: %w", err)
+			}
+
+			var found []DatabaseCountedVoteChange
+			if err := attributevalue.UnmarshalListOfMaps(res.Responses[*r.tableName], &found); err != nil {
+				return nil, fmt.Errorf("unmarshalling counted vote change items: %w", err)
+			}
+
+			for _, c := range found {
+				counted = append(counted, strings.TrimPrefix(c.SK, buildCountedVoteChangeDatabaseKey("")))
+			}
+
+			requestItems = res.UnprocessedKeys
+		}
+	}
+
+	return counted, nil
+}
+
+// RecordCountedVoteChanges records changes to votes that didn't move any of the poll's totals, such
+// as a vote being changed and changed back, so that part of them can't be counted later on
+func (r *repo) RecordCountedVoteChanges(ctx context.Context, pollID string, counted CountedVoteChanges) error {
+	return r.transactCountedVoteChanges(ctx, pollID, nil, counted)
+}
+
+// transactCountedVoteChanges writes updates to a poll's totals in the same transaction as recording
+// the changes to votes behind them. The transaction fails with ErrVoteChangeAlreadyCounted if any
+// of the changes have been counted before.
+func (r *repo) transactCountedVoteChanges(ctx context.Context, pollID string, updates []types.Update, counted CountedVoteChanges) error {
+	var items []types.TransactWriteItem
+	for i := range updates {
+		items = append(items, types.TransactWriteItem{Update: &updates[i]})
+	}
+
+	for _, id := range counted.IDs {
+		key := buildCountedVoteChangeDatabaseKey(id)
+
+		item, err := attributevalue.MarshalMap(DatabaseCountedVoteChange{
+			PK:        buildPollDatabaseKey(pollID),
+			SK:        key,
+			ItemType:  "CountedVoteChange",
+			ExpiresAt: counted.ExpiresAt.Unix(),
+		})
+		if err != nil {
+			return fmt.Errorf("marshalling counted vote change: %w", err)
+		}
+
+		expr, err := expression.NewBuilder().
+			WithCondition(expression.AttributeNotExists(expression.Name("PK"))).
+			Build()
+		if err != nil {
+			return fmt.Errorf("building expression: %w", err)
+		}
+
+		items = append(items, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:                r.tableName,
+				Item:                     item,
+				ConditionExpression:      expr.Condition(),
+				ExpressionAttributeNames: expr.Names(),
+			},
+		})
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	if len(items) > _maxTransactItems {
+		return fmt.Errorf("increment needs %d writes but a transaction allows %d", len(items), _maxTransactItems)
+	}
+
+	if _, err := r.db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items}); err != nil {
+		var cancelledErr *types.TransactionCanceledException
+		if errors.As(err, &cancelledErr) {
+			for i, reason := range cancelledErr.CancellationReasons {
+				if aws.StringValue(reason.Code) != "ConditionalCheckFailed" {
+					continue
+				}
+
+				if i < len(updates) {
+					// only the poll's totals are updated conditionally, on the answers existing
+					return ErrUnknownPollAnswer
+				}
+
+				return ErrVoteChangeAlreadyCounted
+			}
+		}
+
+		return fmt.Errorf("calling TransactWriteItems for poll totals: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrAPIKeyNotFound = errors.New("could not find api key")
+var ErrAPIKeyExists = errors.New("api key already exists")
+var ErrChannelOwnerNotFound = errors.New("channel does not have an owner")
+var ErrChannelAlreadyOwned = errors.New("channel is owned by another creator")
+
+// DatabaseAPIKey lets a creator call the endpoints that manage polls. Only a hash of the key is
+// stored, so the key itself can't be read back out of the table.
+type DatabaseAPIKey struct {
+	PK        string `dynamodbav:"PK"`
+	SK        string `dynamodbav:"SK"`
+	ItemType  string `dynamodbav:"itemType"`
+	CreatorID string `dynamodbav:"creatorId"`
+	CreatedAt string `dynamodbav:"createdAt"`
+}
+
+// DatabaseChannelOwner records which creator is allowed to run polls on a channel. The ARN is
+// stored as channel rather than channelARN so owners stay out of the channel polls index.
+type DatabaseChannelOwner struct {
+	PK        string `dynamodbav:"PK"`
+	SK        string `dynamodbav:"SK"`
+	ItemType  string `dynamodbav:"itemType"`
+	Channel   string `dynamodbav:"channel"`
+	OwnerID   string `dynamodbav:"ownerId"`
+	ClaimedAt string `dynamodbav:"claimedAt"`
+}
+
+func buildAPIKeyDatabaseKey(keyHash string) string {
+	return fmt.Sprintf("APIKEY#%s", keyHash)
+}
+
+func buildChannelDatabaseKey(channelARN string) string {
+	return fmt.Sprintf("CHANNEL#%s", channelARN)
+}
+
+func buildSingletonItemKey(pk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{
+			Value: pk,
+		},
+		"SK": &types.AttributeValueMemberS{
+			Value: pk,
+		},
+	}
+}
+
+// CreateAPIKey stores the hash of a new API key for a creator
+func (r *repo) CreateAPIKey(ctx context.Context, keyHash string, creatorID string, createdAt time.Time) error {
+	key := buildAPIKeyDatabaseKey(keyHash)
+
+	item, err := attributevalue.MarshalMap(DatabaseAPIKey{
+		PK:        key,
+		SK:        key,
+		ItemType:  "APIKey",
+		CreatorID: creatorID,
+		CreatedAt: formatTimestamp(createdAt),
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling api key: %w", err)
+	}
+
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.AttributeNotExists(expression.Name("PK"))).
+		Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                 r.tableName,
+		Item:                      item,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	if _, err := r.db.PutItem(ctx, input); err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrAPIKeyExists
+		}
+
+		return fmt.Errorf("calling PutItem for api key: %w", err)
+	}
+
+	return nil
+}
+
+// GetAPIKey looks up an API key by its hash
+func (r *repo) GetAPIKey(ctx context.Context, keyHash string) (DatabaseAPIKey, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: r.tableName,
+		Key:       buildSingletonItemKey(buildAPIKeyDatabaseKey(keyHash)),
+	}
+
+	result, err := r.db.GetItem(ctx, input)
+	if err != nil {
+		return DatabaseAPIKey{}, err
+	}
+
+	if result.Item == nil {
+		return DatabaseAPIKey{}, ErrAPIKeyNotFound
+	}
+
+	var apiKey DatabaseAPIKey
+	if err := attributevalue.UnmarshalMap(result.Item, &apiKey); err != nil {
+		return DatabaseAPIKey{}, fmt.Errorf("unmarshalling api key item: %w", err)
+	}
+
+	return apiKey, nil
+}
+
+func (r *repo) GetChannelOwner(ctx context.Context, channelARN string) (DatabaseChannelOwner, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: r.tableName,
+		Key:       buildSingletonItemKey(buildChannelDatabaseKey(channelARN)),
+	}
+
+	result, err := r.db.GetItem(ctx, input)
+	if err != nil {
+		return DatabaseChannelOwner{}, err
+	}
+
+	if result.Item == nil {
+		return DatabaseChannelOwner{}, ErrChannelOwnerNotFound
+	}
+
+	var owner DatabaseChannelOwner
+	if err := attributevalue.UnmarshalMap(result.Item, &owner); err != nil {
+		return DatabaseChannelOwner{}, fmt.Errorf("unmarshalling channel owner item: %w", err)
+	}
+
+	return owner, nil
+}
+
+// ClaimChannel makes the creator the owner of a channel nobody owns yet. Claiming a channel the
+// creator already owns leaves it as it was, so claims can safely be retried.
+func (r *repo) ClaimChannel(ctx context.Context, channelARN string, ownerID string, claimedAt time.Time) (DatabaseChannelOwner, error) {
+	key := buildChannelDatabaseKey(channelARN)
+
+	owner := DatabaseChannelOwner{
+		PK:        key,
+		SK:        key,
+		ItemType:  "ChannelOwner",
+		Channel:   channelARN,
+		OwnerID:   ownerID,
+		ClaimedAt: formatTimestamp(claimedAt),
+	}
+
+	item, err := attributevalue.MarshalMap(owner)
+	if err != nil {
+		return DatabaseChannelOwner{}, fmt.Errorf("marshalling channel owner: %w", err)
+	}
+
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.AttributeNotExists(expression.Name("PK"))).
+		Build()
+	if err != nil {
+		return DatabaseChannelOwner{}, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                 r.tableName,
+		Item:                      item,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	if _, err := r.db.PutItem(ctx, input); err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if !errors.As(err, &conditionErr) {
+			return DatabaseChannelOwner{}, fmt.Errorf("calling PutItem for channel owner: %w", err)
+		}
+
+		existing, err := r.GetChannelOwner(ctx, channelARN)
+		if err != nil {
+			return DatabaseChannelOwner{}, fmt.Errorf("getting channel owner: %w", err)
+		}
+
+		if existing.OwnerID != ownerID {
+			return DatabaseChannelOwner{}, ErrChannelAlreadyOwned
+		}
+
+		return existing, nil
+	}
+
+	return owner, nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrInvalidCursor = errors.New("pagination cursor is invalid")
+
+// encodeCursor turns a query's LastEvaluatedKey into an opaque string that can be handed to
+// clients. An empty string means there are no more pages.
+func encodeCursor(lastEvaluatedKey map[string]types.AttributeValue) (string, error) {
+	if len(lastEvaluatedKey) == 0 {
+		return "", nil
+	}
+
+	var key map[string]interface{}
+	if err := attributevalue.UnmarshalMap(lastEvaluatedKey, &key); err != nil {
+		return "", fmt.Errorf("unmarshalling last evaluated key: %w", err)
+	}
+
+	raw, err := json.Marshal(key)
+	if err != nil {
+		return "", fmt.Errorf("marshalling cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodeCursor turns a cursor from encodeCursor back into an ExclusiveStartKey
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var key map[string]interface{}
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	startKey, err := attributevalue.MarshalMap(key)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return startKey, nil
+}
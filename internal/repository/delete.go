@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// DynamoDB accepts at most 25 requests in a single BatchWriteItem call
+	_maxBatchWriteItems = 25
+	// unprocessed batch requests are retried a few times, backing off a little longer each time
+	_maxBatchAttempts  = 5
+	_batchRetryBackoff = 50 * time.Millisecond
+)
+
+// DeletePoll removes a poll along with every vote and term stored under it. The poll is marked as
+// deleting first so the vote removals that follow are ignored by the aggregator instead of being
+// counted as retractions, and the poll item itself goes last so a delete that fails part way
+// through can still be authorized and retried until nothing is left. ErrPollNotFound is only
+// returned once there was nothing to delete.
+func (r *repo) DeletePoll(ctx context.Context, id string, deletingAt time.Time) error {
+	found, err := r.markPollDeleting(ctx, id, deletingAt)
+	if err != nil {
+		return err
+	}
+
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key("PK").Equal(expression.Value(buildPollDatabaseKey(id)))).
+		WithProjection(expression.NamesList(expression.Name("PK"), expression.Name("SK"))).
+		Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 r.tableName,
+		KeyConditionExpression:    expr.KeyCondition(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	paginator := dynamodb.NewQueryPaginator(r.db, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("querying poll items: %w", err)
+		}
+
+		var children []map[string]types.AttributeValue
+		for _, key := range page.Items {
+			if sk, ok := key["SK"].(*types.AttributeValueMemberS); ok && sk.Value == buildPollDatabaseKey(id) {
+				// the poll item is deleted once everything under it has gone
+				continue
+			}
+
+			children = append(children, key)
+		}
+
+		if len(children) > 0 {
+			found = true
+		}
+
+		for start := 0; start < len(children); start += _maxBatchWriteItems {
+			end := start + _maxBatchWriteItems
+			if end > len(children) {
+				end = len(children)
+			}
+
+			if err := r.batchDeleteItems(ctx, children[start:end]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !found {
+		return ErrPollNotFound
+	}
+
+	_, err = r.db.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: r.tableName,
+		Key:       buildPollItemKey(id),
+	})
+	if err != nil {
+		return fmt.Errorf("calling DeleteItem for poll: %w", err)
+	}
+
+	return nil
+}
+
+// markPollDeleting records when the poll started being deleted, keeping the time from the first
+// attempt when a delete is retried. It returns false when the poll item has already gone.
+func (r *repo) markPollDeleting(ctx context.Context, id string, deletingAt time.Time) (bool, error) {
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.Set(
+			expression.Name("deletingAt"),
+			expression.IfNotExists(expression.Name("deletingAt"), expression.Value(formatTimestamp(deletingAt))),
+		)).
+		WithCondition(expression.AttributeExists(expression.Name("PK"))).
+		Build()
+	if err != nil {
+		return false, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 r.tableName,
+		Key:                       buildPollItemKey(id),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	if _, err := r.db.UpdateItem(ctx, input); err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("calling UpdateItem to mark poll as deleting: %w", err)
+	}
+
+	return true, nil
+}
+
+// batchDeleteItems deletes up to 25 items by their keys, retrying any DynamoDB didn't get to
+func (r *repo) batchDeleteItems(ctx context.Context, keys []map[string]types.AttributeValue) error {
+	var requests []types.WriteRequest
+	for _, key := range keys {
+		requests = append(requests, types.WriteRequest{
+			DeleteRequest: &types.DeleteRequest{Key: key},
+		})
+	}
+
+	for attempt := 1; len(requests) > 0; attempt++ {
+		if attempt > _maxBatchAttempts {
+			return fmt.Errorf("%d poll items were still unprocessed after %d attempts", len(requests), _maxBatchAttempts)
+		}
+
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * _batchRetryBackoff)
+		}
+
+		res, err := r.db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{*r.tableName: requests},
+		})
+		if err != nil {
+			return fmt.Errorf("calling BatchWriteItem to delete poll items: %w", err)
+		}
+
+		requests = res.UnprocessedItems[*r.tableName]
+	}
+
+	return nil
+}
@@ -0,0 +1,516 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+var ErrVoteNotQuarantined = errors.New("vote is not quarantined")
+
+const (
+	// VoteFraudStatusQuarantined votes looked suspicious and aren't counted until they are reviewed
+	VoteFraudStatusQuarantined = "quarantined"
+	// VoteFraudStatusReleased votes were quarantined and have since been reviewed and counted
+	VoteFraudStatusReleased = "released"
+)
+
+// quarantined votes are indexed by their poll and ID whilst they wait to be reviewed
+const _quarantineIndex = "QuarantineIndex"
+
+const _userProfileSortKey = "PROFILE"
+
+func buildFraudCounterDatabaseKey(counter string, windowStart time.Time) string {
+	return fmt.Sprintf("FRAUD#%s#%d", counter, windowStart.Unix())
+}
+
+func buildFraudScoredDatabaseKey(voteID string) string {
+	return fmt.Sprintf("FRAUDSCORED#%s", voteID)
+}
+
+// DatabaseFraudScored records that a vote has been counted towards its poll's fraud counters
+type DatabaseFraudScored struct {
+	PK       string `dynamodbav:"PK"`
+	SK       string `dynamodbav:"SK"`
+	ItemType string `dynamodbav:"itemType"`
+	// Counts are the counts of each counter straight after the vote was counted, so scoring the vote
+	// again gives the same result
+	Counts map[string]int `dynamodbav:"counts,omitempty"`
+	// ExpiresAt is the TTL of the marker in seconds since the epoch, it is kept as long as the
+	// counters it was counted in
+	ExpiresAt int64 `dynamodbav:"expiresAt"`
+}
+
+// FraudCounterWindow is the window of one of a poll's fraud counters that a vote is counted in
+type FraudCounterWindow struct {
+	Counter     string
+	WindowStart time.Time
+	ExpiresAt   time.Time
+}
+
+// IncrementFraudCounters counts a vote towards the given windows of a poll's fraud counters,
+// returning the count so far of each counter. The vote is counted in the same transaction as
+// recording that it has been, so a vote that is scored again when its stream batch is retried
+// isn't counted twice and gets the counts it was first scored with. Counters are kept with the
+// poll so they are deleted with it, and expire on their own once the window is long gone.
+func (r *repo) IncrementFraudCounters(ctx context.Context, pollID string, voteID string, windows []FraudCounterWindow) (map[string]int, error) {
+	if len(windows) == 0 {
+		return map[string]int{}, nil
+	}
+
+	scored := DatabaseFraudScored{
+		PK:       buildPollDatabaseKey(pollID),
+		SK:       buildFraudScoredDatabaseKey(voteID),
+		ItemType: "FraudScored",
+	}
+
+	var counterUpdates []types.TransactWriteItem
+	for _, w := range windows {
+		if w.ExpiresAt.Unix() > scored.ExpiresAt {
+			scored.ExpiresAt = w.ExpiresAt.Unix()
+		}
+
+		update := expression.
+			Set(expression.Name("itemType"), expression.Value("FraudCounter")).
+			Set(expression.Name("expiresAt"), expression.Value(w.ExpiresAt.Unix())).
+			Add(expression.Name("count"), expression.Value(1))
+
+		expr, err := expression.NewBuilder().WithUpdate(update).Build()
+		if err != nil {
+			return nil, fmt.Errorf("building expression: %w", err)
+		}
+
+		counterUpdates = append(counterUpdates, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName:                 r.tableName,
+				Key:                       fraudCounterItemKey(pollID, w),
+				UpdateExpression:          expr.Update(),
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+			},
+		})
+	}
+
+	item, err := attributevalue.MarshalMap(scored)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling fraud scored marker: %w", err)
+	}
+
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.AttributeNotExists(expression.Name("PK"))).
+		Build()
+	if err != nil {
+		return nil, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:                r.tableName,
+					Item:                     item,
+					ConditionExpression:      expr.Condition(),
+					ExpressionAttributeNames: expr.Names(),
+				},
+			},
+		}, counterUpdates...),
+	}
+
+	if _, err := r.db.TransactWriteItems(ctx, input); err != nil {
+		var cancelledErr *types.TransactionCanceledException
+		if !errors.As(err, &cancelledErr) || len(cancelledErr.CancellationReasons) == 0 ||
+			aws.StringValue(cancelledErr.CancellationReasons[0].Code) != "ConditionalCheckFailed" {
+			return nil, fmt.Errorf("calling TransactWriteItems for fraud counters: %w", err)
+		}
+
+		// the vote has already been counted, it gets the counts it was first scored with
+		return r.scoredFraudCounts(ctx, pollID, voteID, windows)
+	}
+
+	counts, err := r.getFraudCounts(ctx, pollID, windows)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err = expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("counts"), expression.Value(counts))).
+		Build()
+	if err != nil {
+		return nil, fmt.Errorf("building expression: %w", err)
+	}
+
+	_, err = r.db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 r.tableName,
+		Key:                       fraudScoredItemKey(pollID, voteID),
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("calling UpdateItem for fraud scored marker: %w", err)
+	}
+
+	return counts, nil
+}
+
+// scoredFraudCounts finds the counts a vote was first scored with. A vote whose counts were never
+// saved, because scoring it failed part way through, gets the counts as they are now.
+func (r *repo) scoredFraudCounts(ctx context.Context, pollID string, voteID string, windows []FraudCounterWindow) (map[string]int, error) {
+	res, err := r.db.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      r.tableName,
+		Key:            fraudScoredItemKey(pollID, voteID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting fraud scored marker: %w", err)
+	}
+
+	var scored DatabaseFraudScored
+	if err := attributevalue.UnmarshalMap(res.Item, &scored); err != nil {
+		return nil, fmt.Errorf("unmarshalling fraud scored marker: %w", err)
+	}
+
+	if scored.Counts != nil {
+		return scored.Counts, nil
+	}
+
+	return r.getFraudCounts(ctx, pollID, windows)
+}
+
+func (r *repo) getFraudCounts(ctx context.Context, pollID string, windows []FraudCounterWindow) (map[string]int, error) {
+	counts := make(map[string]int, len(windows))
+	for _, w := range windows {
+		res, err := r.db.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      r.tableName,
+			Key:            fraudCounterItemKey(pollID, w),
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("getting fraud counter: %w", err)
+		}
+
+		count, ok := res.Item["count"].(*types.AttributeValueMemberN)
+		if !ok {
+			return nil, fmt.Errorf("fraud counter did not have a count")
+		}
+
+		counts[w.Counter], err = strconv.Atoi(count.Value)
+		if err != nil {
+			return nil, fmt.Errorf("parsing fraud counter: %w", err)
+		}
+	}
+
+	return counts, nil
+}
+
+func fraudCounterItemKey(pollID string, w FraudCounterWindow) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{
+			Value: buildPollDatabaseKey(pollID),
+		},
+		"SK": &types.AttributeValueMemberS{
+			Value: buildFraudCounterDatabaseKey(w.Counter, w.WindowStart),
+		},
+	}
+}
+
+func fraudScoredItemKey(pollID string, voteID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{
+			Value: buildPollDatabaseKey(pollID),
+		},
+		"SK": &types.AttributeValueMemberS{
+			Value: buildFraudScoredDatabaseKey(voteID),
+		},
+	}
+}
+
+// RecordUserSeen notes that a user has been seen, returning when they were first seen
+func (r *repo) RecordUserSeen(ctx context.Context, userID string, seenAt time.Time) (time.Time, error) {
+	update := expression.
+		Set(expression.Name("itemType"), expression.Value("UserProfile")).
+		Set(expression.Name("firstSeenAt"), expression.IfNotExists(expression.Name("firstSeenAt"), expression.Value(formatTimestamp(seenAt))))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: r.tableName,
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: buildUserDatabaseKey(userID),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: _userProfileSortKey,
+			},
+		},
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	res, err := r.db.UpdateItem(ctx, input)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("calling UpdateItem for user profile: %w", err)
+	}
+
+	var profile struct {
+		FirstSeenAt string `dynamodbav:"firstSeenAt"`
+	}
+	if err := attributevalue.UnmarshalMap(res.Attributes, &profile); err != nil {
+		return time.Time{}, fmt.Errorf("unmarshalling user profile: %w", err)
+	}
+
+	return ParseTimestamp(profile.FirstSeenAt)
+}
+
+// QuarantinePollVote stops a vote from being counted until it has been reviewed, quarantining a vote
+// again leaves it as it is. It returns ErrVoteNotFound if the vote has since been changed or
+// retracted.
+func (r *repo) QuarantinePollVote(ctx context.Context, pollID string, userID string, voteID string, policy string, signals []string) error {
+	update := expression.
+		Set(expression.Name("fraudStatus"), expression.Value(VoteFraudStatusQuarantined)).
+		Set(expression.Name("fraudSignals"), expression.Value(signals)).
+		Set(expression.Name("quarantinedPoll"), expression.Value(pollID))
+
+	condition := expression.Name("id").Equal(expression.Value(voteID)).
+		And(expression.AttributeNotExists(expression.Name("fraudStatus")))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	key := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{
+			Value: buildPollDatabaseKey(pollID),
+		},
+		"SK": &types.AttributeValueMemberS{
+			Value: buildVoteSortKey(userID, voteID, policy),
+		},
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 r.tableName,
+		Key:                       key,
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	if _, err := r.db.UpdateItem(ctx, input); err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if !errors.As(err, &conditionErr) {
+			return fmt.Errorf("calling UpdateItem for vote quarantine: %w", err)
+		}
+
+		// the vote stream redelivers votes whose batch failed, a vote that was quarantined the first
+		// time round stays quarantined
+		res, err := r.db.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      r.tableName,
+			Key:            key,
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return fmt.Errorf("getting vote: %w", err)
+		}
+
+		var vote DatabasePollVote
+		if err := attributevalue.UnmarshalMap(res.Item, &vote); err != nil {
+			return fmt.Errorf("unmarshalling vote: %w", err)
+		}
+
+		if res.Item == nil || vote.ID != voteID || vote.FraudStatus != VoteFraudStatusQuarantined {
+			return ErrVoteNotFound
+		}
+	}
+
+	return nil
+}
+
+// ListQuarantinedVotes pages through a poll's quarantined votes. The returned cursor is empty
+// once the last page has been read.
+func (r *repo) ListQuarantinedVotes(ctx context.Context, pollID string, limit int, cursor string) ([]DatabasePollVote, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key("quarantinedPoll").Equal(expression.Value(pollID))).
+		Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 r.tableName,
+		IndexName:                 aws.String(_quarantineIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(int32(limit)),
+		ExclusiveStartKey:         startKey,
+	}
+
+	res, err := r.db.Query(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("querying quarantined votes: %w", err)
+	}
+
+	var votes []DatabasePollVote
+	if err := attributevalue.UnmarshalListOfMaps(res.Items, &votes); err != nil {
+		return nil, "", fmt.Errorf("unmarshalling quarantined votes: %w", err)
+	}
+
+	nextCursor, err := encodeCursor(res.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return votes, nextCursor, nil
+}
+
+// getQuarantinedVote finds a quarantined vote by its ID, which is all reviewers know it by
+func (r *repo) getQuarantinedVote(ctx context.Context, pollID string, voteID string) (DatabasePollVote, error) {
+	keyCond := expression.Key("quarantinedPoll").Equal(expression.Value(pollID)).
+		And(expression.Key("id").Equal(expression.Value(voteID)))
+
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return DatabasePollVote{}, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 r.tableName,
+		IndexName:                 aws.String(_quarantineIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	res, err := r.db.Query(ctx, input)
+	if err != nil {
+		return DatabasePollVote{}, fmt.Errorf("querying quarantined votes: %w", err)
+	}
+
+	if len(res.Items) == 0 {
+		return DatabasePollVote{}, ErrVoteNotQuarantined
+	}
+
+	var vote DatabasePollVote
+	if err := attributevalue.UnmarshalMap(res.Items[0], &vote); err != nil {
+		return DatabasePollVote{}, fmt.Errorf("unmarshalling quarantined vote: %w", err)
+	}
+
+	return vote, nil
+}
+
+// ReleasePollVote lets a quarantined vote be counted
+func (r *repo) ReleasePollVote(ctx context.Context, pollID string, voteID string) (DatabasePollVote, error) {
+	vote, err := r.getQuarantinedVote(ctx, pollID, voteID)
+	if err != nil {
+		return DatabasePollVote{}, err
+	}
+
+	update := expression.
+		Set(expression.Name("fraudStatus"), expression.Value(VoteFraudStatusReleased)).
+		Remove(expression.Name("quarantinedPoll"))
+
+	expr, err := expression.NewBuilder().
+		WithUpdate(update).
+		WithCondition(quarantinedVoteCondition(voteID)).
+		Build()
+	if err != nil {
+		return DatabasePollVote{}, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 r.tableName,
+		Key:                       voteItemKey(vote),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	res, err := r.db.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return DatabasePollVote{}, ErrVoteNotQuarantined
+		}
+
+		return DatabasePollVote{}, fmt.Errorf("calling UpdateItem for vote release: %w", err)
+	}
+
+	var released DatabasePollVote
+	if err := attributevalue.UnmarshalMap(res.Attributes, &released); err != nil {
+		return DatabasePollVote{}, fmt.Errorf("unmarshalling released vote: %w", err)
+	}
+
+	return released, nil
+}
+
+// DiscardPollVote deletes a quarantined vote so it is never counted
+func (r *repo) DiscardPollVote(ctx context.Context, pollID string, voteID string) error {
+	vote, err := r.getQuarantinedVote(ctx, pollID, voteID)
+	if err != nil {
+		return err
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(quarantinedVoteCondition(voteID)).Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.DeleteItemInput{
+		TableName:                 r.tableName,
+		Key:                       voteItemKey(vote),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	if _, err := r.db.DeleteItem(ctx, input); err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrVoteNotQuarantined
+		}
+
+		return fmt.Errorf("calling DeleteItem for vote: %w", err)
+	}
+
+	return nil
+}
+
+// quarantinedVoteCondition makes sure a vote found through the index is still the same quarantined
+// vote, as the index can lag behind the table
+func quarantinedVoteCondition(voteID string) expression.ConditionBuilder {
+	return expression.Name("id").Equal(expression.Value(voteID)).
+		And(expression.Name("fraudStatus").Equal(expression.Value(VoteFraudStatusQuarantined)))
+}
+
+func voteItemKey(vote DatabasePollVote) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{
+			Value: vote.PK,
+		},
+		"SK": &types.AttributeValueMemberS{
+			Value: vote.SK,
+		},
+	}
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+var ErrIdempotencyKeyNotFound = errors.New("could not find idempotency key")
+
+const (
+	// IdempotencyStatusPending keys have been claimed by a request that hasn't finished yet
+	IdempotencyStatusPending = "pending"
+	// IdempotencyStatusComplete keys hold the response to replay for retries of the request
+	IdempotencyStatusComplete = "complete"
+)
+
+// DatabaseIdempotencyKey remembers a request made with an idempotency key so retries of it get the
+// original response back rather than doing the work again
+type DatabaseIdempotencyKey struct {
+	PK       string `dynamodbav:"PK"`
+	SK       string `dynamodbav:"SK"`
+	ItemType string `dynamodbav:"itemType"`
+	Status   string `dynamodbav:"status"`
+	// RequestHash identifies the request the key was first used with
+	RequestHash string `dynamodbav:"requestHash"`
+	// StatusCode, Headers and Body are the response to the request, they are set once it completes
+	StatusCode int               `dynamodbav:"statusCode,omitempty"`
+	Headers    map[string]string `dynamodbav:"headers,omitempty"`
+	Body       string            `dynamodbav:"body,omitempty"`
+	CreatedAt  string            `dynamodbav:"createdAt"`
+	// ExpiresAt is the TTL of the key in seconds since the epoch
+	ExpiresAt int64 `dynamodbav:"expiresAt"`
+}
+
+func buildIdempotencyDatabaseKey(scope string, key string) string {
+	return fmt.Sprintf("IDEMPOTENCY#%s#%s", scope, key)
+}
+
+// ClaimIdempotencyKey records that a request is being made with an idempotency key. When the key
+// has already been claimed the existing record is returned instead and claimed is false.
+func (r *repo) ClaimIdempotencyKey(ctx context.Context, scope string, key string, requestHash string, createdAt time.Time, expiresAt time.Time) (DatabaseIdempotencyKey, bool, error) {
+	dbKey := buildIdempotencyDatabaseKey(scope, key)
+
+	record := DatabaseIdempotencyKey{
+		PK:          dbKey,
+		SK:          dbKey,
+		ItemType:    "IdempotencyKey",
+		Status:      IdempotencyStatusPending,
+		RequestHash: requestHash,
+		CreatedAt:   formatTimestamp(createdAt),
+		ExpiresAt:   expiresAt.Unix(),
+	}
+
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return DatabaseIdempotencyKey{}, false, fmt.Errorf("marshalling idempotency key: %w", err)
+	}
+
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.AttributeNotExists(expression.Name("PK"))).
+		Build()
+	if err != nil {
+		return DatabaseIdempotencyKey{}, false, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                 r.tableName,
+		Item:                      item,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	if _, err := r.db.PutItem(ctx, input); err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if !errors.As(err, &conditionErr) {
+			return DatabaseIdempotencyKey{}, false, fmt.Errorf("calling PutItem for idempotency key: %w", err)
+		}
+
+		existing, err := r.getIdempotencyKey(ctx, dbKey)
+		if err != nil {
+			return DatabaseIdempotencyKey{}, false, fmt.Errorf("getting idempotency key: %w", err)
+		}
+
+		return existing, false, nil
+	}
+
+	return record, true, nil
+}
+
+func (r *repo) getIdempotencyKey(ctx context.Context, dbKey string) (DatabaseIdempotencyKey, error) {
+	input := &dynamodb.GetItemInput{
+		TableName:      r.tableName,
+		Key:            buildSingletonItemKey(dbKey),
+		ConsistentRead: aws.Bool(true),
+	}
+
+	result, err := r.db.GetItem(ctx, input)
+	if err != nil {
+		return DatabaseIdempotencyKey{}, err
+	}
+
+	if result.Item == nil {
+		return DatabaseIdempotencyKey{}, ErrIdempotencyKeyNotFound
+	}
+
+	var record DatabaseIdempotencyKey
+	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
+		return DatabaseIdempotencyKey{}, fmt.Errorf("unmarshalling idempotency key item: %w", err)
+	}
+
+	return record, nil
+}
+
+// CompleteIdempotencyKey stores the response to a request made with an idempotency key
+func (r *repo) CompleteIdempotencyKey(ctx context.Context, scope string, key string, statusCode int, headers map[string]string, body string) error {
+	update := expression.
+		Set(expression.Name("status"), expression.Value(IdempotencyStatusComplete)).
+		Set(expression.Name("statusCode"), expression.Value(statusCode)).
+		Set(expression.Name("headers"), expression.Value(headers)).
+		Set(expression.Name("body"), expression.Value(body))
+
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.AttributeExists(expression.Name("PK"))).
+		WithUpdate(update).
+		Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 r.tableName,
+		Key:                       buildSingletonItemKey(buildIdempotencyDatabaseKey(scope, key)),
+		ConditionExpression:       expr.Condition(),
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	if _, err := r.db.UpdateItem(ctx, input); err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrIdempotencyKeyNotFound
+		}
+
+		return fmt.Errorf("calling UpdateItem for idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a request that didn't complete so it can be retried with the same key
+func (r *repo) ReleaseIdempotencyKey(ctx context.Context, scope string, key string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: r.tableName,
+		Key:       buildSingletonItemKey(buildIdempotencyDatabaseKey(scope, key)),
+	}
+
+	if _, err := r.db.DeleteItem(ctx, input); err != nil {
+		return fmt.Errorf("calling DeleteItem for idempotency key: %w", err)
+	}
+
+	return nil
+}
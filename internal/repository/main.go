@@ -1,6 +1,11 @@
 package repository
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
 
 func buildPollDatabaseKey(id string) string {
 	return fmt.Sprintf("POLL#%s", id)
@@ -9,3 +14,48 @@ func buildPollDatabaseKey(id string) string {
 func buildUserDatabaseKey(id string) string {
 	return fmt.Sprintf("USER#%s", id)
 }
+
+func buildSessionDatabaseKey(id string) string {
+	return fmt.Sprintf("SESSION#%s", id)
+}
+
+func buildUserVoteDatabaseKey(userID string, voteID string) string {
+	return fmt.Sprintf("USER#%s#VOTE#%s", userID, voteID)
+}
+
+func buildPollItemKey(id string) map[string]types.AttributeValue {
+	pollKey := buildPollDatabaseKey(id)
+
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{
+			Value: pollKey,
+		},
+		"SK": &types.AttributeValueMemberS{
+			Value: pollKey,
+		},
+	}
+}
+
+// formatTimestamp stores times in UTC to the second so that they sort lexicographically
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Truncate(time.Second).Format(time.RFC3339)
+}
+
+// formatExpiry stores an expiry as seconds since the epoch, which is the format DynamoDB needs for
+// a TTL attribute. Items that should never expire have no expiry.
+func formatExpiry(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+
+	return t.Unix()
+}
+
+// ParseTimestamp reads a time previously stored by the repository
+func ParseTimestamp(t string) (time.Time, error) {
+	return time.Parse(time.RFC3339, t)
+}
+
+func buildTermDatabaseKey(term string) string {
+	return fmt.Sprintf("TERM#%s", term)
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go/aws"
@@ -13,6 +16,38 @@ import (
 )
 
 var ErrPollNotFound = errors.New("could not find poll")
+var ErrPollStatusConflict = errors.New("poll is not in an expected status")
+var ErrPollVersionConflict = errors.New("poll has been changed since it was read")
+var ErrPollHasVotes = errors.New("poll cannot be edited once it has votes")
+
+const (
+	_scheduledCloseIndex = "ScheduledCloseIndex"
+	// all polls waiting to be closed share a partition in the scheduled close index so they can
+	// be queried by their deadline
+	_closeScheduleKey = "SCHEDULED"
+	// polls are listed per channel by when they were created, polls created before the index was
+	// added have no createdAt so are left out of it
+	_channelPollsIndex = "ChannelPollsIndex"
+	// open polls are indexed by their channel so late joining viewers can find them, the index is
+	// sparse as activeChannel is removed when a poll closes
+	_activePollsIndex = "ActivePollsIndex"
+)
+
+const (
+	PollTypeSingleChoice = "single-choice"
+	PollTypeMultiSelect  = "multi-select"
+	PollTypeRankedChoice = "ranked-choice"
+	PollTypeQuiz         = "quiz"
+	PollTypeScale        = "scale"
+	PollTypeFreeText     = "free-text"
+	PollTypePrediction   = "prediction"
+)
+
+const (
+	PollStatusDraft  = "draft"
+	PollStatusOpen   = "open"
+	PollStatusClosed = "closed"
+)
 
 type repo struct {
 	tableName *string
@@ -27,23 +62,93 @@ func New(tableName string, db *dynamodb.Client) *repo {
 }
 
 type DatabasePoll struct {
-	PK                   string               `dynamodbav:"PK"`
-	SK                   string               `dynamodbav:"SK"`
-	ID                   string               `dynamodbav:"id"`
-	ItemType             string               `dynamodbav:"itemType"`
-	Question             string               `dynamodbav:"question"`
-	Options              []DatabasePollOption `dynamodbav:"options"`
-	ChannelARN           string               `dynamodbav:"channelARN"`
-	AggregatedVoteTotals DatabasePollTotals   `dynamodbav:"aggregatedVoteTotals"`
+	PK              string               `dynamodbav:"PK"`
+	SK              string               `dynamodbav:"SK"`
+	ID              string               `dynamodbav:"id"`
+	ItemType        string               `dynamodbav:"itemType"`
+	Type            string               `dynamodbav:"pollType"`
+	Question        string               `dynamodbav:"question"`
+	Options         []DatabasePollOption `dynamodbav:"options"`
+	MinSelections   int                  `dynamodbav:"minSelections,omitempty"`
+	MaxSelections   int                  `dynamodbav:"maxSelections,omitempty"`
+	ScaleMin        int                  `dynamodbav:"scaleMin,omitempty"`
+	ScaleMax        int                  `dynamodbav:"scaleMax,omitempty"`
+	ChannelARN      string               `dynamodbav:"channelARN"`
+	SessionID       string               `dynamodbav:"sessionId,omitempty"`
+	Status          string               `dynamodbav:"status"`
+	VotePolicy      string               `dynamodbav:"votePolicy"`
+	DurationSeconds int                  `dynamodbav:"durationSeconds,omitempty"`
+	OpenedAt        string               `dynamodbav:"openedAt,omitempty"`
+	ClosesAt        string               `dynamodbav:"closesAt,omitempty"`
+	CloseSchedule   string               `dynamodbav:"closeSchedule,omitempty"`
+	AnswerRevealed  bool                 `dynamodbav:"answerRevealed,omitempty"`
+	// Version goes up by one every time the poll is edited, polls created before editing was
+	// introduced have no version
+	Version int `dynamodbav:"version,omitempty"`
+	// HasVotes is set along with the first vote on the poll, polls can't be edited once it is
+	HasVotes bool `dynamodbav:"hasVotes,omitempty"`
+	// PredictionPoints are awarded to everyone who predicts the winning option
+	PredictionPoints int    `dynamodbav:"predictionPoints,omitempty"`
+	WinningOptionID  string `dynamodbav:"winningOptionId,omitempty"`
+	ResolvedAt       string `dynamodbav:"resolvedAt,omitempty"`
+	// AwardedAt is when every voter had been awarded their points for a revealed quiz or resolved
+	// prediction
+	AwardedAt string `dynamodbav:"awardedAt,omitempty"`
+	// DeletingAt is when a delete of the poll started, the poll item is kept until everything stored
+	// under it has gone
+	DeletingAt string `dynamodbav:"deletingAt,omitempty"`
+	// AggregatedVoteTotals is keyed by option ID, or by value for scale polls so it doubles as
+	// the histogram of votes
+	AggregatedVoteTotals DatabasePollTotals `dynamodbav:"aggregatedVoteTotals"`
+	// WeightRules maps a viewer tier to how many votes each of their votes counts as
+	WeightRules map[string]int `dynamodbav:"weightRules,omitempty"`
+	// WeightedVoteTotals is only kept for polls with weight rules, it sums the weight of each vote
+	// where AggregatedVoteTotals counts each vote once
+	WeightedVoteTotals DatabasePollTotals `dynamodbav:"weightedVoteTotals,omitempty"`
+	// ScaleStats is only set for scale polls
+	ScaleStats *DatabaseScaleStats `dynamodbav:"scaleStats,omitempty"`
+	// TopTerms are the most used terms on a free-text poll, most used first
+	TopTerms []DatabaseTermCount `dynamodbav:"topTerms,omitempty"`
+	// CreatedAt sorts the poll amongst the rest of its channel's polls
+	CreatedAt string `dynamodbav:"createdAt,omitempty"`
+	// ExpiresAt is the TTL of the poll in seconds since the epoch, DynamoDB removes the poll some
+	// time after it passes
+	ExpiresAt int64 `dynamodbav:"expiresAt,omitempty"`
+	// ActiveChannel is a copy of the channel ARN that is only set whilst the poll is open
+	ActiveChannel string `dynamodbav:"activeChannel,omitempty"`
+	// DefinitionBroadcastAt is when the full poll was last re-broadcast for viewers who missed it
+	DefinitionBroadcastAt string `dynamodbav:"definitionBroadcastAt,omitempty"`
+	// RunoffBroadcastAt is when the instant-runoff results of a ranked-choice poll were last
+	// broadcast
+	RunoffBroadcastAt string `dynamodbav:"runoffBroadcastAt,omitempty"`
+	// CreatorID is the creator who made the poll, polls made before creators had to authenticate
+	// have no creator
+	CreatorID string `dynamodbav:"creatorId,omitempty"`
+	// VoteRateLimit replaces the default per user vote rate limit for the poll
+	VoteRateLimit *DatabaseRateLimit `dynamodbav:"voteRateLimit,omitempty"`
+}
+
+// DatabaseRateLimit lets Requests through every PerSeconds
+type DatabaseRateLimit struct {
+	Requests   int `dynamodbav:"requests"`
+	PerSeconds int `dynamodbav:"perSeconds"`
 }
 
 type DatabasePollOption struct {
 	ID    string `dynamodbav:"id"`
 	Label string `dynamodbav:"label"`
+	// Correct marks the right answer(s) to a quiz
+	Correct bool `dynamodbav:"correct,omitempty"`
 }
 
 type DatabasePollTotals = map[string]int
 
+// DatabaseScaleStats keeps the running count and sum of a scale poll's votes
+type DatabaseScaleStats struct {
+	Count int `dynamodbav:"count"`
+	Sum   int `dynamodbav:"sum"`
+}
+
 func (r *repo) GetPoll(ctx context.Context, id string) (DatabasePoll, error) {
 	pollKey := buildPollDatabaseKey(id)
 
@@ -77,21 +182,105 @@ func (r *repo) GetPoll(ctx context.Context, id string) (DatabasePoll, error) {
 	return foundPoll, nil
 }
 
+// DynamoDB accepts at most 100 keys in a single BatchGetItem call
+const _maxBatchGetItems = 100
+
+// GetPolls loads several polls at once, keyed by their ID. Polls that don't exist are left out.
+func (r *repo) GetPolls(ctx context.Context, ids []string) (map[string]DatabasePoll, error) {
+	polls := make(map[string]DatabasePoll, len(ids))
+
+	for start := 0; start < len(ids); start += _maxBatchGetItems {
+		end := start + _maxBatchGetItems
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		var keys []map[string]types.AttributeValue
+		for _, id := range ids[start:end] {
+			keys = append(keys, buildPollItemKey(id))
+		}
+
+		requestItems := map[string]types.KeysAndAttributes{*r.tableName: {Keys: keys}}
+		for attempt := 1; len(requestItems) > 0; attempt++ {
+			if attempt > _maxBatchAttempts {
+				return nil, fmt.Errorf("polls were still unprocessed after %d attempts", _maxBatchAttempts)
+			}
+
+			if attempt > 1 {
+				time.Sleep(time.Duration(attempt-1) * _batchRetryBackoff)
+			}
+
+			res, err := r.db.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: requestItems})
+			if err != nil {
+				return nil, fmt.Errorf("calling BatchGetItem for polls: %w", err)
+			}
+
+			var found []DatabasePoll
+			if err := attributevalue.UnmarshalListOfMaps(res.Responses[*r.tableName], &found); err != nil {
+				return nil, fmt.Errorf("unmarshalling poll items: %w", err)
+			}
+
+			for _, p := range found {
+				polls[p.ID] = p
+			}
+
+			requestItems = res.UnprocessedKeys
+		}
+	}
+
+	return polls, nil
+}
+
 type NewPoll struct {
-	Question   string
-	Options    []string
-	ChannelARN string
+	Type     string
+	Question string
+	Options  []string
+	// CorrectOptions are the indexes of the options that answer a quiz correctly
+	CorrectOptions []int
+	// MinSelections and MaxSelections limit how many options a multi-select vote can choose
+	MinSelections int
+	MaxSelections int
+	// ScaleMin and ScaleMax are the inclusive range of values a scale poll accepts
+	ScaleMin int
+	ScaleMax int
+	// PredictionPoints are awarded to everyone who predicts a prediction's winning option
+	PredictionPoints int
+	// WeightRules maps a viewer tier to the weight of their votes, other viewers' votes count once
+	WeightRules map[string]int
+	ChannelARN  string
+	// SessionID groups the poll with others in a quiz session
+	SessionID  string
+	Status     string
+	VotePolicy string
+	// DurationSeconds is how long the poll stays open for once it has been opened
+	DurationSeconds int
+	// ClosesAt is when an open poll is automatically closed
+	ClosesAt *time.Time
+	// OpenedAt is when the poll started accepting votes
+	OpenedAt *time.Time
+	// ExpiresAt is when the poll should be removed from the table, polls without it are kept forever
+	ExpiresAt *time.Time
+	CreatedAt time.Time
+	CreatorID string
+	// VoteRateLimit replaces the default per user vote rate limit for the poll
+	VoteRateLimit *DatabaseRateLimit
 }
 
 func (r *repo) CreatePoll(ctx context.Context, poll NewPoll) (DatabasePoll, error) {
 	id := uuid.NewString()
 	pollKey := buildPollDatabaseKey(id)
 
+	correct := make(map[int]bool, len(poll.CorrectOptions))
+	for _, i := range poll.CorrectOptions {
+		correct[i] = true
+	}
+
 	var pollOptions []DatabasePollOption
-	for _, o := range poll.Options {
+	for i, o := range poll.Options {
 		pollOptions = append(pollOptions, DatabasePollOption{
-			ID:    uuid.NewString(),
-			Label: o,
+			ID:      uuid.NewString(),
+			Label:   o,
+			Correct: correct[i],
 		})
 	}
 
@@ -100,15 +289,66 @@ func (r *repo) CreatePoll(ctx context.Context, poll NewPoll) (DatabasePoll, erro
 		totals[o.ID] = 0
 	}
 
+	var weightedTotals DatabasePollTotals
+	if len(poll.WeightRules) > 0 {
+		weightedTotals = make(DatabasePollTotals, len(totals))
+		for answerID := range totals {
+			weightedTotals[answerID] = 0
+		}
+	}
+
+	var scaleStats *DatabaseScaleStats
+	if poll.Type == PollTypeScale {
+		for v := poll.ScaleMin; v <= poll.ScaleMax; v++ {
+			totals[ScaleTotalsKey(v)] = 0
+		}
+
+		scaleStats = &DatabaseScaleStats{}
+	}
+
 	dbPoll := DatabasePoll{
 		PK:                   pollKey,
 		SK:                   pollKey,
 		ID:                   id,
 		ItemType:             "Poll",
+		Version:              1,
+		Type:                 poll.Type,
 		Question:             poll.Question,
 		Options:              pollOptions,
+		MinSelections:        poll.MinSelections,
+		MaxSelections:        poll.MaxSelections,
+		ScaleMin:             poll.ScaleMin,
+		ScaleMax:             poll.ScaleMax,
+		PredictionPoints:     poll.PredictionPoints,
 		ChannelARN:           poll.ChannelARN,
+		SessionID:            poll.SessionID,
+		Status:               poll.Status,
+		VotePolicy:           poll.VotePolicy,
+		DurationSeconds:      poll.DurationSeconds,
 		AggregatedVoteTotals: totals,
+		WeightRules:          poll.WeightRules,
+		WeightedVoteTotals:   weightedTotals,
+		ScaleStats:           scaleStats,
+		CreatedAt:            formatTimestamp(poll.CreatedAt),
+		ExpiresAt:            formatExpiry(poll.ExpiresAt),
+		CreatorID:            poll.CreatorID,
+		VoteRateLimit:        poll.VoteRateLimit,
+	}
+
+	if poll.OpenedAt != nil {
+		dbPoll.OpenedAt = formatTimestamp(*poll.OpenedAt)
+	}
+
+	if poll.ClosesAt != nil {
+		dbPoll.ClosesAt = formatTimestamp(*poll.ClosesAt)
+
+		if poll.Status == PollStatusOpen {
+			dbPoll.CloseSchedule = _closeScheduleKey
+		}
+	}
+
+	if poll.Status == PollStatusOpen {
+		dbPoll.ActiveChannel = poll.ChannelARN
 	}
 
 	item, err := attributevalue.MarshalMap(dbPoll)
@@ -128,3 +368,247 @@ func (r *repo) CreatePoll(ctx context.Context, poll NewPoll) (DatabasePoll, erro
 
 	return dbPoll, nil
 }
+
+// PollEdit replaces a poll's question and options. Options that keep their ID keep their place in
+// the poll's totals, options without an ID are added as new options.
+type PollEdit struct {
+	Question string
+	Options  []DatabasePollOption
+	// ExpectedVersion is the version the edit was based on, the edit is rejected if the poll has
+	// been changed since
+	ExpectedVersion int
+}
+
+// UpdatePoll applies an edit to a poll using the poll's version to make sure nobody else has edited
+// it in the meantime. The vote totals of polls with options are reset so they match the new
+// options, so ErrPollHasVotes is returned once a poll has been voted on. Scale and free-text polls
+// keep their totals as they aren't keyed by option.
+func (r *repo) UpdatePoll(ctx context.Context, id string, edit PollEdit, weighted bool) (DatabasePoll, error) {
+	totals := make(DatabasePollTotals, len(edit.Options))
+	for i, o := range edit.Options {
+		if o.ID == "" {
+			edit.Options[i].ID = uuid.NewString()
+		}
+
+		totals[edit.Options[i].ID] = 0
+	}
+
+	update := expression.
+		Set(expression.Name("question"), expression.Value(edit.Question)).
+		Set(expression.Name("options"), expression.Value(edit.Options)).
+		Set(expression.Name("version"), expression.Value(edit.ExpectedVersion+1))
+
+	if len(edit.Options) > 0 {
+		update = update.Set(expression.Name("aggregatedVoteTotals"), expression.Value(totals))
+
+		if weighted {
+			update = update.Set(expression.Name("weightedVoteTotals"), expression.Value(totals))
+		}
+	}
+
+	// the first vote marks the poll in the same transaction as the vote is written, so checking
+	// for it here means no vote can be counted against options that have since changed
+	condition := expression.AttributeExists(expression.Name("PK")).
+		And(versionCondition(edit.ExpectedVersion)).
+		And(expression.AttributeNotExists(expression.Name("hasVotes")))
+
+	expr, err := expression.NewBuilder().
+		WithUpdate(update).
+		WithCondition(condition).
+		Build()
+	if err != nil {
+		return DatabasePoll{}, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 r.tableName,
+		Key:                       buildPollItemKey(id),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	res, err := r.db.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			existing, err := r.GetPoll(ctx, id)
+			if err != nil {
+				return DatabasePoll{}, err
+			}
+
+			if existing.HasVotes {
+				return DatabasePoll{}, ErrPollHasVotes
+			}
+
+			return DatabasePoll{}, ErrPollVersionConflict
+		}
+
+		return DatabasePoll{}, fmt.Errorf("calling UpdateItem for poll edit: %w", err)
+	}
+
+	var updatedPoll DatabasePoll
+	if err := attributevalue.UnmarshalMap(res.Attributes, &updatedPoll); err != nil {
+		return DatabasePoll{}, fmt.Errorf("unmarshalling edited poll item: %w", err)
+	}
+
+	return updatedPoll, nil
+}
+
+// PollStatusUpdate describes the status a poll should move into. OpenedAt and ClosesAt record when
+// voting started and schedule the poll to be closed automatically, they are only honoured when
+// opening a poll.
+type PollStatusUpdate struct {
+	Status   string
+	OpenedAt *time.Time
+	ClosesAt *time.Time
+}
+
+// UpdatePollStatus moves a poll into the given status as long as it is currently in one of the
+// allowed statuses. ErrPollStatusConflict is returned when the poll exists but is in any other status.
+func (r *repo) UpdatePollStatus(ctx context.Context, id string, allowedFrom []string, update PollStatusUpdate) (DatabasePoll, error) {
+	var allowed []expression.OperandBuilder
+	for _, s := range allowedFrom {
+		allowed = append(allowed, expression.Value(s))
+	}
+
+	if len(allowed) == 0 {
+		return DatabasePoll{}, fmt.Errorf("at least one allowed status is required")
+	}
+
+	statusCondition := expression.Name("status").In(allowed[0], allowed[1:]...)
+	for _, s := range allowedFrom {
+		// polls created before statuses were introduced have no status and have always been open
+		if s == PollStatusOpen {
+			statusCondition = statusCondition.Or(expression.AttributeNotExists(expression.Name("status")))
+		}
+	}
+
+	builder := expression.Set(expression.Name("status"), expression.Value(update.Status))
+	if update.Status == PollStatusOpen {
+		builder = builder.Set(expression.Name("activeChannel"), expression.Name("channelARN"))
+	} else {
+		builder = builder.Remove(expression.Name("activeChannel"))
+	}
+
+	if update.Status == PollStatusOpen && update.OpenedAt != nil {
+		builder = builder.Set(expression.Name("openedAt"), expression.Value(formatTimestamp(*update.OpenedAt)))
+	}
+
+	if update.Status == PollStatusOpen && update.ClosesAt != nil {
+		builder = builder.
+			Set(expression.Name("closesAt"), expression.Value(formatTimestamp(*update.ClosesAt))).
+			Set(expression.Name("closeSchedule"), expression.Value(_closeScheduleKey))
+	} else {
+		builder = builder.Remove(expression.Name("closeSchedule"))
+
+		if update.Status == PollStatusOpen {
+			builder = builder.Remove(expression.Name("closesAt"))
+		}
+	}
+
+	expr, err := expression.NewBuilder().
+		WithUpdate(builder).
+		WithCondition(expression.AttributeExists(expression.Name("PK")).And(statusCondition)).
+		Build()
+	if err != nil {
+		return DatabasePoll{}, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 r.tableName,
+		Key:                       buildPollItemKey(id),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	res, err := r.db.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return DatabasePoll{}, r.pollConditionError(ctx, id, ErrPollStatusConflict)
+		}
+
+		return DatabasePoll{}, fmt.Errorf("calling UpdateItem for poll status: %w", err)
+	}
+
+	var updatedPoll DatabasePoll
+	if err := attributevalue.UnmarshalMap(res.Attributes, &updatedPoll); err != nil {
+		return DatabasePoll{}, fmt.Errorf("unmarshalling updated poll item: %w", err)
+	}
+
+	return updatedPoll, nil
+}
+
+// ListExpiredPollIDs finds the open polls whose closing deadline is at or before the given time
+func (r *repo) ListExpiredPollIDs(ctx context.Context, now time.Time) ([]string, error) {
+	keyCond := expression.Key("closeSchedule").Equal(expression.Value(_closeScheduleKey)).
+		And(expression.Key("closesAt").LessThanEqual(expression.Value(formatTimestamp(now))))
+
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(keyCond).
+		WithProjection(expression.NamesList(expression.Name("id"))).
+		Build()
+	if err != nil {
+		return nil, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 r.tableName,
+		IndexName:                 aws.String(_scheduledCloseIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	var ids []string
+	paginator := dynamodb.NewQueryPaginator(r.db, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("querying expired polls: %w", err)
+		}
+
+		var polls []DatabasePoll
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &polls); err != nil {
+			return nil, fmt.Errorf("unmarshalling expired polls: %w", err)
+		}
+
+		for _, p := range polls {
+			ids = append(ids, p.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+// ScaleTotalsKey is the aggregated vote totals key used to count votes for a value on a scale poll
+func ScaleTotalsKey(value int) string {
+	return strconv.Itoa(value)
+}
+
+// versionCondition checks a poll is still at the given version, polls created before editing was
+// introduced have no version
+func versionCondition(version int) expression.ConditionBuilder {
+	if version == 0 {
+		return expression.AttributeNotExists(expression.Name("version"))
+	}
+
+	return expression.Name("version").Equal(expression.Value(version))
+}
+
+// pollConditionError works out why a conditional write against a poll failed, returning
+// ErrPollNotFound when the poll does not exist and conflictErr otherwise.
+func (r *repo) pollConditionError(ctx context.Context, id string, conflictErr error) error {
+	if _, err := r.GetPoll(ctx, id); err != nil {
+		return err
+	}
+
+	return conflictErr
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrPollAlreadyResolved = errors.New("prediction has already been resolved")
+
+const _userPointsSortKey = "POINTS"
+
+// DatabaseUserPoints is a viewer's running total of points won from predictions
+type DatabaseUserPoints struct {
+	PK              string `dynamodbav:"PK"`
+	SK              string `dynamodbav:"SK"`
+	ItemType        string `dynamodbav:"itemType"`
+	UserID          string `dynamodbav:"userId"`
+	Points          int    `dynamodbav:"points"`
+	PredictionsMade int    `dynamodbav:"predictionsMade"`
+	PredictionsWon  int    `dynamodbav:"predictionsWon"`
+}
+
+// ResolvePoll records the winning option of a closed prediction. Each prediction can only be
+// resolved once so its winning option never changes whilst points are being paid out.
+func (r *repo) ResolvePoll(ctx context.Context, id string, winningOptionID string, resolvedAt time.Time) (DatabasePoll, error) {
+	condition := expression.AttributeExists(expression.Name("PK")).
+		And(expression.Name("status").Equal(expression.Value(PollStatusClosed))).
+		And(expression.AttributeNotExists(expression.Name("winningOptionId")))
+
+	update := expression.
+		Set(expression.Name("winningOptionId"), expression.Value(winningOptionID)).
+		Set(expression.Name("resolvedAt"), expression.Value(formatTimestamp(resolvedAt)))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return DatabasePoll{}, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 r.tableName,
+		Key:                       buildPollItemKey(id),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	res, err := r.db.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return DatabasePoll{}, r.pollConditionError(ctx, id, ErrPollAlreadyResolved)
+		}
+
+		return DatabasePoll{}, fmt.Errorf("calling UpdateItem for poll resolution: %w", err)
+	}
+
+	var resolvedPoll DatabasePoll
+	if err := attributevalue.UnmarshalMap(res.Attributes, &resolvedPoll); err != nil {
+		return DatabasePoll{}, fmt.Errorf("unmarshalling resolved poll item: %w", err)
+	}
+
+	return resolvedPoll, nil
+}
+
+// AddUserPoints records the outcome of a viewer's prediction against their running total,
+// creating the total if it is their first prediction. Each viewer is only paid out once per
+// prediction, ErrPointsAlreadyAwarded is returned if they already have been.
+func (r *repo) AddUserPoints(ctx context.Context, pollID string, userID string, points int, won bool, expiresAt *time.Time) error {
+	wins := 0
+	if won {
+		wins = 1
+	}
+
+	update := expression.
+		Set(expression.Name("itemType"), expression.Value("UserPoints")).
+		Set(expression.Name("userId"), expression.Value(userID)).
+		Add(expression.Name("points"), expression.Value(points)).
+		Add(expression.Name("predictionsMade"), expression.Value(1)).
+		Add(expression.Name("predictionsWon"), expression.Value(wins))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	return r.awardPoints(ctx, pollID, userID, expiresAt, types.Update{
+		TableName: r.tableName,
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: buildUserDatabaseKey(userID),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: _userPointsSortKey,
+			},
+		},
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+}
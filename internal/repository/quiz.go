@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrAnswerAlreadyRevealed = errors.New("quiz answer has already been revealed")
+
+// RevealPollAnswer marks a closed quiz's correct answers as public. Each quiz can only be revealed once.
+func (r *repo) RevealPollAnswer(ctx context.Context, id string) (DatabasePoll, error) {
+	condition := expression.AttributeExists(expression.Name("PK")).
+		And(expression.Name("status").Equal(expression.Value(PollStatusClosed))).
+		And(expression.AttributeNotExists(expression.Name("answerRevealed")))
+
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("answerRevealed"), expression.Value(true))).
+		WithCondition(condition).
+		Build()
+	if err != nil {
+		return DatabasePoll{}, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 r.tableName,
+		Key:                       buildPollItemKey(id),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              types.ReturnValueAllNew,
+	}
+
+	res, err := r.db.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return DatabasePoll{}, r.pollConditionError(ctx, id, ErrAnswerAlreadyRevealed)
+		}
+
+		return DatabasePoll{}, fmt.Errorf("calling UpdateItem for quiz reveal: %w", err)
+	}
+
+	var revealedPoll DatabasePoll
+	if err := attributevalue.UnmarshalMap(res.Attributes, &revealedPoll); err != nil {
+		return DatabasePoll{}, fmt.Errorf("unmarshalling revealed poll item: %w", err)
+	}
+
+	return revealedPoll, nil
+}
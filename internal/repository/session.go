@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/google/uuid"
+)
+
+var ErrSessionNotFound = errors.New("could not find session")
+
+const _leaderboardIndex = "LeaderboardIndex"
+
+type DatabaseSession struct {
+	PK                     string `dynamodbav:"PK"`
+	SK                     string `dynamodbav:"SK"`
+	ID                     string `dynamodbav:"id"`
+	ItemType               string `dynamodbav:"itemType"`
+	Name                   string `dynamodbav:"name"`
+	ChannelARN             string `dynamodbav:"channelARN"`
+	PointsPerCorrectAnswer int    `dynamodbav:"pointsPerCorrectAnswer"`
+	// SpeedBonus is the most extra points a correct answer can earn for being quick
+	SpeedBonus int    `dynamodbav:"speedBonus"`
+	StartedAt  string `dynamodbav:"startedAt"`
+}
+
+// DatabaseSessionScore is a viewer's running total across every quiz in a session
+type DatabaseSessionScore struct {
+	PK             string `dynamodbav:"PK"`
+	SK             string `dynamodbav:"SK"`
+	ItemType       string `dynamodbav:"itemType"`
+	SessionID      string `dynamodbav:"sessionId"`
+	UserID         string `dynamodbav:"userId"`
+	Score          int    `dynamodbav:"score"`
+	CorrectAnswers int    `dynamodbav:"correctAnswers"`
+}
+
+func (r *repo) GetSession(ctx context.Context, id string) (DatabaseSession, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: r.tableName,
+		Key:       buildSessionItemKey(id),
+	}
+
+	result, err := r.db.GetItem(ctx, input)
+	if err != nil {
+		return DatabaseSession{}, err
+	}
+
+	if result.Item == nil {
+		return DatabaseSession{}, ErrSessionNotFound
+	}
+
+	var foundSession DatabaseSession
+	if err := attributevalue.UnmarshalMap(result.Item, &foundSession); err != nil {
+		return DatabaseSession{}, fmt.Errorf("unmarshalling session item: %w", err)
+	}
+
+	return foundSession, nil
+}
+
+type NewSession struct {
+	Name                   string
+	ChannelARN             string
+	PointsPerCorrectAnswer int
+	SpeedBonus             int
+	StartedAt              time.Time
+}
+
+func (r *repo) CreateSession(ctx context.Context, session NewSession) (DatabaseSession, error) {
+	id := uuid.NewString()
+	sessionKey := buildSessionDatabaseKey(id)
+
+	dbSession := DatabaseSession{
+		PK:                     sessionKey,
+		SK:                     sessionKey,
+		ID:                     id,
+		ItemType:               "Session",
+		Name:                   session.Name,
+		ChannelARN:             session.ChannelARN,
+		PointsPerCorrectAnswer: session.PointsPerCorrectAnswer,
+		SpeedBonus:             session.SpeedBonus,
+		StartedAt:              formatTimestamp(session.StartedAt),
+	}
+
+	item, err := attributevalue.MarshalMap(dbSession)
+	if err != nil {
+		return DatabaseSession{}, fmt.Errorf("marshalling new session: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: r.tableName,
+		Item:      item,
+	}
+
+	if _, err := r.db.PutItem(ctx, input); err != nil {
+		return DatabaseSession{}, fmt.Errorf("calling PutItem for session: %w", err)
+	}
+
+	return dbSession, nil
+}
+
+// AddSessionScore adds the points a viewer earned on a quiz to their session score, creating the
+// score if it is their first. Each viewer is only scored once per quiz, ErrPointsAlreadyAwarded is
+// returned if they already have been.
+func (r *repo) AddSessionScore(ctx context.Context, sessionID string, pollID string, userID string, points int, expiresAt *time.Time) error {
+	update := expression.
+		Set(expression.Name("itemType"), expression.Value("SessionScore")).
+		Set(expression.Name("sessionId"), expression.Value(sessionID)).
+		Set(expression.Name("userId"), expression.Value(userID)).
+		Add(expression.Name("score"), expression.Value(points)).
+		Add(expression.Name("correctAnswers"), expression.Value(1))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	return r.awardPoints(ctx, pollID, userID, expiresAt, types.Update{
+		TableName: r.tableName,
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: buildSessionDatabaseKey(sessionID),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: buildUserDatabaseKey(userID),
+			},
+		},
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+}
+
+// ListSessionLeaderboard pages through a session's scores from highest to lowest. The returned
+// cursor is empty once the last page has been read.
+func (r *repo) ListSessionLeaderboard(ctx context.Context, sessionID string, limit int, cursor string) ([]DatabaseSessionScore, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyCond := expression.Key("sessionId").Equal(expression.Value(sessionID))
+
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 r.tableName,
+		IndexName:                 aws.String(_leaderboardIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(int32(limit)),
+		ExclusiveStartKey:         startKey,
+	}
+
+	res, err := r.db.Query(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("querying session leaderboard: %w", err)
+	}
+
+	var scores []DatabaseSessionScore
+	if err := attributevalue.UnmarshalListOfMaps(res.Items, &scores); err != nil {
+		return nil, "", fmt.Errorf("unmarshalling session scores: %w", err)
+	}
+
+	nextCursor, err := encodeCursor(res.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return scores, nextCursor, nil
+}
+
+func buildSessionItemKey(id string) map[string]types.AttributeValue {
+	sessionKey := buildSessionDatabaseKey(id)
+
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{
+			Value: sessionKey,
+		},
+		"SK": &types.AttributeValueMemberS{
+			Value: sessionKey,
+		},
+	}
+}
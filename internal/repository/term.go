@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// DatabasePollTerm counts how many votes on a free-text poll used the same normalized term
+type DatabasePollTerm struct {
+	PK       string `dynamodbav:"PK"`
+	SK       string `dynamodbav:"SK"`
+	ItemType string `dynamodbav:"itemType"`
+	PollID   string `dynamodbav:"pollId"`
+	Term     string `dynamodbav:"term"`
+	Count    int    `dynamodbav:"count"`
+}
+
+// DatabaseTermCount is an entry in a free-text poll's top terms
+type DatabaseTermCount struct {
+	Term  string `dynamodbav:"term"`
+	Count int    `dynamodbav:"count"`
+}
+
+// IncrementPollTerms adjusts the count of each term on a free-text poll, creating counts for terms
+// that haven't been seen before, and returns the new count of every term that was changed
+func (r *repo) IncrementPollTerms(ctx context.Context, pollID string, termIncrements map[string]int, counted CountedVoteChanges) (map[string]int, error) {
+	var updates []types.Update
+	var keys []map[string]types.AttributeValue
+
+	for term, incr := range termIncrements {
+		update := expression.
+			Set(expression.Name("itemType"), expression.Value("Term")).
+			Set(expression.Name("pollId"), expression.Value(pollID)).
+			Set(expression.Name("term"), expression.Value(term)).
+			Add(expression.Name("count"), expression.Value(incr))
+
+		expr, err := expression.NewBuilder().WithUpdate(update).Build()
+		if err != nil {
+			return nil, fmt.Errorf("building expression: %w", err)
+		}
+
+		key := map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: buildPollDatabaseKey(pollID),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: buildTermDatabaseKey(term),
+			},
+		}
+
+		updates = append(updates, types.Update{
+			TableName:                 r.tableName,
+			Key:                       key,
+			UpdateExpression:          expr.Update(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		})
+		keys = append(keys, key)
+	}
+
+	if err := r.transactCountedVoteChanges(ctx, pollID, updates, counted); err != nil {
+		if err == ErrVoteChangeAlreadyCounted {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("incrementing poll terms: %w", err)
+	}
+
+	newCounts := make(map[string]int, len(termIncrements))
+	if len(keys) == 0 {
+		return newCounts, nil
+	}
+
+	// transactions don't return the items they write so the new counts are read back
+	requestItems := map[string]types.KeysAndAttributes{*r.tableName: {Keys: keys, ConsistentRead: aws.Bool(true)}}
+	for attempt := 1; len(requestItems) > 0; attempt++ {
+		if attempt > _maxBatchAttempts {
+			return nil, fmt.Errorf("poll terms were still unprocessed after %d attempts", _maxBatchAttempts)
+		}
+
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * _batchRetryBackoff)
+		}
+
+		res, err := r.db.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: requestItems})
+		if err != nil {
+			return nil, fmt.Errorf("calling BatchGetItem for poll terms: %w", err)
+		}
+
+		var updatedTerms []DatabasePollTerm
+		if err := attributevalue.UnmarshalListOfMaps(res.Responses[*r.tableName], &updatedTerms); err != nil {
+			return nil, fmt.Errorf("unmarshalling poll terms: %w", err)
+		}
+
+		for _, t := range updatedTerms {
+			newCounts[t.Term] = t.Count
+		}
+
+		requestItems = res.UnprocessedKeys
+	}
+
+	return newCounts, nil
+}
+
+// ListPollTerms loads the count of every term used on a free-text poll
+func (r *repo) ListPollTerms(ctx context.Context, pollID string) ([]DatabasePollTerm, error) {
+	keyCond := expression.Key("PK").Equal(expression.Value(buildPollDatabaseKey(pollID))).
+		And(expression.Key("SK").BeginsWith(buildTermDatabaseKey("")))
+
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 r.tableName,
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	var terms []DatabasePollTerm
+	paginator := dynamodb.NewQueryPaginator(r.db, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("querying poll terms: %w", err)
+		}
+
+		var pageTerms []DatabasePollTerm
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageTerms); err != nil {
+			return nil, fmt.Errorf("unmarshalling poll terms: %w", err)
+		}
+
+		terms = append(terms, pageTerms...)
+	}
+
+	return terms, nil
+}
+
+// UpdatePollTopTerms replaces the most used terms stored on a free-text poll
+func (r *repo) UpdatePollTopTerms(ctx context.Context, pollID string, topTerms []DatabaseTermCount) error {
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("topTerms"), expression.Value(topTerms))).
+		WithCondition(expression.AttributeExists(expression.Name("PK"))).
+		Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 r.tableName,
+		Key:                       buildPollItemKey(pollID),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	if _, err := r.db.UpdateItem(ctx, input); err != nil {
+		return fmt.Errorf("calling UpdateItem for poll top terms: %w", err)
+	}
+
+	return nil
+}
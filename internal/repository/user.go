@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// votes are indexed by the user who cast them and when, votes cast before votedAt was recorded are
+// left out of the index
+const _userVotesIndex = "UserVotesIndex"
+
+// ListVotesByUser pages through every vote a user has cast across all polls, newest first. The
+// returned cursor is empty once the last page has been read.
+func (r *repo) ListVotesByUser(ctx context.Context, userID string, limit int, cursor string) ([]DatabasePollVote, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key("userId").Equal(expression.Value(userID))).
+		Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 r.tableName,
+		IndexName:                 aws.String(_userVotesIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(int32(limit)),
+		ExclusiveStartKey:         startKey,
+	}
+
+	res, err := r.db.Query(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("querying user votes: %w", err)
+	}
+
+	var votes []DatabasePollVote
+	if err := attributevalue.UnmarshalListOfMaps(res.Items, &votes); err != nil {
+		return nil, "", fmt.Errorf("unmarshalling user votes: %w", err)
+	}
+
+	nextCursor, err := encodeCursor(res.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return votes, nextCursor, nil
+}
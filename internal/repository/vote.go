@@ -2,15 +2,31 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/google/uuid"
 )
 
+var ErrUnknownPollAnswer = errors.New("answer is not one of the poll's options")
+var ErrVoteNotFound = errors.New("could not find vote")
+var ErrVoteAlreadyExists = errors.New("user has already voted")
+
+const (
+	// VotePolicySingle allows one vote per user that cannot be changed
+	VotePolicySingle = "single"
+	// VotePolicyChangeable allows one vote per user that can be changed or retracted
+	VotePolicyChangeable = "changeable"
+	// VotePolicyUnlimited allows a user to vote as many times as they like
+	VotePolicyUnlimited = "unlimited"
+)
+
 type DatabasePollVote struct {
 	PK       string `dynamodbav:"PK"`
 	SK       string `dynamodbav:"SK"`
@@ -18,92 +34,501 @@ type DatabasePollVote struct {
 	ItemType string `dynamodbav:"itemType"`
 	PollID   string `dynamodbav:"pollId"`
 	UserID   string `dynamodbav:"userId"`
-	Answer   string `dynamodbav:"answer"`
+	Answer   string `dynamodbav:"answer,omitempty"`
+	// Answers holds every option chosen in a multi-select vote
+	Answers []string `dynamodbav:"answers,stringset,omitempty"`
+	// Ranking holds the full ballot of a ranked-choice vote, most preferred option first
+	Ranking []string `dynamodbav:"ranking,omitempty"`
+	// Correct records whether a quiz vote chose a correct answer
+	Correct *bool `dynamodbav:"correct,omitempty"`
+	// Value is the number chosen in a scale poll vote
+	Value *int `dynamodbav:"value,omitempty"`
+	// Tier is the viewer's tier when they voted and Weight is how many votes it counted as
+	Tier    string `dynamodbav:"tier,omitempty"`
+	Weight  int    `dynamodbav:"weight,omitempty"`
+	VotedAt string `dynamodbav:"votedAt,omitempty"`
+	// ExpiresAt is the TTL of the vote in seconds since the epoch
+	ExpiresAt int64 `dynamodbav:"expiresAt,omitempty"`
+	// IPHash and Fingerprint identify where the vote came from without storing the address or user
+	// agent themselves
+	IPHash      string `dynamodbav:"ipHash,omitempty"`
+	Fingerprint string `dynamodbav:"fingerprint,omitempty"`
+	// FraudStatus is empty until a vote has been quarantined, quarantined votes are not counted
+	FraudStatus string `dynamodbav:"fraudStatus,omitempty"`
+	// FraudSignals are the reasons the vote was quarantined
+	FraudSignals []string `dynamodbav:"fraudSignals,omitempty"`
+	// QuarantinedPoll is a copy of the poll ID that is only set whilst the vote is quarantined
+	QuarantinedPoll string `dynamodbav:"quarantinedPoll,omitempty"`
 }
 
 type NewPollVote struct {
-	PollID string
-	UserID string
-	Answer string
+	PollID  string
+	UserID  string
+	Answer  string
+	Answers []string
+	Ranking []string
+	Correct *bool
+	Value   *int
+	Tier    string
+	Weight  int
+	Policy  string
+	VotedAt time.Time
+	// ExpiresAt is when the vote should be removed from the table, votes expire with their poll
+	ExpiresAt *time.Time
+	// IPHash and Fingerprint are hashes of where the vote came from, used to spot vote stuffing
+	IPHash      string
+	Fingerprint string
+	// FirstVote is set whilst the poll isn't known to have any votes. The vote is then written in
+	// the same transaction as marking the poll as voted on, as long as the poll is still at
+	// PollVersion, so it can't race an edit to the poll's options.
+	FirstVote   bool
+	PollVersion int
 }
 
-func (r *repo) CreatePollVote(ctx context.Context, v NewPollVote) (DatabasePollVote, error) {
+// buildVoteSortKey places a vote within its poll, every vote on a poll that allows unlimited votes
+// gets its own item so they don't overwrite each other
+func buildVoteSortKey(userID string, voteID string, policy string) string {
+	if policy == VotePolicyUnlimited {
+		return buildUserVoteDatabaseKey(userID, voteID)
+	}
+
+	return buildUserDatabaseKey(userID)
+}
+
+// CreatePollVote stores a user's vote according to the poll's vote policy. replaced reports
+// whether the vote changed an earlier vote from the same user.
+func (r *repo) CreatePollVote(ctx context.Context, v NewPollVote) (vote DatabasePollVote, replaced bool, err error) {
 	id := uuid.NewString()
 	pollKey := buildPollDatabaseKey(v.PollID)
-	userKey := buildUserDatabaseKey(v.UserID)
+	userKey := buildVoteSortKey(v.UserID, id, v.Policy)
 
 	dbVote := DatabasePollVote{
-		PK:       pollKey,
-		SK:       userKey,
-		ID:       id,
-		ItemType: "Vote",
-		PollID:   v.PollID,
-		UserID:   v.UserID,
-		Answer:   v.Answer,
+		PK:          pollKey,
+		SK:          userKey,
+		ID:          id,
+		ItemType:    "Vote",
+		PollID:      v.PollID,
+		UserID:      v.UserID,
+		Answer:      v.Answer,
+		Answers:     v.Answers,
+		Ranking:     v.Ranking,
+		Correct:     v.Correct,
+		Value:       v.Value,
+		Tier:        v.Tier,
+		Weight:      v.Weight,
+		VotedAt:     formatTimestamp(v.VotedAt),
+		ExpiresAt:   formatExpiry(v.ExpiresAt),
+		IPHash:      v.IPHash,
+		Fingerprint: v.Fingerprint,
 	}
 
 	item, err := attributevalue.MarshalMap(dbVote)
 	if err != nil {
-		return DatabasePollVote{}, fmt.Errorf("marshalling new povotell: %w", err)
+		return DatabasePollVote{}, false, fmt.Errorf("marshalling new povotell: %w", err)
+	}
+
+	if v.FirstVote {
+		err := r.createFirstPollVote(ctx, v.PollID, v.PollVersion, item)
+		if err == nil {
+			return dbVote, false, nil
+		}
+
+		// the poll already has a vote from the user, which a changeable vote replaces as usual
+		if err != ErrVoteAlreadyExists || v.Policy != VotePolicyChangeable {
+			return DatabasePollVote{}, false, err
+		}
 	}
 
 	input := &dynamodb.PutItemInput{
+		TableName:    r.tableName,
+		Item:         item,
+		ReturnValues: types.ReturnValueAllOld,
+	}
+
+	if v.Policy != VotePolicyChangeable {
+		expr, err := expression.NewBuilder().
+			WithCondition(expression.AttributeNotExists(expression.Name("PK"))).
+			Build()
+		if err != nil {
+			return DatabasePollVote{}, false, fmt.Errorf("building expression: %w", err)
+		}
+
+		input.ConditionExpression = expr.Condition()
+		input.ExpressionAttributeNames = expr.Names()
+	}
+
+	res, err := r.db.PutItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return DatabasePollVote{}, false, ErrVoteAlreadyExists
+		}
+
+		return DatabasePollVote{}, false, fmt.Errorf("calling PutItem for vote: %w", err)
+	}
+
+	return dbVote, len(res.Attributes) > 0, nil
+}
+
+// createFirstPollVote writes a vote along with marking its poll as voted on, as long as the poll
+// hasn't been edited since the given version. ErrVoteAlreadyExists is returned when the user has
+// already voted on the poll.
+func (r *repo) createFirstPollVote(ctx context.Context, pollID string, pollVersion int, item map[string]types.AttributeValue) error {
+	voteExpr, err := expression.NewBuilder().
+		WithCondition(expression.AttributeNotExists(expression.Name("PK"))).
+		Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	pollExpr, err := expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("hasVotes"), expression.Value(true))).
+		WithCondition(expression.AttributeExists(expression.Name("PK")).And(versionCondition(pollVersion))).
+		Build()
+	if err != nil {
+		return fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:                r.tableName,
+					Item:                     item,
+					ConditionExpression:      voteExpr.Condition(),
+					ExpressionAttributeNames: voteExpr.Names(),
+				},
+			},
+			{
+				Update: &types.Update{
+					TableName:                 r.tableName,
+					Key:                       buildPollItemKey(pollID),
+					UpdateExpression:          pollExpr.Update(),
+					ConditionExpression:       pollExpr.Condition(),
+					ExpressionAttributeNames:  pollExpr.Names(),
+					ExpressionAttributeValues: pollExpr.Values(),
+				},
+			},
+		},
+	}
+
+	if _, err := r.db.TransactWriteItems(ctx, input); err != nil {
+		var cancelledErr *types.TransactionCanceledException
+		if errors.As(err, &cancelledErr) && len(cancelledErr.CancellationReasons) == 2 {
+			if aws.StringValue(cancelledErr.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+				return r.pollConditionError(ctx, pollID, ErrPollVersionConflict)
+			}
+
+			if aws.StringValue(cancelledErr.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+				return ErrVoteAlreadyExists
+			}
+		}
+
+		return fmt.Errorf("calling TransactWriteItems for first vote: %w", err)
+	}
+
+	return nil
+}
+
+// GetPollVote loads a user's vote on a poll. Users can have several votes on polls that allow
+// unlimited votes, in which case their most recent vote is returned.
+func (r *repo) GetPollVote(ctx context.Context, pollID string, userID string) (DatabasePollVote, error) {
+	input := &dynamodb.GetItemInput{
 		TableName: r.tableName,
-		Item:      item,
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: buildPollDatabaseKey(pollID),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: buildUserDatabaseKey(userID),
+			},
+		},
 	}
 
-	_, err = r.db.PutItem(ctx, input)
+	res, err := r.db.GetItem(ctx, input)
 	if err != nil {
-		return DatabasePollVote{}, fmt.Errorf("calling PutItem for vote: %w", err)
+		return DatabasePollVote{}, fmt.Errorf("calling GetItem for vote: %w", err)
+	}
+
+	if res.Item != nil {
+		var vote DatabasePollVote
+		if err := attributevalue.UnmarshalMap(res.Item, &vote); err != nil {
+			return DatabasePollVote{}, fmt.Errorf("unmarshalling vote: %w", err)
+		}
+
+		return vote, nil
+	}
+
+	keyCond := expression.Key("PK").Equal(expression.Value(buildPollDatabaseKey(pollID))).
+		And(expression.Key("SK").BeginsWith(buildUserVoteDatabaseKey(userID, "")))
+
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return DatabasePollVote{}, fmt.Errorf("building expression: %w", err)
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:                 r.tableName,
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	var latest *DatabasePollVote
+	paginator := dynamodb.NewQueryPaginator(r.db, queryInput)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return DatabasePollVote{}, fmt.Errorf("querying user votes: %w", err)
+		}
+
+		var votes []DatabasePollVote
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &votes); err != nil {
+			return DatabasePollVote{}, fmt.Errorf("unmarshalling user votes: %w", err)
+		}
+
+		for i := range votes {
+			// votedAt sorts chronologically as it is stored in UTC
+			if latest == nil || votes[i].VotedAt > latest.VotedAt {
+				latest = &votes[i]
+			}
+		}
+	}
+
+	if latest == nil {
+		return DatabasePollVote{}, ErrVoteNotFound
 	}
 
-	return dbVote, nil
+	return *latest, nil
+}
+
+// DeletePollVote removes a user's vote from a poll, returning the vote that was removed
+func (r *repo) DeletePollVote(ctx context.Context, pollID string, userID string) (DatabasePollVote, error) {
+	expr, err := expression.NewBuilder().
+		WithCondition(expression.AttributeExists(expression.Name("PK"))).
+		Build()
+	if err != nil {
+		return DatabasePollVote{}, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.DeleteItemInput{
+		TableName: r.tableName,
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: buildPollDatabaseKey(pollID),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: buildUserDatabaseKey(userID),
+			},
+		},
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+		ReturnValues:             types.ReturnValueAllOld,
+	}
+
+	res, err := r.db.DeleteItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return DatabasePollVote{}, ErrVoteNotFound
+		}
+
+		return DatabasePollVote{}, fmt.Errorf("calling DeleteItem for vote: %w", err)
+	}
+
+	var deletedVote DatabasePollVote
+	if err := attributevalue.UnmarshalMap(res.Attributes, &deletedVote); err != nil {
+		return DatabasePollVote{}, fmt.Errorf("unmarshalling deleted vote: %w", err)
+	}
+
+	return deletedVote, nil
+}
+
+// HasPollVotes checks whether anybody has voted on a poll without loading the votes
+func (r *repo) HasPollVotes(ctx context.Context, pollID string) (bool, error) {
+	keyCond := expression.Key("PK").Equal(expression.Value(buildPollDatabaseKey(pollID))).
+		And(expression.Key("SK").BeginsWith(buildUserDatabaseKey("")))
+
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return false, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 r.tableName,
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Select:                    types.SelectCount,
+		Limit:                     aws.Int32(1),
+	}
+
+	res, err := r.db.Query(ctx, input)
+	if err != nil {
+		return false, fmt.Errorf("querying poll votes: %w", err)
+	}
+
+	return res.Count > 0, nil
+}
+
+// ListPollVotes loads every vote stored against a poll, leaving out quarantined votes so they are
+// never counted
+func (r *repo) ListPollVotes(ctx context.Context, pollID string) ([]DatabasePollVote, error) {
+	keyCond := expression.Key("PK").Equal(expression.Value(buildPollDatabaseKey(pollID))).
+		And(expression.Key("SK").BeginsWith(buildUserDatabaseKey("")))
+
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(keyCond).
+		WithFilter(expression.AttributeNotExists(expression.Name("fraudStatus")).
+			Or(expression.Name("fraudStatus").NotEqual(expression.Value(VoteFraudStatusQuarantined)))).
+		Build()
+	if err != nil {
+		return nil, fmt.Errorf("building expression: %w", err)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 r.tableName,
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	var votes []DatabasePollVote
+	paginator := dynamodb.NewQueryPaginator(r.db, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("querying poll votes: %w", err)
+		}
+
+		var pageVotes []DatabasePollVote
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageVotes); err != nil {
+			return nil, fmt.Errorf("unmarshalling poll votes: %w", err)
+		}
+
+		votes = append(votes, pageVotes...)
+	}
+
+	return votes, nil
 }
 
 type updateItemResponse struct {
 	AggregatedVoteTotals DatabasePollTotals `json:"aggregatedVoteTotals"`
+	WeightedVoteTotals   DatabasePollTotals `json:"weightedVoteTotals"`
+	ScaleStats           DatabaseScaleStats `json:"scaleStats"`
+}
+
+// pollTotalsIncrement is every running total that moves when votes on a poll change
+type pollTotalsIncrement struct {
+	answers  DatabasePollTotals
+	weighted DatabasePollTotals
+	scale    *DatabaseScaleStats
+}
+
+func (r *repo) IncrementPollTotals(ctx context.Context, pollID string, answerIncrements DatabasePollTotals, counted CountedVoteChanges) (DatabasePollTotals, error) {
+	res, err := r.incrementPollTotals(ctx, pollID, pollTotalsIncrement{answers: answerIncrements}, counted)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.AggregatedVoteTotals, nil
+}
+
+// IncrementWeightedPollTotals moves a poll's raw vote counts and its weighted totals in the same
+// write so the two never disagree about which votes have been counted
+func (r *repo) IncrementWeightedPollTotals(ctx context.Context, pollID string, answerIncrements DatabasePollTotals, weightedIncrements DatabasePollTotals, counted CountedVoteChanges) (DatabasePollTotals, DatabasePollTotals, error) {
+	res, err := r.incrementPollTotals(ctx, pollID, pollTotalsIncrement{answers: answerIncrements, weighted: weightedIncrements}, counted)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res.AggregatedVoteTotals, res.WeightedVoteTotals, nil
 }
 
-func (r *repo) IncrementPollTotals(ctx context.Context, pollID string, answerIncrements DatabasePollTotals) (DatabasePollTotals, error) {
+// IncrementScalePollTotals moves a scale poll's histogram and adjusts its running count and sum in
+// the same write so the statistics never drift from the histogram
+func (r *repo) IncrementScalePollTotals(ctx context.Context, pollID string, valueIncrements DatabasePollTotals, statsIncrement DatabaseScaleStats, counted CountedVoteChanges) (DatabasePollTotals, DatabaseScaleStats, error) {
+	res, err := r.incrementPollTotals(ctx, pollID, pollTotalsIncrement{answers: valueIncrements, scale: &statsIncrement}, counted)
+	if err != nil {
+		return nil, DatabaseScaleStats{}, err
+	}
+
+	return res.AggregatedVoteTotals, res.ScaleStats, nil
+}
+
+func (r *repo) incrementPollTotals(ctx context.Context, pollID string, increment pollTotalsIncrement, counted CountedVoteChanges) (updateItemResponse, error) {
 	pollKey := buildPollDatabaseKey(pollID)
 
-	input, err := r.getPollAnswerIncrementInput(pollKey, answerIncrements)
+	update, err := r.getPollAnswerIncrementUpdate(pollKey, increment)
 	if err != nil {
-		return nil, fmt.Errorf("creating increment update input %w", err)
+		return updateItemResponse{}, fmt.Errorf("creating increment update %w", err)
+	}
+
+	if err := r.transactCountedVoteChanges(ctx, pollID, []types.Update{update}, counted); err != nil {
+		if err == ErrUnknownPollAnswer || err == ErrVoteChangeAlreadyCounted {
+			return updateItemResponse{}, err
+		}
+
+		return updateItemResponse{}, fmt.Errorf("updating vote aggregate totals %w", err)
 	}
 
-	res, err := r.db.UpdateItem(ctx, input)
+	// transactions don't return the items they write so the new totals are read back, scale
+	// statistics like the median need the whole histogram anyway
+	input := &dynamodb.GetItemInput{
+		TableName:            r.tableName,
+		Key:                  buildPollItemKey(pollID),
+		ConsistentRead:       aws.Bool(true),
+		ProjectionExpression: aws.String("aggregatedVoteTotals, weightedVoteTotals, scaleStats"),
+	}
+
+	res, err := r.db.GetItem(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("updating vote aggregate totals %w", err)
+		return updateItemResponse{}, fmt.Errorf("getting vote aggregate totals %w", err)
 	}
 
 	var updateItemRes updateItemResponse
-	if err := attributevalue.UnmarshalMap(res.Attributes, &updateItemRes); err != nil {
-		return nil, fmt.Errorf("unmarshalling update item output attributes %w", err)
-
+	if err := attributevalue.UnmarshalMap(res.Item, &updateItemRes); err != nil {
+		return updateItemResponse{}, fmt.Errorf("unmarshalling vote aggregate totals %w", err)
 	}
 
-	return updateItemRes.AggregatedVoteTotals, nil
+	return updateItemRes, nil
 }
 
-func (r *repo) getPollAnswerIncrementInput(pollKey string, pollAnswerIncrements DatabasePollTotals) (*dynamodb.UpdateItemInput, error) {
+func (r *repo) getPollAnswerIncrementUpdate(pollKey string, increment pollTotalsIncrement) (types.Update, error) {
 	builder := expression.UpdateBuilder{}
+	// every answer must already have a total, this stops unknown answers creating new totals
+	condition := expression.AttributeExists(expression.Name("PK"))
+
+	totals := map[string]DatabasePollTotals{
+		"aggregatedVoteTotals": increment.answers,
+		"weightedVoteTotals":   increment.weighted,
+	}
+
+	for totalsName, increments := range totals {
+		for answerID, incr := range increments {
+			attrName := fmt.Sprintf("%s.%s", totalsName, answerID)
 
-	for answerID, incr := range pollAnswerIncrements {
-		attrName := fmt.Sprintf("aggregatedVoteTotals.%s", answerID)
+			builder = builder.Set(
+				expression.Name(attrName),
+				expression.Name(attrName).Plus(expression.Value(incr)),
+			)
+			condition = condition.And(expression.AttributeExists(expression.Name(attrName)))
+		}
+	}
 
-		builder = builder.Set(
-			expression.Name(attrName),
-			expression.Name(attrName).Plus(expression.Value(incr)),
-		)
+	if statsIncrement := increment.scale; statsIncrement != nil {
+		builder = builder.
+			Set(expression.Name("scaleStats.count"), expression.Name("scaleStats.count").Plus(expression.Value(statsIncrement.Count))).
+			Set(expression.Name("scaleStats.sum"), expression.Name("scaleStats.sum").Plus(expression.Value(statsIncrement.Sum)))
 	}
 
-	expr, err := expression.NewBuilder().WithUpdate(builder).Build()
+	expr, err := expression.NewBuilder().WithUpdate(builder).WithCondition(condition).Build()
 	if err != nil {
-		return nil, fmt.Errorf("building expression: %w", err)
+		return types.Update{}, fmt.Errorf("building expression: %w", err)
 	}
 
-	return &dynamodb.UpdateItemInput{
+	return types.Update{
 		TableName: r.tableName,
 		Key: map[string]types.AttributeValue{
 			"PK": &types.AttributeValueMemberS{
@@ -117,6 +542,5 @@ func (r *repo) getPollAnswerIncrementInput(pollKey string, pollAnswerIncrements
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 		ConditionExpression:       expr.Condition(),
-		ReturnValues:              types.ReturnValueUpdatedNew,
 	}, nil
 }
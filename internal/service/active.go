@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+)
+
+// the full poll is re-broadcast at most this often whilst votes are coming in, so players that
+// joined after the poll opened can render it
+const _definitionRebroadcastInterval = 30 * time.Second
+
+// broadcastPollDefinition carries everything a player needs to render a poll it missed being
+// opened, along with the current results
+type broadcastPollDefinition struct {
+	broadcastPollOpened
+	AggregatedVoteTotals map[string]int `json:"aggregatedVoteTotals"`
+	WeightedVoteTotals   map[string]int `json:"weightedVoteTotals,omitempty"`
+	Scale                *ScaleSummary  `json:"scaleSummary,omitempty"`
+	Terms                []TermCount    `json:"terms,omitempty"`
+	ClosesAt             *time.Time     `json:"closesAt,omitempty"`
+}
+
+// ListActivePolls finds the polls on a channel that viewers can currently vote on
+func (s *service) ListActivePolls(ctx context.Context, channelARN string) ([]Poll, error) {
+	dbPolls, err := s.repo.ListActivePolls(ctx, channelARN)
+	if err != nil {
+		return nil, fmt.Errorf("listing active polls: %w", err)
+	}
+
+	polls := []Poll{}
+	for _, p := range dbPolls {
+		poll := mapDatabasePollToPoll(p)
+
+		// polls past their deadline are only open until the next scheduled close runs
+		if s.isAcceptingVotes(poll) {
+			polls = append(polls, poll)
+		}
+	}
+
+	return polls, nil
+}
+
+// rebroadcastPollDefinition sends the full poll to its channel if it hasn't been sent recently,
+// along with the leaderboard of the session it is part of
+func (s *service) rebroadcastPollDefinition(ctx context.Context, pollID string) error {
+	now := s.now()
+
+	dbPoll, ok, err := s.repo.ClaimDefinitionBroadcast(ctx, pollID, now, now.Add(-_definitionRebroadcastInterval))
+	if err != nil {
+		return fmt.Errorf("claiming poll definition broadcast: %w", err)
+	}
+
+	if !ok {
+		return nil
+	}
+
+	poll := mapDatabasePollToPoll(dbPoll)
+
+	definition := broadcastPollDefinition{
+		broadcastPollOpened:  newBroadcastPollOpened(poll),
+		AggregatedVoteTotals: poll.AggregatedVoteTotals,
+		WeightedVoteTotals:   poll.WeightedVoteTotals,
+		Scale:                poll.ScaleSummary(),
+		ClosesAt:             poll.ClosesAt,
+	}
+
+	if poll.Type == PollTypeFreeText {
+		definition.Terms = poll.TopTerms
+	}
+
+	metadata := broadcast.CreateTypedMetadata(broadcast.EnvelopePollDefinition, definition)
+
+	if err := s.broadcastMetadata(ctx, poll.ChannelARN, metadata); err != nil {
+		return err
+	}
+
+	// viewers who joined part way through a session get its leaderboard along with the poll
+	if poll.SessionID != "" {
+		return s.broadcastSessionLeaderboard(ctx, poll.SessionID, poll.ChannelARN)
+	}
+
+	return nil
+}
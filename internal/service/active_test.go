@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+func (r *fakeRepo) ClaimDefinitionBroadcast(ctx context.Context, pollID string, now time.Time, since time.Time) (repository.DatabasePoll, bool, error) {
+	p := r.polls[pollID]
+	if p.Status != repository.PollStatusOpen {
+		return repository.DatabasePoll{}, false, nil
+	}
+
+	if last, err := repository.ParseTimestamp(p.DefinitionBroadcastAt); err == nil && last.After(since) {
+		return repository.DatabasePoll{}, false, nil
+	}
+
+	p.DefinitionBroadcastAt = now.Format(time.RFC3339)
+	r.polls[pollID] = p
+
+	return p, true, nil
+}
+
+func TestRebroadcastPollDefinition(t *testing.T) {
+	now := time.Date(2022, 7, 10, 12, 0, 0, 0, time.UTC)
+
+	repo := newFakeRepo(repository.DatabasePoll{
+		ID:                   "quiz",
+		Type:                 repository.PollTypeQuiz,
+		ChannelARN:           "channel",
+		Status:               repository.PollStatusOpen,
+		Options:              []repository.DatabasePollOption{{ID: "a", Label: "A", Correct: true}, {ID: "b", Label: "B"}},
+		AggregatedVoteTotals: map[string]int{"a": 2, "b": 1},
+	})
+	broadcaster := &fakeBroadcaster{}
+
+	for _, offset := range []time.Duration{0, 10 * time.Second, 31 * time.Second} {
+		svc := New(repo, broadcaster, WithClock(fixedClock(now.Add(offset))))
+
+		if err := svc.rebroadcastPollDefinition(context.Background(), "quiz"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	messages := broadcaster.messages["channel"]
+	if len(messages) != 2 {
+		t.Fatalf("expected the definition to be broadcast twice, got %d broadcasts", len(messages))
+	}
+
+	if !strings.Contains(messages[0], `"type":"poll-definition"`) || !strings.Contains(messages[0], `"aggregatedVoteTotals":{"a":2,"b":1}`) {
+		t.Errorf("expected the full poll with its totals, got %s", messages[0])
+	}
+
+	if strings.Contains(messages[0], "correct") {
+		t.Errorf("expected the quiz answer to stay hidden, got %s", messages[0])
+	}
+}
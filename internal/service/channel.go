@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+type PollPage struct {
+	Polls []Poll
+	// Cursor fetches the next page of polls, it is empty on the last page
+	Cursor string
+}
+
+// ListChannelPolls pages through a channel's polls, newest first. Only polls in one of the given
+// statuses are included, or every poll when no statuses are given.
+func (s *service) ListChannelPolls(ctx context.Context, channelARN string, statuses []PollStatus, limit int, cursor string) (PollPage, error) {
+	var dbStatuses []string
+	for _, status := range statuses {
+		dbStatuses = append(dbStatuses, string(status))
+	}
+
+	dbPolls, next, err := s.repo.ListPollsByChannel(ctx, channelARN, dbStatuses, limit, cursor)
+	if err != nil {
+		if err == repository.ErrInvalidCursor {
+			return PollPage{}, ErrInvalidCursor
+		}
+
+		return PollPage{}, fmt.Errorf("listing channel polls: %w", err)
+	}
+
+	page := PollPage{
+		Polls:  []Poll{},
+		Cursor: next,
+	}
+
+	for _, p := range dbPolls {
+		page.Polls = append(page.Polls, mapDatabasePollToPoll(p))
+	}
+
+	return page, nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+var ErrInvalidAPIKey = errors.New("api key is not valid")
+var ErrNotChannelOwner = errors.New("creator does not own the channel")
+var ErrChannelAlreadyOwned = errors.New("channel is owned by another creator")
+
+// API keys are 32 random bytes, which is far too many to guess
+const _apiKeyBytes = 32
+
+// Creator is somebody who runs polls on the channels they own
+type Creator struct {
+	ID string
+}
+
+type ChannelOwner struct {
+	ChannelARN string
+	OwnerID    string
+}
+
+// CreateAPIKey generates a new API key for a creator. The key is only ever returned here, just
+// its hash is kept.
+func (s *service) CreateAPIKey(ctx context.Context, creatorID string) (string, error) {
+	raw := make([]byte, _apiKeyBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("generating api key: %w", err)
+	}
+
+	apiKey := base64.RawURLEncoding.EncodeToString(raw)
+
+	if err := s.repo.CreateAPIKey(ctx, hashAPIKey(apiKey), creatorID, s.now()); err != nil {
+		return "", fmt.Errorf("creating api key: %w", err)
+	}
+
+	return apiKey, nil
+}
+
+// AuthenticateCreator finds the creator an API key belongs to
+func (s *service) AuthenticateCreator(ctx context.Context, apiKey string) (Creator, error) {
+	if apiKey == "" {
+		return Creator{}, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.GetAPIKey(ctx, hashAPIKey(apiKey))
+	if err != nil {
+		if err == repository.ErrAPIKeyNotFound {
+			return Creator{}, ErrInvalidAPIKey
+		}
+
+		return Creator{}, fmt.Errorf("getting api key: %w", err)
+	}
+
+	return Creator{ID: key.CreatorID}, nil
+}
+
+// ClaimChannel makes the creator the owner of a channel, as long as nobody else already owns it
+func (s *service) ClaimChannel(ctx context.Context, channelARN string, creatorID string) (ChannelOwner, error) {
+	owner, err := s.repo.ClaimChannel(ctx, channelARN, creatorID, s.now())
+	if err != nil {
+		if err == repository.ErrChannelAlreadyOwned {
+			return ChannelOwner{}, ErrChannelAlreadyOwned
+		}
+
+		return ChannelOwner{}, fmt.Errorf("claiming channel: %w", err)
+	}
+
+	return ChannelOwner{ChannelARN: owner.Channel, OwnerID: owner.OwnerID}, nil
+}
+
+// AuthorizeChannel checks that the creator owns the channel, channels nobody has claimed can't be
+// used by anyone
+func (s *service) AuthorizeChannel(ctx context.Context, channelARN string, creatorID string) error {
+	owner, err := s.repo.GetChannelOwner(ctx, channelARN)
+	if err != nil {
+		if err == repository.ErrChannelOwnerNotFound {
+			return ErrNotChannelOwner
+		}
+
+		return fmt.Errorf("getting channel owner: %w", err)
+	}
+
+	if owner.OwnerID != creatorID {
+		return ErrNotChannelOwner
+	}
+
+	return nil
+}
+
+// OwnsChannel checks whether an API key belongs to the owner of a channel. Missing and invalid keys
+// aren't an error, they just don't own the channel, so public endpoints can use it to decide
+// whether to show a channel's drafts.
+func (s *service) OwnsChannel(ctx context.Context, apiKey string, channelARN string) (bool, error) {
+	if apiKey == "" {
+		return false, nil
+	}
+
+	creator, err := s.AuthenticateCreator(ctx, apiKey)
+	if err != nil {
+		if err == ErrInvalidAPIKey {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	if err := s.AuthorizeChannel(ctx, channelARN, creator.ID); err != nil {
+		if err == ErrNotChannelOwner {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// AuthorizePoll checks that the creator owns the channel the poll is running on. Ownership follows
+// the channel rather than whoever made the poll, so polls made before creators had to authenticate
+// are managed by the channel's owner too.
+func (s *service) AuthorizePoll(ctx context.Context, pollID string, creatorID string) error {
+	poll, err := s.GetPoll(ctx, pollID)
+	if err != nil {
+		return err
+	}
+
+	return s.AuthorizeChannel(ctx, poll.ChannelARN, creatorID)
+}
+
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+// fakeCreatorRepo keeps API keys and channel owners alongside the polls of a fakeRepo
+type fakeCreatorRepo struct {
+	*fakeRepo
+	apiKeys map[string]repository.DatabaseAPIKey
+	owners  map[string]repository.DatabaseChannelOwner
+}
+
+func newFakeCreatorRepo(polls ...repository.DatabasePoll) *fakeCreatorRepo {
+	return &fakeCreatorRepo{
+		fakeRepo: newFakeRepo(polls...),
+		apiKeys:  make(map[string]repository.DatabaseAPIKey),
+		owners:   make(map[string]repository.DatabaseChannelOwner),
+	}
+}
+
+func (r *fakeCreatorRepo) CreateAPIKey(ctx context.Context, keyHash string, creatorID string, createdAt time.Time) error {
+	r.apiKeys[keyHash] = repository.DatabaseAPIKey{CreatorID: creatorID}
+
+	return nil
+}
+
+func (r *fakeCreatorRepo) GetAPIKey(ctx context.Context, keyHash string) (repository.DatabaseAPIKey, error) {
+	key, ok := r.apiKeys[keyHash]
+	if !ok {
+		return repository.DatabaseAPIKey{}, repository.ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+func (r *fakeCreatorRepo) GetChannelOwner(ctx context.Context, channelARN string) (repository.DatabaseChannelOwner, error) {
+	owner, ok := r.owners[channelARN]
+	if !ok {
+		return repository.DatabaseChannelOwner{}, repository.ErrChannelOwnerNotFound
+	}
+
+	return owner, nil
+}
+
+func (r *fakeCreatorRepo) ClaimChannel(ctx context.Context, channelARN string, ownerID string, claimedAt time.Time) (repository.DatabaseChannelOwner, error) {
+	if owner, ok := r.owners[channelARN]; ok {
+		if owner.OwnerID != ownerID {
+			return repository.DatabaseChannelOwner{}, repository.ErrChannelAlreadyOwned
+		}
+
+		return owner, nil
+	}
+
+	r.owners[channelARN] = repository.DatabaseChannelOwner{Channel: channelARN, OwnerID: ownerID}
+
+	return r.owners[channelARN], nil
+}
+
+func TestAuthenticateCreator(t *testing.T) {
+	repo := newFakeCreatorRepo()
+	svc := New(repo, &fakeBroadcaster{})
+
+	apiKey, err := svc.CreateAPIKey(context.Background(), "creator-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, ok := repo.apiKeys[apiKey]; ok {
+		t.Errorf("expected the api key to be stored hashed")
+	}
+
+	creator, err := svc.AuthenticateCreator(context.Background(), apiKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if creator.ID != "creator-1" {
+		t.Errorf("expected creator-1, got %s", creator.ID)
+	}
+
+	for _, key := range []string{"", "not-a-real-key", apiKey + "x"} {
+		if _, err := svc.AuthenticateCreator(context.Background(), key); err != ErrInvalidAPIKey {
+			t.Errorf("expected %q to be rejected with ErrInvalidAPIKey, got %v", key, err)
+		}
+	}
+}
+
+func TestAuthorizePoll(t *testing.T) {
+	repo := newFakeCreatorRepo(
+		repository.DatabasePoll{ID: "poll", ChannelARN: "owned"},
+		repository.DatabasePoll{ID: "unclaimed-poll", ChannelARN: "unclaimed"},
+	)
+	svc := New(repo, &fakeBroadcaster{})
+
+	if _, err := svc.ClaimChannel(context.Background(), "owned", "creator-1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := svc.ClaimChannel(context.Background(), "owned", "creator-1"); err != nil {
+		t.Errorf("expected the owner to be able to claim their channel again, got %s", err)
+	}
+
+	if _, err := svc.ClaimChannel(context.Background(), "owned", "creator-2"); err != ErrChannelAlreadyOwned {
+		t.Errorf("expected ErrChannelAlreadyOwned, got %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		pollID    string
+		creatorID string
+		wantErr   error
+	}{
+		{name: "owner", pollID: "poll", creatorID: "creator-1"},
+		{name: "another creator", pollID: "poll", creatorID: "creator-2", wantErr: ErrNotChannelOwner},
+		{name: "channel without an owner", pollID: "unclaimed-poll", creatorID: "creator-1", wantErr: ErrNotChannelOwner},
+		{name: "missing poll", pollID: "missing", creatorID: "creator-1", wantErr: ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := svc.AuthorizePoll(context.Background(), tt.pollID, tt.creatorID); err != tt.wantErr {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestOwnsChannel(t *testing.T) {
+	repo := newFakeCreatorRepo()
+	svc := New(repo, &fakeBroadcaster{})
+
+	ownerKey, err := svc.CreateAPIKey(context.Background(), "creator-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	otherKey, err := svc.CreateAPIKey(context.Background(), "creator-2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := svc.ClaimChannel(context.Background(), "owned", "creator-1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		channelARN string
+		expected   bool
+	}{
+		{name: "owner", apiKey: ownerKey, channelARN: "owned", expected: true},
+		{name: "another creator", apiKey: otherKey, channelARN: "owned"},
+		{name: "channel without an owner", apiKey: ownerKey, channelARN: "unclaimed"},
+		{name: "invalid key", apiKey: "not-a-key", channelARN: "owned"},
+		{name: "no key", channelARN: "owned"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owns, err := svc.OwnsChannel(context.Background(), tt.apiKey, tt.channelARN)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if owns != tt.expected {
+				t.Errorf("expected owns to be %t", tt.expected)
+			}
+		})
+	}
+}
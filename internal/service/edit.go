@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+var ErrPollHasVotes = errors.New("poll cannot be edited once it has votes")
+var ErrPollVersionConflict = errors.New("poll has been changed since it was read")
+
+type PollEdit struct {
+	// Version is the version of the poll the edit was made to, the edit is rejected if the poll
+	// has been edited since
+	Version int
+	// Question is left as it is when empty
+	Question string
+	// Options replace the poll's options in the order given, options with an ID keep that option
+	// and options without one are added. The options are left as they are when nil.
+	Options []EditedOption
+}
+
+type EditedOption struct {
+	ID    string
+	Label string
+	// Correct marks the right answer(s) to a quiz
+	Correct bool
+}
+
+// broadcastPollUpdated replaces a poll players are showing, players ignore versions older than the
+// one they have
+type broadcastPollUpdated struct {
+	broadcastPollOpened
+	Version int `json:"version"`
+}
+
+// EditPoll changes a poll's question and options. Polls can only be edited before anybody has
+// voted, so no votes are ever counted against an option that has changed. Edits to polls that
+// players are already showing are broadcast to the channel.
+func (s *service) EditPoll(ctx context.Context, pollID string, edit PollEdit) (Poll, error) {
+	current, err := s.GetPoll(ctx, pollID)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	if current.Version != edit.Version {
+		return Poll{}, ErrPollVersionConflict
+	}
+
+	if current.HasVotes {
+		return Poll{}, ErrPollHasVotes
+	}
+
+	// polls only record being voted on from their first vote, votes from before polls did are
+	// looked up instead
+	if current.Status != PollStatusDraft {
+		hasVotes, err := s.repo.HasPollVotes(ctx, pollID)
+		if err != nil {
+			return Poll{}, fmt.Errorf("checking for poll votes: %w", err)
+		}
+
+		if hasVotes {
+			return Poll{}, ErrPollHasVotes
+		}
+	}
+
+	question := edit.Question
+	if question == "" {
+		question = current.Question
+	}
+
+	options, err := editPollOptions(current, edit.Options)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	updated, err := s.repo.UpdatePoll(ctx, pollID, repository.PollEdit{
+		Question:        question,
+		Options:         options,
+		ExpectedVersion: edit.Version,
+	}, len(current.WeightRules) > 0)
+	if err != nil {
+		if err == repository.ErrPollNotFound {
+			return Poll{}, ErrRecordNotFound
+		}
+
+		if err == repository.ErrPollVersionConflict {
+			return Poll{}, ErrPollVersionConflict
+		}
+
+		if err == repository.ErrPollHasVotes {
+			return Poll{}, ErrPollHasVotes
+		}
+
+		return Poll{}, fmt.Errorf("updating poll: %w", err)
+	}
+
+	poll := mapDatabasePollToPoll(updated)
+
+	// drafts are broadcast when they are opened
+	if poll.Status == PollStatusDraft {
+		return poll, nil
+	}
+
+	metadata := broadcast.CreateTypedMetadata(broadcast.EnvelopePollUpdated, broadcastPollUpdated{
+		broadcastPollOpened: newBroadcastPollOpened(poll),
+		Version:             poll.Version,
+	})
+
+	// the edit has been saved, players that miss the broadcast pick it up from the periodic
+	// re-broadcast of its definition
+	if err := s.broadcastMetadata(ctx, poll.ChannelARN, metadata); err != nil {
+		log.Printf("error broadcasting poll %s edit: %s", poll.ID, err)
+	}
+
+	return poll, nil
+}
+
+// editPollOptions works out a poll's options after an edit, checking they still suit the type of
+// poll and its selection limits
+func editPollOptions(poll Poll, edited []EditedOption) ([]repository.DatabasePollOption, error) {
+	if edited == nil {
+		var options []repository.DatabasePollOption
+		for _, o := range poll.Options {
+			options = append(options, repository.DatabasePollOption{ID: o.ID, Label: o.Label, Correct: o.Correct})
+		}
+
+		return options, nil
+	}
+
+	// scale and free-text polls don't have a fixed set of answers to choose from
+	hasOptions := poll.Type != PollTypeScale && poll.Type != PollTypeFreeText
+	if !hasOptions || len(edited) == 0 {
+		return nil, ErrInvalidOptions
+	}
+
+	options := make([]repository.DatabasePollOption, 0, len(edited))
+	seen := make(map[string]bool, len(edited))
+	correct := 0
+	for _, o := range edited {
+		if o.ID != "" {
+			if !poll.HasOption(o.ID) || seen[o.ID] {
+				return nil, ErrInvalidOptions
+			}
+
+			seen[o.ID] = true
+		}
+
+		if o.Correct {
+			correct++
+		}
+
+		options = append(options, repository.DatabasePollOption{ID: o.ID, Label: o.Label, Correct: o.Correct})
+	}
+
+	if (poll.Type == PollTypeQuiz) != (correct > 0) {
+		return nil, ErrInvalidCorrectOptions
+	}
+
+	if poll.Type == PollTypeMultiSelect && poll.MaxSelections > len(options) {
+		return nil, ErrInvalidSelectionLimits
+	}
+
+	return options, nil
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+func (r *fakeRepo) HasPollVotes(ctx context.Context, pollID string) (bool, error) {
+	for _, v := range r.votes {
+		if v.PollID == pollID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *fakeRepo) UpdatePoll(ctx context.Context, pollID string, edit repository.PollEdit, weighted bool) (repository.DatabasePoll, error) {
+	p, ok := r.polls[pollID]
+	if !ok {
+		return repository.DatabasePoll{}, repository.ErrPollNotFound
+	}
+
+	if p.Version != edit.ExpectedVersion {
+		return repository.DatabasePoll{}, repository.ErrPollVersionConflict
+	}
+
+	if p.HasVotes {
+		return repository.DatabasePoll{}, repository.ErrPollHasVotes
+	}
+
+	for i, o := range edit.Options {
+		if o.ID == "" {
+			edit.Options[i].ID = o.Label
+		}
+	}
+
+	p.Question = edit.Question
+	p.Options = edit.Options
+	p.Version++
+
+	if len(edit.Options) > 0 {
+		p.AggregatedVoteTotals = make(repository.DatabasePollTotals, len(edit.Options))
+		for _, o := range edit.Options {
+			p.AggregatedVoteTotals[o.ID] = 0
+		}
+	}
+
+	r.polls[pollID] = p
+
+	return p, nil
+}
+
+func TestEditPollKeepsExistingOptions(t *testing.T) {
+	repo := newFakeRepo(repository.DatabasePoll{
+		ID:       "poll",
+		Question: "How many legs does a spidr have?",
+		Status:   repository.PollStatusOpen,
+		Version:  3,
+		Options: []repository.DatabasePollOption{
+			{ID: "two", Label: "2"},
+			{ID: "eight", Label: "8"},
+		},
+	})
+
+	svc := New(repo, &fakeBroadcaster{})
+
+	poll, err := svc.EditPoll(context.Background(), "poll", PollEdit{
+		Version:  3,
+		Question: "How many legs does a spider have?",
+		Options: []EditedOption{
+			{ID: "eight", Label: "Eight"},
+			{Label: "6"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if poll.Version != 4 || poll.Question != "How many legs does a spider have?" {
+		t.Errorf("expected the question to be edited as version 4, got %+v", poll)
+	}
+
+	if len(poll.Options) != 2 || poll.Options[0].ID != "eight" || poll.Options[0].Label != "Eight" || poll.Options[1].ID != "6" {
+		t.Errorf("expected the kept option to be first followed by the new one, got %+v", poll.Options)
+	}
+}
+
+func TestEditPollBroadcastsEdits(t *testing.T) {
+	repo := newFakeRepo(
+		repository.DatabasePoll{ID: "draft", ChannelARN: "channel", Status: repository.PollStatusDraft, Options: []repository.DatabasePollOption{{ID: "a", Label: "A"}}},
+		repository.DatabasePoll{ID: "open", ChannelARN: "channel", Status: repository.PollStatusOpen, Options: []repository.DatabasePollOption{{ID: "a", Label: "A"}}},
+	)
+	broadcaster := &fakeBroadcaster{}
+
+	svc := New(repo, broadcaster)
+
+	for _, id := range []string{"draft", "open"} {
+		if _, err := svc.EditPoll(context.Background(), id, PollEdit{Question: "Edited?"}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	messages := broadcaster.messages["channel"]
+	if len(messages) != 1 {
+		t.Fatalf("expected only the open poll's edit to be broadcast, got %v", messages)
+	}
+
+	var metadata struct {
+		Type string `json:"type"`
+		Data struct {
+			ID       string `json:"id"`
+			Question string `json:"question"`
+			Version  int    `json:"version"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(messages[0]), &metadata); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if metadata.Type != "poll-updated" || metadata.Data.ID != "open" || metadata.Data.Question != "Edited?" || metadata.Data.Version != 1 {
+		t.Errorf("expected the edited poll to be broadcast at version 1, got %+v", metadata)
+	}
+}
+
+func TestEditPollRejections(t *testing.T) {
+	tests := map[string]struct {
+		status string
+		voted  bool
+		edit   PollEdit
+		want   error
+	}{
+		"stale version": {
+			status: repository.PollStatusDraft,
+			edit:   PollEdit{Version: 1, Question: "Typo fixed"},
+			want:   ErrPollVersionConflict,
+		},
+		"open poll with votes": {
+			status: repository.PollStatusOpen,
+			voted:  true,
+			edit:   PollEdit{Version: 2, Question: "Typo fixed"},
+			want:   ErrPollHasVotes,
+		},
+		"unknown option ID": {
+			status: repository.PollStatusDraft,
+			edit:   PollEdit{Version: 2, Options: []EditedOption{{ID: "missing", Label: "?"}}},
+			want:   ErrInvalidOptions,
+		},
+		"no options": {
+			status: repository.PollStatusDraft,
+			edit:   PollEdit{Version: 2, Options: []EditedOption{}},
+			want:   ErrInvalidOptions,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			repo := newFakeRepo(repository.DatabasePoll{
+				ID:      "poll",
+				Status:  tc.status,
+				Version: 2,
+				Options: []repository.DatabasePollOption{{ID: "a", Label: "A"}},
+			})
+
+			if tc.voted {
+				repo.votes = append(repo.votes, repository.NewPollVote{PollID: "poll", UserID: "viewer", Answer: "a"})
+			}
+
+			svc := New(repo, &fakeBroadcaster{})
+
+			if _, err := svc.EditPoll(context.Background(), "poll", tc.edit); err != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestEditPollRacingFirstVote(t *testing.T) {
+	repo := newFakeRepo(repository.DatabasePoll{
+		ID:      "poll",
+		Status:  repository.PollStatusOpen,
+		Version: 2,
+		Options: []repository.DatabasePollOption{{ID: "a", Label: "A"}, {ID: "b", Label: "B"}},
+	})
+
+	svc := New(repo, &fakeBroadcaster{})
+
+	// the vote is stored after the edit has checked for votes but before it is saved
+	stale := repo.polls["poll"]
+	if _, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "poll", UserID: "viewer", Answer: "a"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := repo.UpdatePoll(context.Background(), "poll", repository.PollEdit{ExpectedVersion: stale.Version, Options: stale.Options}, false); err != repository.ErrPollHasVotes {
+		t.Fatalf("expected the edit to be rejected once the poll has a vote, got %v", err)
+	}
+
+	// the edit is saved after the vote was checked against the poll but before it is stored
+	repo = newFakeRepo(stale)
+	svc = New(repo, &fakeBroadcaster{})
+
+	if _, err := svc.EditPoll(context.Background(), "poll", PollEdit{Version: 2, Options: []EditedOption{{ID: "b", Label: "B"}}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, _, err := repo.CreatePollVote(context.Background(), repository.NewPollVote{PollID: "poll", UserID: "viewer", Answer: "a", FirstVote: true, PollVersion: 2}); err != repository.ErrPollVersionConflict {
+		t.Fatalf("expected a vote checked against the old options to be rejected, got %v", err)
+	}
+}
+
+func (r *fakeRepo) IncrementScalePollTotals(ctx context.Context, pollID string, valueIncrements repository.DatabasePollTotals, statsIncrement repository.DatabaseScaleStats, counted repository.CountedVoteChanges) (repository.DatabasePollTotals, repository.DatabaseScaleStats, error) {
+	p := r.polls[pollID]
+	for value, incr := range valueIncrements {
+		if _, ok := p.AggregatedVoteTotals[value]; !ok {
+			return nil, repository.DatabaseScaleStats{}, repository.ErrUnknownPollAnswer
+		}
+
+		p.AggregatedVoteTotals[value] += incr
+	}
+
+	p.ScaleStats.Count += statsIncrement.Count
+	p.ScaleStats.Sum += statsIncrement.Sum
+	r.polls[pollID] = p
+
+	return p.AggregatedVoteTotals, *p.ScaleStats, nil
+}
+
+func TestEditScalePollKeepsCounting(t *testing.T) {
+	now := time.Date(2022, 7, 10, 12, 0, 0, 0, time.UTC)
+
+	repo := newFakeRepo(repository.DatabasePoll{
+		ID:                   "scale",
+		Type:                 repository.PollTypeScale,
+		Question:             "How wuld you rate the stream?",
+		ChannelARN:           "channel",
+		Status:               repository.PollStatusDraft,
+		Version:              1,
+		ScaleMin:             1,
+		ScaleMax:             3,
+		AggregatedVoteTotals: repository.DatabasePollTotals{"1": 0, "2": 0, "3": 0},
+		ScaleStats:           &repository.DatabaseScaleStats{},
+	})
+
+	svc := New(repo, &fakeBroadcaster{}, WithClock(fixedClock(now)))
+
+	if _, err := svc.EditPoll(context.Background(), "scale", PollEdit{Version: 1, Question: "How would you rate the stream?"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := svc.OpenPoll(context.Background(), "scale"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	value := 3
+	if _, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "scale", UserID: "viewer", Value: &value}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := svc.IncrementPollTotals(context.Background(), "scale", []string{"change"}, map[string]int{"3": 1}, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if totals := repo.polls["scale"].AggregatedVoteTotals; totals["3"] != 1 {
+		t.Errorf("expected the vote to be counted after the edit, got %v", totals)
+	}
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+var ErrVoteNotQuarantined = errors.New("vote is not quarantined")
+
+// FraudSignal is a reason to suspect a vote is part of an attempt to stuff a poll
+type FraudSignal string
+
+const (
+	// FraudSignalIPBurst is set when lots of votes arrive on a poll from the same address at once
+	FraudSignalIPBurst FraudSignal = "ip-burst"
+	// FraudSignalSharedFingerprint is set when lots of votes arrive on a poll from the same client
+	FraudSignalSharedFingerprint FraudSignal = "shared-fingerprint"
+	// FraudSignalNewUser is set for users who had never voted before shortly before this vote
+	FraudSignalNewUser FraudSignal = "new-user"
+)
+
+// a new user on its own is common during a big stream, it takes a burst as well for a vote to be
+// quarantined
+var _fraudSignalWeights = map[FraudSignal]int{
+	FraudSignalIPBurst:           2,
+	FraudSignalSharedFingerprint: 2,
+	FraudSignalNewUser:           1,
+}
+
+// FraudRules decide when a vote looks suspicious. A rule with a zero limit is turned off.
+type FraudRules struct {
+	// IPBurstVotes is the most votes allowed on a poll from one address within IPBurstWindow
+	IPBurstVotes  int
+	IPBurstWindow time.Duration
+	// FingerprintVotes is the most votes allowed on a poll from one client within FingerprintWindow
+	FingerprintVotes  int
+	FingerprintWindow time.Duration
+	// NewUserAge is how long after a user is first seen that they count as new
+	NewUserAge time.Duration
+	// QuarantineScore is the total weight of signals at which a vote is quarantined
+	QuarantineScore int
+}
+
+var DefaultFraudRules = FraudRules{
+	IPBurstVotes:      20,
+	IPBurstWindow:     time.Minute,
+	FingerprintVotes:  50,
+	FingerprintWindow: time.Minute,
+	NewUserAge:        10 * time.Minute,
+	QuarantineScore:   3,
+}
+
+// WithFraudRules replaces the rules used to decide whether votes are quarantined
+func WithFraudRules(rules FraudRules) Option {
+	return func(s *service) {
+		s.fraudRules = rules
+	}
+}
+
+type QuarantinedVotePage struct {
+	Votes []PollVote
+	// Cursor fetches the next page of votes, it is empty on the last page
+	Cursor string
+}
+
+// hashVoteSource hashes where a vote came from together with its poll, so votes from the same
+// place can be matched up on a poll without storing the address or user agent
+func hashVoteSource(pollID string, source string) string {
+	if source == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(pollID + "#" + source))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// ScoreVote checks a newly made vote for signs of vote stuffing and quarantines it when it looks
+// suspicious enough. It reports whether the vote was quarantined, a vote that has been changed or
+// retracted since it was made is left alone.
+func (s *service) ScoreVote(ctx context.Context, vote PollVote) (bool, error) {
+	poll, err := s.GetPoll(ctx, vote.PollID)
+	if err != nil {
+		return false, err
+	}
+
+	votedAt := s.now()
+	if vote.VotedAt != nil {
+		votedAt = *vote.VotedAt
+	}
+
+	signals, err := s.fraudSignals(ctx, poll, vote, votedAt)
+	if err != nil {
+		return false, err
+	}
+
+	score := 0
+	var reasons []string
+	for _, signal := range signals {
+		score += _fraudSignalWeights[signal]
+		reasons = append(reasons, string(signal))
+	}
+
+	if s.fraudRules.QuarantineScore == 0 || score < s.fraudRules.QuarantineScore {
+		return false, nil
+	}
+
+	if err := s.repo.QuarantinePollVote(ctx, poll.ID, vote.UserID, vote.ID, string(poll.VotePolicy), reasons); err != nil {
+		if err == repository.ErrVoteNotFound {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("quarantining vote: %w", err)
+	}
+
+	return true, nil
+}
+
+func (s *service) fraudSignals(ctx context.Context, poll Poll, vote PollVote, votedAt time.Time) ([]FraudSignal, error) {
+	rules := s.fraudRules
+
+	var signals []FraudSignal
+
+	ipCounter := fraudCounter{name: "IP#" + vote.IPHash, limit: rules.IPBurstVotes, window: rules.IPBurstWindow}
+	fingerprintCounter := fraudCounter{name: "FINGERPRINT#" + vote.Fingerprint, limit: rules.FingerprintVotes, window: rules.FingerprintWindow}
+
+	var counters []fraudCounter
+	if vote.IPHash != "" && ipCounter.enabled() {
+		counters = append(counters, ipCounter)
+	}
+
+	if vote.Fingerprint != "" && fingerprintCounter.enabled() {
+		counters = append(counters, fingerprintCounter)
+	}
+
+	exceeded, err := s.exceededFraudCounters(ctx, poll.ID, vote.ID, counters, votedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if exceeded[ipCounter.name] {
+		signals = append(signals, FraudSignalIPBurst)
+	}
+
+	if exceeded[fingerprintCounter.name] {
+		signals = append(signals, FraudSignalSharedFingerprint)
+	}
+
+	if rules.NewUserAge > 0 {
+		firstSeen, err := s.repo.RecordUserSeen(ctx, vote.UserID, votedAt)
+		if err != nil {
+			return nil, fmt.Errorf("recording user seen: %w", err)
+		}
+
+		if votedAt.Sub(firstSeen) < rules.NewUserAge {
+			signals = append(signals, FraudSignalNewUser)
+		}
+	}
+
+	return signals, nil
+}
+
+// fraudCounter counts the votes on a poll that share something, e.g. their address, within each
+// window. A counter with a zero limit or window is turned off.
+type fraudCounter struct {
+	name   string
+	limit  int
+	window time.Duration
+}
+
+func (c fraudCounter) enabled() bool {
+	return c.limit > 0 && c.window > 0
+}
+
+// exceededFraudCounters counts the vote in the current window of each counter, reporting which
+// counters' windows now hold more votes than their limit. A vote is only ever counted once, however
+// many times it is scored.
+func (s *service) exceededFraudCounters(ctx context.Context, pollID string, voteID string, counters []fraudCounter, votedAt time.Time) (map[string]bool, error) {
+	if len(counters) == 0 {
+		return nil, nil
+	}
+
+	windows := make([]repository.FraudCounterWindow, 0, len(counters))
+	for _, c := range counters {
+		windowStart := votedAt.Truncate(c.window)
+
+		windows = append(windows, repository.FraudCounterWindow{
+			Counter:     c.name,
+			WindowStart: windowStart,
+			ExpiresAt:   windowStart.Add(2 * c.window),
+		})
+	}
+
+	counts, err := s.repo.IncrementFraudCounters(ctx, pollID, voteID, windows)
+	if err != nil {
+		return nil, fmt.Errorf("incrementing fraud counters: %w", err)
+	}
+
+	exceeded := make(map[string]bool, len(counters))
+	for _, c := range counters {
+		exceeded[c.name] = counts[c.name] > c.limit
+	}
+
+	return exceeded, nil
+}
+
+// ListQuarantinedVotes pages through the votes on a poll that are waiting to be reviewed
+func (s *service) ListQuarantinedVotes(ctx context.Context, pollID string, limit int, cursor string) (QuarantinedVotePage, error) {
+	if _, err := s.GetPoll(ctx, pollID); err != nil {
+		return QuarantinedVotePage{}, err
+	}
+
+	votes, next, err := s.repo.ListQuarantinedVotes(ctx, pollID, limit, cursor)
+	if err != nil {
+		if err == repository.ErrInvalidCursor {
+			return QuarantinedVotePage{}, ErrInvalidCursor
+		}
+
+		return QuarantinedVotePage{}, fmt.Errorf("listing quarantined votes: %w", err)
+	}
+
+	page := QuarantinedVotePage{
+		Votes:  []PollVote{},
+		Cursor: next,
+	}
+
+	for _, v := range votes {
+		page.Votes = append(page.Votes, mapDatabasePollVoteToVote(v))
+	}
+
+	return page, nil
+}
+
+// ReleaseVote counts a quarantined vote after all, the vote stream picks the change up and adds it
+// to the poll's totals
+func (s *service) ReleaseVote(ctx context.Context, pollID string, voteID string) (PollVote, error) {
+	vote, err := s.repo.ReleasePollVote(ctx, pollID, voteID)
+	if err != nil {
+		if err == repository.ErrVoteNotQuarantined {
+			return PollVote{}, ErrVoteNotQuarantined
+		}
+
+		return PollVote{}, fmt.Errorf("releasing vote: %w", err)
+	}
+
+	return mapDatabasePollVoteToVote(vote), nil
+}
+
+// DiscardVote deletes a quarantined vote, it was never counted so the totals stay as they are
+func (s *service) DiscardVote(ctx context.Context, pollID string, voteID string) error {
+	if err := s.repo.DiscardPollVote(ctx, pollID, voteID); err != nil {
+		if err == repository.ErrVoteNotQuarantined {
+			return ErrVoteNotQuarantined
+		}
+
+		return fmt.Errorf("discarding vote: %w", err)
+	}
+
+	return nil
+}
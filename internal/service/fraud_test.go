@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+// fakeFraudRepo keeps fraud counters, the votes counted towards them, when users were first seen
+// and quarantined votes alongside the polls of a fakeRepo
+type fakeFraudRepo struct {
+	*fakeRepo
+	counters    map[string]int
+	scored      map[string]map[string]int
+	firstSeen   map[string]time.Time
+	quarantined map[string][]string
+}
+
+func newFakeFraudRepo(polls ...repository.DatabasePoll) *fakeFraudRepo {
+	return &fakeFraudRepo{
+		fakeRepo:    newFakeRepo(polls...),
+		counters:    make(map[string]int),
+		scored:      make(map[string]map[string]int),
+		firstSeen:   make(map[string]time.Time),
+		quarantined: make(map[string][]string),
+	}
+}
+
+func (r *fakeFraudRepo) IncrementFraudCounters(ctx context.Context, pollID string, voteID string, windows []repository.FraudCounterWindow) (map[string]int, error) {
+	if counts, ok := r.scored[pollID+"#"+voteID]; ok {
+		return counts, nil
+	}
+
+	counts := make(map[string]int, len(windows))
+	for _, w := range windows {
+		key := pollID + "#" + w.Counter + "#" + w.WindowStart.String()
+		r.counters[key]++
+		counts[w.Counter] = r.counters[key]
+	}
+	r.scored[pollID+"#"+voteID] = counts
+
+	return counts, nil
+}
+
+func (r *fakeFraudRepo) RecordUserSeen(ctx context.Context, userID string, seenAt time.Time) (time.Time, error) {
+	if firstSeen, ok := r.firstSeen[userID]; ok {
+		return firstSeen, nil
+	}
+
+	r.firstSeen[userID] = seenAt
+
+	return seenAt, nil
+}
+
+func (r *fakeFraudRepo) QuarantinePollVote(ctx context.Context, pollID string, userID string, voteID string, policy string, signals []string) error {
+	r.quarantined[voteID] = signals
+
+	return nil
+}
+
+func TestScoreVote(t *testing.T) {
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	repo := newFakeFraudRepo(repository.DatabasePoll{
+		ID:         "poll",
+		Status:     repository.PollStatusOpen,
+		VotePolicy: repository.VotePolicyUnlimited,
+	})
+	repo.firstSeen["regular"] = now.Add(-24 * time.Hour)
+
+	svc := New(repo, &fakeBroadcaster{},
+		WithClock(fixedClock(now)),
+		WithFraudRules(FraudRules{
+			IPBurstVotes:      2,
+			IPBurstWindow:     time.Minute,
+			FingerprintVotes:  10,
+			FingerprintWindow: time.Minute,
+			NewUserAge:        10 * time.Minute,
+			QuarantineScore:   3,
+		}),
+	)
+
+	score := func(voteID string, userID string) bool {
+		quarantined, err := svc.ScoreVote(context.Background(), PollVote{
+			ID:          voteID,
+			PollID:      "poll",
+			UserID:      userID,
+			VotedAt:     &now,
+			IPHash:      "ip",
+			Fingerprint: voteID,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		return quarantined
+	}
+
+	if score("1", "regular") || score("2", "newcomer") {
+		t.Fatalf("expected votes within the address's burst limit not to be quarantined")
+	}
+
+	if score("3", "regular") {
+		t.Errorf("expected a burst from a regular user not to be quarantined on its own")
+	}
+
+	// the stream redelivers a batch that failed, scoring its votes again
+	if score("1", "regular") || score("2", "newcomer") {
+		t.Fatalf("expected votes scored again not to be counted towards the burst twice")
+	}
+
+	if !score("4", "another-newcomer") {
+		t.Fatalf("expected a burst from a new user to be quarantined")
+	}
+
+	expected := []string{string(FraudSignalIPBurst), string(FraudSignalNewUser)}
+	if signals := repo.quarantined["4"]; !reflect.DeepEqual(signals, expected) {
+		t.Errorf("expected signals %v, got %v", expected, signals)
+	}
+}
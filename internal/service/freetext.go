@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+var ErrInvalidFreeText = errors.New("free-text answer is empty or too long")
+
+const (
+	_maxTermLength = 30
+	// only the most used terms are kept on the poll so the word cloud stays small enough to broadcast
+	_topTermsSize = 20
+)
+
+// TermCount is how many viewers gave the same answer to a free-text poll
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// normalizeTerm folds free-text answers that only differ by case, spacing or surrounding
+// punctuation into the same term, e.g. " Awesome!! " and "awesome" are both "awesome"
+func normalizeTerm(text string) string {
+	term := strings.ToLower(strings.Join(strings.Fields(text), " "))
+
+	return strings.TrimFunc(term, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// freeTextTerm normalizes a free-text answer, returning ErrInvalidFreeText if nothing is left of it
+// or it is too long to be a sensible word cloud entry
+func freeTextTerm(text string) (string, error) {
+	term := normalizeTerm(text)
+	if term == "" || utf8.RuneCountInString(term) > _maxTermLength {
+		return "", ErrInvalidFreeText
+	}
+
+	return term, nil
+}
+
+type broadcastWordCloud struct {
+	ID    string      `json:"id"`
+	Terms []TermCount `json:"terms"`
+}
+
+// incrementTermCounts moves the counts of each term on a free-text poll, refreshes the poll's top
+// terms and broadcasts them as a word cloud
+func (s *service) incrementTermCounts(ctx context.Context, dbPoll repository.DatabasePoll, termIncrements map[string]int, counted repository.CountedVoteChanges) error {
+	if len(termIncrements) == 0 {
+		if err := s.repo.RecordCountedVoteChanges(ctx, dbPoll.ID, counted); err != nil {
+			return fmt.Errorf("recording counted vote changes: %w", err)
+		}
+
+		return nil
+	}
+
+	newCounts, err := s.repo.IncrementPollTerms(ctx, dbPoll.ID, termIncrements, counted)
+	if err != nil {
+		return fmt.Errorf("incrementing poll terms: %w", err)
+	}
+
+	poll := mapDatabasePollToPoll(dbPoll)
+
+	// a term in the current top list losing votes could let one outside the list overtake it, the
+	// full set of counts is needed to know which one
+	counts := make(map[string]int, len(poll.TopTerms)+len(newCounts))
+	needsRecount := false
+	for _, t := range poll.TopTerms {
+		counts[t.Term] = t.Count
+
+		if termIncrements[t.Term] < 0 {
+			needsRecount = true
+		}
+	}
+
+	if needsRecount {
+		terms, err := s.repo.ListPollTerms(ctx, dbPoll.ID)
+		if err != nil {
+			return fmt.Errorf("listing poll terms: %w", err)
+		}
+
+		counts = make(map[string]int, len(terms))
+		for _, t := range terms {
+			counts[t.Term] = t.Count
+		}
+	} else {
+		for term, count := range newCounts {
+			counts[term] = count
+		}
+	}
+
+	topTerms := rankTerms(counts, _topTermsSize)
+
+	var dbTopTerms []repository.DatabaseTermCount
+	for _, t := range topTerms {
+		dbTopTerms = append(dbTopTerms, repository.DatabaseTermCount{Term: t.Term, Count: t.Count})
+	}
+
+	if err := s.repo.UpdatePollTopTerms(ctx, dbPoll.ID, dbTopTerms); err != nil {
+		return fmt.Errorf("updating poll top terms: %w", err)
+	}
+
+	metadata := broadcast.CreateTypedMetadata(broadcast.EnvelopeWordCloud, broadcastWordCloud{
+		ID:    poll.ID,
+		Terms: topTerms,
+	})
+
+	return s.broadcastMetadata(ctx, poll.ChannelARN, metadata)
+}
+
+// rankTerms orders terms from most to least used, alphabetically when tied, keeping at most limit
+// of them. Terms that nobody is using any more are dropped.
+func rankTerms(counts map[string]int, limit int) []TermCount {
+	ranked := []TermCount{}
+	for term, count := range counts {
+		if count > 0 {
+			ranked = append(ranked, TermCount{Term: term, Count: count})
+		}
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+
+		return ranked[i].Term < ranked[j].Term
+	})
+
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	return ranked
+}
+
+func mapDatabaseTermCounts(dbTerms []repository.DatabaseTermCount) []TermCount {
+	var terms []TermCount
+	for _, t := range dbTerms {
+		terms = append(terms, TermCount{Term: t.Term, Count: t.Count})
+	}
+
+	return terms
+}
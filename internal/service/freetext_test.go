@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+func TestFreeTextTerm(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+		err      error
+	}{
+		{text: "Awesome", expected: "awesome"},
+		{text: "  AWESOME!!  ", expected: "awesome"},
+		{text: "so   much\tfun", expected: "so much fun"},
+		{text: "¡Olé!", expected: "olé"},
+		{text: "?!", err: ErrInvalidFreeText},
+		{text: strings.Repeat("a", _maxTermLength+1), err: ErrInvalidFreeText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			term, err := freeTextTerm(tt.text)
+			if err != tt.err || term != tt.expected {
+				t.Errorf("expected %q %v, got %q %v", tt.expected, tt.err, term, err)
+			}
+		})
+	}
+}
+
+func TestRankTerms(t *testing.T) {
+	ranked := rankTerms(map[string]int{"fun": 2, "boring": 0, "epic": 5, "cool": 2}, 2)
+
+	expected := []TermCount{{Term: "epic", Count: 5}, {Term: "cool", Count: 2}}
+	if !reflect.DeepEqual(ranked, expected) {
+		t.Fatalf("expected %v, got %v", expected, ranked)
+	}
+}
+
+func TestCreatePollVoteNormalizesFreeText(t *testing.T) {
+	repo := newFakeRepo(repository.DatabasePoll{
+		ID:     "poll",
+		Type:   repository.PollTypeFreeText,
+		Status: repository.PollStatusOpen,
+	})
+
+	svc := New(repo, &fakeBroadcaster{})
+
+	if _, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "poll", UserID: "user", Answer: " Hype! "}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if repo.votes[0].Answer != "hype" {
+		t.Errorf("expected the normalized term to be stored, got %q", repo.votes[0].Answer)
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+// VoteHistoryEntry is a vote a user has cast along with the poll they voted on
+type VoteHistoryEntry struct {
+	Vote PollVote
+	Poll Poll
+}
+
+type VoteHistory struct {
+	UserID  string
+	Entries []VoteHistoryEntry
+	// Cursor fetches the next page of votes, it is empty on the last page
+	Cursor string
+}
+
+// ListUserVotes pages through the votes a user has cast across every poll, newest first. Votes
+// whose poll has since been deleted are left out.
+func (s *service) ListUserVotes(ctx context.Context, userID string, limit int, cursor string) (VoteHistory, error) {
+	votes, next, err := s.repo.ListVotesByUser(ctx, userID, limit, cursor)
+	if err != nil {
+		if err == repository.ErrInvalidCursor {
+			return VoteHistory{}, ErrInvalidCursor
+		}
+
+		return VoteHistory{}, fmt.Errorf("listing user votes: %w", err)
+	}
+
+	var pollIDs []string
+	seen := make(map[string]bool, len(votes))
+	for _, v := range votes {
+		if !seen[v.PollID] {
+			seen[v.PollID] = true
+			pollIDs = append(pollIDs, v.PollID)
+		}
+	}
+
+	polls, err := s.repo.GetPolls(ctx, pollIDs)
+	if err != nil {
+		return VoteHistory{}, fmt.Errorf("getting voted polls: %w", err)
+	}
+
+	history := VoteHistory{
+		UserID:  userID,
+		Entries: []VoteHistoryEntry{},
+		Cursor:  next,
+	}
+
+	for _, v := range votes {
+		poll, ok := polls[v.PollID]
+		if !ok {
+			continue
+		}
+
+		history.Entries = append(history.Entries, VoteHistoryEntry{
+			Vote: mapDatabasePollVoteToVote(v),
+			Poll: mapDatabasePollToPoll(poll),
+		})
+	}
+
+	return history, nil
+}
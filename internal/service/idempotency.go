@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+var ErrIdempotencyKeyInUse = errors.New("a request with the idempotency key is still in progress")
+var ErrIdempotencyKeyReused = errors.New("idempotency key was used with a different request")
+
+const (
+	// idempotency keys are kept for a day, long enough for any client to have given up retrying
+	_idempotencyKeyTTL = 24 * time.Hour
+	// a request that still hasn't finished after this long has failed without releasing its key
+	_idempotencyKeyLockTimeout = time.Minute
+)
+
+// IdempotentResponse is the response to a request made with an idempotency key
+type IdempotentResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       string
+}
+
+// BeginIdempotentRequest claims an idempotency key for a request. When the key has already been
+// used for the same request its original response is returned to be replayed, otherwise nil is
+// returned and the request should go ahead and finish with CompleteIdempotentRequest or
+// AbandonIdempotentRequest. Keys are scoped so different callers can't see each other's responses.
+func (s *service) BeginIdempotentRequest(ctx context.Context, scope string, key string, request string) (*IdempotentResponse, error) {
+	requestHash := hashRequest(request)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		now := s.now()
+
+		record, claimed, err := s.repo.ClaimIdempotencyKey(ctx, scope, key, requestHash, now, now.Add(_idempotencyKeyTTL))
+		if err != nil {
+			if err == repository.ErrIdempotencyKeyNotFound {
+				// the key was released between the claim and reading it back
+				continue
+			}
+
+			return nil, fmt.Errorf("claiming idempotency key: %w", err)
+		}
+
+		if claimed {
+			return nil, nil
+		}
+
+		// TTL deletion can lag behind a key's expiry by days, an expired key is free to be reused
+		if record.ExpiresAt > 0 && now.Unix() >= record.ExpiresAt {
+			if err := s.repo.ReleaseIdempotencyKey(ctx, scope, key); err != nil {
+				return nil, fmt.Errorf("releasing expired idempotency key: %w", err)
+			}
+
+			continue
+		}
+
+		if record.RequestHash != requestHash {
+			return nil, ErrIdempotencyKeyReused
+		}
+
+		if record.Status == repository.IdempotencyStatusComplete {
+			return &IdempotentResponse{StatusCode: record.StatusCode, Headers: record.Headers, Body: record.Body}, nil
+		}
+
+		createdAt, err := repository.ParseTimestamp(record.CreatedAt)
+		if err != nil || now.Sub(createdAt) < _idempotencyKeyLockTimeout {
+			return nil, ErrIdempotencyKeyInUse
+		}
+
+		if err := s.repo.ReleaseIdempotencyKey(ctx, scope, key); err != nil {
+			return nil, fmt.Errorf("releasing stale idempotency key: %w", err)
+		}
+	}
+
+	return nil, ErrIdempotencyKeyInUse
+}
+
+// CompleteIdempotentRequest stores the response to a request so retries replay it
+func (s *service) CompleteIdempotentRequest(ctx context.Context, scope string, key string, response IdempotentResponse) error {
+	if err := s.repo.CompleteIdempotencyKey(ctx, scope, key, response.StatusCode, response.Headers, response.Body); err != nil {
+		return fmt.Errorf("completing idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// AbandonIdempotentRequest releases the key of a request that failed so it can be retried
+func (s *service) AbandonIdempotentRequest(ctx context.Context, scope string, key string) error {
+	if err := s.repo.ReleaseIdempotencyKey(ctx, scope, key); err != nil {
+		return fmt.Errorf("releasing idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+func hashRequest(request string) string {
+	sum := sha256.Sum256([]byte(request))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+// fakeIdempotencyRepo keeps idempotency keys alongside the polls of a fakeRepo
+type fakeIdempotencyRepo struct {
+	*fakeRepo
+	keys map[string]repository.DatabaseIdempotencyKey
+}
+
+func newFakeIdempotencyRepo() *fakeIdempotencyRepo {
+	return &fakeIdempotencyRepo{
+		fakeRepo: newFakeRepo(),
+		keys:     make(map[string]repository.DatabaseIdempotencyKey),
+	}
+}
+
+func (r *fakeIdempotencyRepo) ClaimIdempotencyKey(ctx context.Context, scope string, key string, requestHash string, createdAt time.Time, expiresAt time.Time) (repository.DatabaseIdempotencyKey, bool, error) {
+	if existing, ok := r.keys[scope+key]; ok {
+		return existing, false, nil
+	}
+
+	r.keys[scope+key] = repository.DatabaseIdempotencyKey{
+		Status:      repository.IdempotencyStatusPending,
+		RequestHash: requestHash,
+		CreatedAt:   createdAt.Format(time.RFC3339),
+		ExpiresAt:   expiresAt.Unix(),
+	}
+
+	return r.keys[scope+key], true, nil
+}
+
+func (r *fakeIdempotencyRepo) CompleteIdempotencyKey(ctx context.Context, scope string, key string, statusCode int, headers map[string]string, body string) error {
+	record, ok := r.keys[scope+key]
+	if !ok {
+		return repository.ErrIdempotencyKeyNotFound
+	}
+
+	record.Status = repository.IdempotencyStatusComplete
+	record.StatusCode = statusCode
+	record.Headers = headers
+	record.Body = body
+	r.keys[scope+key] = record
+
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) ReleaseIdempotencyKey(ctx context.Context, scope string, key string) error {
+	delete(r.keys, scope+key)
+
+	return nil
+}
+
+func TestBeginIdempotentRequest(t *testing.T) {
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	repo := newFakeIdempotencyRepo()
+	svc := New(repo, &fakeBroadcaster{}, WithClock(fixedClock(now)))
+
+	ctx := context.Background()
+
+	if replay, err := svc.BeginIdempotentRequest(ctx, "creator-1", "key", `{"question":"?"}`); err != nil || replay != nil {
+		t.Fatalf("expected the first request to go ahead, got %v, %v", replay, err)
+	}
+
+	if _, err := svc.BeginIdempotentRequest(ctx, "creator-1", "key", `{"question":"?"}`); err != ErrIdempotencyKeyInUse {
+		t.Fatalf("expected a retry during the first request to be told the key is in use, got %v", err)
+	}
+
+	if err := svc.CompleteIdempotentRequest(ctx, "creator-1", "key", IdempotentResponse{StatusCode: 202, Body: `{"id":"poll"}`}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	replay, err := svc.BeginIdempotentRequest(ctx, "creator-1", "key", `{"question":"?"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if replay == nil || replay.StatusCode != 202 || replay.Body != `{"id":"poll"}` {
+		t.Fatalf("expected the original response to be replayed, got %v", replay)
+	}
+
+	if _, err := svc.BeginIdempotentRequest(ctx, "creator-1", "key", `{"question":"!"}`); err != ErrIdempotencyKeyReused {
+		t.Fatalf("expected reusing the key for another request to fail, got %v", err)
+	}
+
+	if replay, err := svc.BeginIdempotentRequest(ctx, "creator-2", "key", `{"question":"?"}`); err != nil || replay != nil {
+		t.Fatalf("expected keys to be scoped to their caller, got %v, %v", replay, err)
+	}
+}
+
+func TestBeginIdempotentRequestReclaimsStaleKeys(t *testing.T) {
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	repo := newFakeIdempotencyRepo()
+	repo.keys["creator-1key"] = repository.DatabaseIdempotencyKey{
+		Status:      repository.IdempotencyStatusPending,
+		RequestHash: hashRequest("{}"),
+		CreatedAt:   now.Add(-5 * time.Minute).Format(time.RFC3339),
+	}
+
+	svc := New(repo, &fakeBroadcaster{}, WithClock(fixedClock(now)))
+
+	if replay, err := svc.BeginIdempotentRequest(context.Background(), "creator-1", "key", "{}"); err != nil || replay != nil {
+		t.Fatalf("expected a request abandoned without releasing its key to be retried, got %v, %v", replay, err)
+	}
+}
+
+func TestBeginIdempotentRequestReusesExpiredKeys(t *testing.T) {
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	repo := newFakeIdempotencyRepo()
+	// the key has expired but TTL deletion hasn't removed it yet
+	repo.keys["creator-1key"] = repository.DatabaseIdempotencyKey{
+		Status:      repository.IdempotencyStatusComplete,
+		RequestHash: hashRequest(`{"question":"old"}`),
+		StatusCode:  202,
+		Body:        `{"id":"old-poll"}`,
+		CreatedAt:   now.Add(-25 * time.Hour).Format(time.RFC3339),
+		ExpiresAt:   now.Add(-time.Hour).Unix(),
+	}
+
+	svc := New(repo, &fakeBroadcaster{}, WithClock(fixedClock(now)))
+
+	if replay, err := svc.BeginIdempotentRequest(context.Background(), "creator-1", "key", "{}"); err != nil || replay != nil {
+		t.Fatalf("expected an expired key to be claimed for a new request, got %v, %v", replay, err)
+	}
+
+	if record := repo.keys["creator-1key"]; record.Status != repository.IdempotencyStatusPending || record.RequestHash != hashRequest("{}") {
+		t.Errorf("expected the expired key to be overwritten, got %+v", record)
+	}
+}
@@ -2,19 +2,109 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/ratelimit"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
 )
 
 var ErrRecordNotFound = errors.New("could not find record")
+var ErrPollNotOpen = errors.New("poll is not open for voting")
+var ErrInvalidStatusTransition = errors.New("poll cannot move to the requested status")
+var ErrDeadlineInPast = errors.New("poll deadline must be in the future")
+var ErrInvalidAnswer = errors.New("answer is not one of the poll's options")
+var ErrAlreadyVoted = errors.New("user has already voted on the poll")
+var ErrVoteNotChangeable = errors.New("votes on the poll cannot be changed")
+var ErrInvalidSelectionLimits = errors.New("selection limits must be within the number of options")
+var ErrInvalidSelectionCount = errors.New("number of selected options is outside the poll's limits")
+var ErrUnsupportedPollType = errors.New("operation is not supported for the poll's type")
+var ErrInvalidCorrectOptions = errors.New("correct options must be valid options of a quiz")
+var ErrSessionChannelMismatch = errors.New("poll must be on the same channel as its session")
+var ErrVoteNotFound = errors.New("user has not voted on the poll")
+var ErrInvalidOptions = errors.New("options are required for choice polls and not allowed for scale or free-text polls")
+
+// PollType decides what a vote looks like and how votes are counted
+type PollType string
+
+const (
+	PollTypeSingleChoice PollType = repository.PollTypeSingleChoice
+	PollTypeMultiSelect  PollType = repository.PollTypeMultiSelect
+	PollTypeRankedChoice PollType = repository.PollTypeRankedChoice
+	PollTypeQuiz         PollType = repository.PollTypeQuiz
+	PollTypeScale        PollType = repository.PollTypeScale
+	PollTypeFreeText     PollType = repository.PollTypeFreeText
+	PollTypePrediction   PollType = repository.PollTypePrediction
+)
+
+type PollStatus string
+
+const (
+	PollStatusDraft  PollStatus = repository.PollStatusDraft
+	PollStatusOpen   PollStatus = repository.PollStatusOpen
+	PollStatusClosed PollStatus = repository.PollStatusClosed
+)
+
+// VotePolicy controls how many times a user can vote on a poll
+type VotePolicy string
+
+const (
+	VotePolicySingle     VotePolicy = repository.VotePolicySingle
+	VotePolicyChangeable VotePolicy = repository.VotePolicyChangeable
+	VotePolicyUnlimited  VotePolicy = repository.VotePolicyUnlimited
+)
 
 type Repo interface {
 	GetPoll(ctx context.Context, pollID string) (repository.DatabasePoll, error)
+	GetPolls(ctx context.Context, pollIDs []string) (map[string]repository.DatabasePoll, error)
 	CreatePoll(ctx context.Context, poll repository.NewPoll) (repository.DatabasePoll, error)
-	CreatePollVote(ctx context.Context, vote repository.NewPollVote) (repository.DatabasePollVote, error)
-	IncrementPollTotals(ctx context.Context, pollID string, answerIncrements repository.DatabasePollTotals) (repository.DatabasePollTotals, error)
+	CreatePollVote(ctx context.Context, vote repository.NewPollVote) (repository.DatabasePollVote, bool, error)
+	IncrementPollTotals(ctx context.Context, pollID string, answerIncrements repository.DatabasePollTotals, counted repository.CountedVoteChanges) (repository.DatabasePollTotals, error)
+	IncrementWeightedPollTotals(ctx context.Context, pollID string, answerIncrements repository.DatabasePollTotals, weightedIncrements repository.DatabasePollTotals, counted repository.CountedVoteChanges) (repository.DatabasePollTotals, repository.DatabasePollTotals, error)
+	IncrementScalePollTotals(ctx context.Context, pollID string, valueIncrements repository.DatabasePollTotals, statsIncrement repository.DatabaseScaleStats, counted repository.CountedVoteChanges) (repository.DatabasePollTotals, repository.DatabaseScaleStats, error)
+	IncrementPollTerms(ctx context.Context, pollID string, termIncrements map[string]int, counted repository.CountedVoteChanges) (map[string]int, error)
+	ListCountedVoteChanges(ctx context.Context, pollID string, changeIDs []string) ([]string, error)
+	RecordCountedVoteChanges(ctx context.Context, pollID string, counted repository.CountedVoteChanges) error
+	ListPollTerms(ctx context.Context, pollID string) ([]repository.DatabasePollTerm, error)
+	UpdatePollTopTerms(ctx context.Context, pollID string, topTerms []repository.DatabaseTermCount) error
+	UpdatePoll(ctx context.Context, pollID string, edit repository.PollEdit, weighted bool) (repository.DatabasePoll, error)
+	HasPollVotes(ctx context.Context, pollID string) (bool, error)
+	DeletePoll(ctx context.Context, pollID string, deletingAt time.Time) error
+	ListActivePolls(ctx context.Context, channelARN string) ([]repository.DatabasePoll, error)
+	ClaimDefinitionBroadcast(ctx context.Context, pollID string, now time.Time, since time.Time) (repository.DatabasePoll, bool, error)
+	ClaimRunoffBroadcast(ctx context.Context, pollID string, now time.Time, since time.Time) (repository.DatabasePoll, bool, error)
+	ListPollsByChannel(ctx context.Context, channelARN string, statuses []string, limit int, cursor string) ([]repository.DatabasePoll, string, error)
+	UpdatePollStatus(ctx context.Context, pollID string, allowedFrom []string, update repository.PollStatusUpdate) (repository.DatabasePoll, error)
+	ListExpiredPollIDs(ctx context.Context, now time.Time) ([]string, error)
+	GetPollVote(ctx context.Context, pollID string, userID string) (repository.DatabasePollVote, error)
+	ListVotesByUser(ctx context.Context, userID string, limit int, cursor string) ([]repository.DatabasePollVote, string, error)
+	DeletePollVote(ctx context.Context, pollID string, userID string) (repository.DatabasePollVote, error)
+	ListPollVotes(ctx context.Context, pollID string) ([]repository.DatabasePollVote, error)
+	RevealPollAnswer(ctx context.Context, pollID string) (repository.DatabasePoll, error)
+	GetSession(ctx context.Context, sessionID string) (repository.DatabaseSession, error)
+	CreateSession(ctx context.Context, session repository.NewSession) (repository.DatabaseSession, error)
+	AddSessionScore(ctx context.Context, sessionID string, pollID string, userID string, points int, expiresAt *time.Time) error
+	CompletePollAwards(ctx context.Context, pollID string, awardedAt time.Time) error
+	ListSessionLeaderboard(ctx context.Context, sessionID string, limit int, cursor string) ([]repository.DatabaseSessionScore, string, error)
+	ResolvePoll(ctx context.Context, pollID string, winningOptionID string, resolvedAt time.Time) (repository.DatabasePoll, error)
+	AddUserPoints(ctx context.Context, pollID string, userID string, points int, won bool, expiresAt *time.Time) error
+	CreateAPIKey(ctx context.Context, keyHash string, creatorID string, createdAt time.Time) error
+	GetAPIKey(ctx context.Context, keyHash string) (repository.DatabaseAPIKey, error)
+	GetChannelOwner(ctx context.Context, channelARN string) (repository.DatabaseChannelOwner, error)
+	ClaimChannel(ctx context.Context, channelARN string, ownerID string, claimedAt time.Time) (repository.DatabaseChannelOwner, error)
+	IncrementFraudCounters(ctx context.Context, pollID string, voteID string, windows []repository.FraudCounterWindow) (map[string]int, error)
+	RecordUserSeen(ctx context.Context, userID string, seenAt time.Time) (time.Time, error)
+	QuarantinePollVote(ctx context.Context, pollID string, userID string, voteID string, policy string, signals []string) error
+	ListQuarantinedVotes(ctx context.Context, pollID string, limit int, cursor string) ([]repository.DatabasePollVote, string, error)
+	ReleasePollVote(ctx context.Context, pollID string, voteID string) (repository.DatabasePollVote, error)
+	DiscardPollVote(ctx context.Context, pollID string, voteID string) error
+	ClaimIdempotencyKey(ctx context.Context, scope string, key string, requestHash string, createdAt time.Time, expiresAt time.Time) (repository.DatabaseIdempotencyKey, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, scope string, key string, statusCode int, headers map[string]string, body string) error
+	ReleaseIdempotencyKey(ctx context.Context, scope string, key string) error
 }
 
 type Broadcaster interface {
@@ -22,28 +112,126 @@ type Broadcaster interface {
 }
 
 type Poll struct {
-	ID                   string
-	Question             string
-	Options              []PollOption
-	ChannelARN           string
+	ID             string
+	Type           PollType
+	Question       string
+	Options        []PollOption
+	MinSelections  int
+	MaxSelections  int
+	ScaleMin       int
+	ScaleMax       int
+	ChannelARN     string
+	SessionID      string
+	Status         PollStatus
+	VotePolicy     VotePolicy
+	Duration       time.Duration
+	OpenedAt       *time.Time
+	ClosesAt       *time.Time
+	AnswerRevealed bool
+	// Version goes up by one each time the poll is edited
+	Version int
+	// HasVotes is set once the poll has been voted on, after which it can't be edited
+	HasVotes bool
+	// PredictionPoints are awarded to everyone who predicts the winning option of a prediction
+	PredictionPoints int
+	WinningOptionID  string
+	ResolvedAt       *time.Time
+	// AwardedAt is when every voter had been awarded their points for a revealed quiz or resolved
+	// prediction
+	AwardedAt *time.Time
+	// DeletingAt is set whilst the poll is being deleted, it stops accepting votes from then on
+	DeletingAt           *time.Time
 	AggregatedVoteTotals map[string]int
+	// WeightRules maps a viewer tier to the weight of their votes, WeightedVoteTotals sums those
+	// weights whilst AggregatedVoteTotals still counts each vote once
+	WeightRules        map[string]int
+	WeightedVoteTotals map[string]int
+	// ScaleStats is only kept for scale polls, AggregatedVoteTotals holds their histogram
+	ScaleStats ScaleStats
+	// TopTerms are the most used answers to a free-text poll, most used first
+	TopTerms []TermCount
+	// ExpiresAt is when the poll and its votes are removed, polls without it are kept forever
+	ExpiresAt *time.Time
+	CreatedAt *time.Time
+	// CreatorID is the creator who made the poll, it is empty for polls made before creators had
+	// to authenticate
+	CreatorID string
+	// VoteRateLimit replaces the per user vote rate limit for this poll
+	VoteRateLimit *ratelimit.Limit
 }
 
 type PollOption struct {
 	ID    string
 	Label string
+	// Correct marks the right answer(s) to a quiz, it must not be shown to viewers until the
+	// answer has been revealed
+	Correct bool
+}
+
+// IsCorrect checks whether the option ID is one of a quiz's correct answers
+func (p Poll) IsCorrect(optionID string) bool {
+	for _, o := range p.Options {
+		if o.ID == optionID {
+			return o.Correct
+		}
+	}
+
+	return false
+}
+
+// HasOption checks whether the option ID belongs to the poll
+func (p Poll) HasOption(optionID string) bool {
+	for _, o := range p.Options {
+		if o.ID == optionID {
+			return true
+		}
+	}
+
+	return false
 }
 
 type service struct {
 	repo        Repo
 	broadcaster Broadcaster
+	now         func() time.Time
+	// retention is how long new polls are kept for, they are kept forever when it is zero
+	retention time.Duration
+	// rateLimiter is only set when votes are rate limited
+	rateLimiter    ratelimit.Limiter
+	voteRateLimits VoteRateLimits
+	fraudRules     FraudRules
+}
+
+type Option func(*service)
+
+// WithClock replaces the clock used to enforce poll deadlines
+func WithClock(now func() time.Time) Option {
+	return func(s *service) {
+		s.now = now
+	}
+}
+
+// WithRetention removes new polls and their votes once the retention period has passed since the
+// poll was created
+func WithRetention(retention time.Duration) Option {
+	return func(s *service) {
+		s.retention = retention
+	}
 }
 
-func New(r Repo, b Broadcaster) *service {
-	return &service{
+func New(r Repo, b Broadcaster, opts ...Option) *service {
+	s := &service{
 		repo:        r,
 		broadcaster: b,
+		now:         time.Now,
+		fraudRules:  DefaultFraudRules,
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
 func (s *service) GetPoll(ctx context.Context, pollID string) (Poll, error) {
@@ -60,16 +248,171 @@ func (s *service) GetPoll(ctx context.Context, pollID string) (Poll, error) {
 }
 
 type NewPoll struct {
-	Question   string
-	Options    []string
-	ChannelARN string
+	// Type defaults to a single choice poll
+	Type     PollType
+	Question string
+	Options  []string
+	// CorrectOptions are the indexes of the options that answer a quiz correctly
+	CorrectOptions []int
+	// MinSelections and MaxSelections limit how many options a multi-select vote can choose,
+	// they default to at least one option and at most every option
+	MinSelections int
+	MaxSelections int
+	// ScaleMin and ScaleMax are the inclusive range of values a scale poll accepts
+	ScaleMin int
+	ScaleMax int
+	// PredictionPoints are awarded for predicting the winning option, defaulting to 100
+	PredictionPoints int
+	// WeightRules maps a viewer tier to how many votes each of their votes counts as
+	WeightRules map[string]int
+	ChannelARN  string
+	// SessionID adds the poll to a quiz session on the same channel
+	SessionID string
+	// Draft polls are hidden from viewers and do not accept votes until they are opened
+	Draft bool
+	// Duration is how long viewers have to vote once the poll is open
+	Duration time.Duration
+	// ClosesAt is an absolute deadline for voting, it cannot be combined with Duration
+	ClosesAt *time.Time
+	// VotePolicy defaults to a single vote per user
+	VotePolicy VotePolicy
+	// CreatorID is the authenticated creator making the poll
+	CreatorID string
+	// VoteRateLimit replaces the per user vote rate limit for this poll
+	VoteRateLimit *ratelimit.Limit
 }
 
 func (s *service) CreatePoll(ctx context.Context, poll NewPoll) (Poll, error) {
+	status := PollStatusOpen
+	if poll.Draft {
+		status = PollStatusDraft
+	}
+
+	pollType := poll.Type
+	if pollType == "" {
+		pollType = PollTypeSingleChoice
+	}
+
+	var minSelections, maxSelections int
+	if pollType == PollTypeMultiSelect {
+		minSelections, maxSelections = poll.MinSelections, poll.MaxSelections
+		if minSelections == 0 {
+			minSelections = 1
+		}
+
+		if maxSelections == 0 {
+			maxSelections = len(poll.Options)
+		}
+
+		if minSelections < 1 || minSelections > maxSelections || maxSelections > len(poll.Options) {
+			return Poll{}, ErrInvalidSelectionLimits
+		}
+	}
+
+	// scale and free-text polls don't have a fixed set of answers to choose from
+	hasOptions := pollType != PollTypeScale && pollType != PollTypeFreeText
+	if hasOptions != (len(poll.Options) > 0) {
+		return Poll{}, ErrInvalidOptions
+	}
+
+	if pollType == PollTypeScale {
+		if poll.ScaleMin >= poll.ScaleMax || poll.ScaleMax-poll.ScaleMin > _maxScaleSteps {
+			return Poll{}, ErrInvalidScale
+		}
+	} else if poll.ScaleMin != 0 || poll.ScaleMax != 0 {
+		return Poll{}, ErrInvalidScale
+	}
+
+	if err := validateWeightRules(pollType, poll.WeightRules); err != nil {
+		return Poll{}, err
+	}
+
+	if pollType == PollTypeQuiz && len(poll.CorrectOptions) == 0 {
+		return Poll{}, ErrInvalidCorrectOptions
+	}
+
+	if pollType != PollTypeQuiz && len(poll.CorrectOptions) > 0 {
+		return Poll{}, ErrInvalidCorrectOptions
+	}
+
+	for _, i := range poll.CorrectOptions {
+		if i < 0 || i >= len(poll.Options) {
+			return Poll{}, ErrInvalidCorrectOptions
+		}
+	}
+
+	if poll.SessionID != "" {
+		session, err := s.GetSession(ctx, poll.SessionID)
+		if err != nil {
+			if err == ErrRecordNotFound {
+				return Poll{}, ErrSessionNotFound
+			}
+
+			return Poll{}, err
+		}
+
+		if session.ChannelARN != poll.ChannelARN {
+			return Poll{}, ErrSessionChannelMismatch
+		}
+	}
+
+	votePolicy := poll.VotePolicy
+	if votePolicy == "" {
+		votePolicy = VotePolicySingle
+	}
+
+	var predictionPoints int
+	if pollType == PollTypePrediction {
+		predictionPoints = poll.PredictionPoints
+		if predictionPoints == 0 {
+			predictionPoints = _defaultPredictionPoints
+		}
+	}
+
+	var openedAt *time.Time
+	if status == PollStatusOpen {
+		now := s.now()
+		openedAt = &now
+	}
+
+	closesAt := poll.ClosesAt
+	if closesAt != nil && !closesAt.After(s.now()) {
+		return Poll{}, ErrDeadlineInPast
+	}
+
+	if poll.Duration > 0 && status == PollStatusOpen {
+		deadline := s.now().Add(poll.Duration)
+		closesAt = &deadline
+	}
+
+	var expiresAt *time.Time
+	if s.retention > 0 {
+		expiry := s.now().Add(s.retention)
+		expiresAt = &expiry
+	}
+
 	newPoll, err := s.repo.CreatePoll(ctx, repository.NewPoll{
-		Question:   poll.Question,
-		Options:    poll.Options,
-		ChannelARN: poll.ChannelARN,
+		Type:             string(pollType),
+		Question:         poll.Question,
+		Options:          poll.Options,
+		CorrectOptions:   poll.CorrectOptions,
+		MinSelections:    minSelections,
+		MaxSelections:    maxSelections,
+		ScaleMin:         poll.ScaleMin,
+		ScaleMax:         poll.ScaleMax,
+		PredictionPoints: predictionPoints,
+		WeightRules:      poll.WeightRules,
+		ChannelARN:       poll.ChannelARN,
+		SessionID:        poll.SessionID,
+		Status:           string(status),
+		VotePolicy:       string(votePolicy),
+		DurationSeconds:  int(poll.Duration.Seconds()),
+		OpenedAt:         openedAt,
+		ClosesAt:         closesAt,
+		ExpiresAt:        expiresAt,
+		CreatedAt:        s.now(),
+		CreatorID:        poll.CreatorID,
+		VoteRateLimit:    mapRateLimitToDatabase(poll.VoteRateLimit),
 	})
 	if err != nil {
 		return Poll{}, fmt.Errorf("creating new poll: %w", err)
@@ -78,20 +421,295 @@ func (s *service) CreatePoll(ctx context.Context, poll NewPoll) (Poll, error) {
 	return mapDatabasePollToPoll(newPoll), nil
 }
 
+type broadcastPollOpened struct {
+	ID            string                `json:"id"`
+	Type          PollType              `json:"type"`
+	Question      string                `json:"question"`
+	Options       []broadcastPollOption `json:"options"`
+	MinSelections int                   `json:"minSelections"`
+	MaxSelections int                   `json:"maxSelections"`
+	Scale         *broadcastScaleRange  `json:"scale,omitempty"`
+	Status        PollStatus            `json:"status"`
+}
+
+type broadcastPollOption struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// newBroadcastPollOpened describes a poll for players without giving away a quiz's answer
+func newBroadcastPollOpened(poll Poll) broadcastPollOpened {
+	var opts []broadcastPollOption
+	for _, o := range poll.Options {
+		opts = append(opts, broadcastPollOption{
+			ID:    o.ID,
+			Label: o.Label,
+		})
+	}
+
+	return broadcastPollOpened{
+		ID:            poll.ID,
+		Type:          poll.Type,
+		Question:      poll.Question,
+		Options:       opts,
+		MinSelections: poll.MinSelections,
+		MaxSelections: poll.MaxSelections,
+		Scale:         newBroadcastScaleRange(poll),
+		Status:        poll.Status,
+	}
+}
+
+type broadcastPollClosed struct {
+	ID                   string         `json:"id"`
+	Status               PollStatus     `json:"status"`
+	AggregatedVoteTotals map[string]int `json:"aggregatedVoteTotals"`
+	WeightedVoteTotals   map[string]int `json:"weightedVoteTotals,omitempty"`
+	Scale                *ScaleSummary  `json:"scale,omitempty"`
+	Terms                []TermCount    `json:"terms,omitempty"`
+}
+
+// OpenPoll starts accepting votes for a draft poll, or reopens a closed poll, and lets the
+// channel's viewers know they can now vote
+func (s *service) OpenPoll(ctx context.Context, pollID string) (Poll, error) {
+	current, err := s.GetPoll(ctx, pollID)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	// the voting window starts from when the poll is opened, an absolute deadline is only kept
+	// whilst it is still in the future so reopened polls are not immediately closed again
+	now := s.now()
+
+	var closesAt *time.Time
+	if current.Duration > 0 {
+		deadline := now.Add(current.Duration)
+		closesAt = &deadline
+	} else if current.ClosesAt != nil && current.ClosesAt.After(now) {
+		closesAt = current.ClosesAt
+	}
+
+	poll, err := s.updatePollStatus(ctx, pollID, []PollStatus{PollStatusDraft, PollStatusClosed}, repository.PollStatusUpdate{
+		Status:   string(PollStatusOpen),
+		OpenedAt: &now,
+		ClosesAt: closesAt,
+	})
+	if err != nil {
+		return Poll{}, err
+	}
+
+	metadata := broadcast.CreateTypedMetadata(broadcast.EnvelopePollOpened, newBroadcastPollOpened(poll))
+
+	// the poll is already open, players that miss the broadcast pick it up from the periodic
+	// re-broadcast of its definition
+	if err := s.broadcastMetadata(ctx, poll.ChannelARN, metadata); err != nil {
+		log.Printf("error broadcasting poll %s opening: %s", poll.ID, err)
+	}
+
+	return poll, nil
+}
+
+// ClosePoll stops an open poll from accepting any more votes and lets the channel's viewers
+// know so players can hide the voting UI
+func (s *service) ClosePoll(ctx context.Context, pollID string) (Poll, error) {
+	poll, err := s.updatePollStatus(ctx, pollID, []PollStatus{PollStatusOpen}, repository.PollStatusUpdate{
+		Status: string(PollStatusClosed),
+	})
+	if err != nil {
+		return Poll{}, err
+	}
+
+	metadata := broadcast.CreateTypedMetadata(broadcast.EnvelopePollClosed, broadcastPollClosed{
+		ID:                   poll.ID,
+		Status:               poll.Status,
+		AggregatedVoteTotals: poll.AggregatedVoteTotals,
+		WeightedVoteTotals:   poll.WeightedVoteTotals,
+		Scale:                poll.ScaleSummary(),
+		Terms:                poll.TopTerms,
+	})
+
+	// the poll is already closed and won't accept any more votes, so failing the request would
+	// only make a retry fail with a conflict
+	if err := s.broadcastMetadata(ctx, poll.ChannelARN, metadata); err != nil {
+		log.Printf("error broadcasting poll %s closing: %s", poll.ID, err)
+	}
+
+	return poll, nil
+}
+
+// CloseExpiredPolls closes every open poll whose deadline has passed, broadcasting the final
+// results for each of them
+func (s *service) CloseExpiredPolls(ctx context.Context) ([]Poll, error) {
+	ids, err := s.repo.ListExpiredPollIDs(ctx, s.now())
+	if err != nil {
+		return nil, fmt.Errorf("listing expired polls: %w", err)
+	}
+
+	var closed []Poll
+	var errs []error
+	for _, id := range ids {
+		poll, err := s.ClosePoll(ctx, id)
+		if err != nil {
+			// the poll was closed by someone else since it was listed
+			if err == ErrInvalidStatusTransition || err == ErrRecordNotFound {
+				continue
+			}
+
+			errs = append(errs, fmt.Errorf("closing poll %s: %w", id, err))
+			continue
+		}
+
+		closed = append(closed, poll)
+	}
+
+	if len(errs) > 0 {
+		return closed, fmt.Errorf("closing %d expired poll(s): %v", len(errs), errs)
+	}
+
+	return closed, nil
+}
+
+func (s *service) updatePollStatus(ctx context.Context, pollID string, from []PollStatus, update repository.PollStatusUpdate) (Poll, error) {
+	var allowedFrom []string
+	for _, status := range from {
+		allowedFrom = append(allowedFrom, string(status))
+	}
+
+	poll, err := s.repo.UpdatePollStatus(ctx, pollID, allowedFrom, update)
+	if err != nil {
+		if err == repository.ErrPollNotFound {
+			return Poll{}, ErrRecordNotFound
+		}
+
+		if err == repository.ErrPollStatusConflict {
+			return Poll{}, ErrInvalidStatusTransition
+		}
+
+		return Poll{}, fmt.Errorf("updating poll status: %w", err)
+	}
+
+	return mapDatabasePollToPoll(poll), nil
+}
+
+// DeletePoll removes a poll and all of its votes, a delete that failed part way through can be
+// retried to finish it
+func (s *service) DeletePoll(ctx context.Context, pollID string) error {
+	if err := s.repo.DeletePoll(ctx, pollID, s.now()); err != nil {
+		if err == repository.ErrPollNotFound {
+			return ErrRecordNotFound
+		}
+
+		return fmt.Errorf("deleting poll: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) broadcastMetadata(ctx context.Context, channelARN string, metadata broadcast.Metadata) error {
+	jsonMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("error marhsalling broadcast metadata: %w", err)
+	}
+
+	if err := s.broadcaster.Broadcast(ctx, channelARN, string(jsonMetadata)); err != nil {
+		return fmt.Errorf("error sending metadata to channel: %w", err)
+	}
+
+	return nil
+}
+
 func mapDatabasePollToPoll(dbPoll repository.DatabasePoll) Poll {
 	var opts []PollOption
 	for _, o := range dbPoll.Options {
 		opts = append(opts, PollOption{
-			ID:    o.ID,
-			Label: o.Label,
+			ID:      o.ID,
+			Label:   o.Label,
+			Correct: o.Correct,
 		})
 	}
 
+	status := PollStatus(dbPoll.Status)
+	if status == "" {
+		// polls created before statuses were introduced have always been open
+		status = PollStatusOpen
+	}
+
+	pollType := PollType(dbPoll.Type)
+	if pollType == "" {
+		pollType = PollTypeSingleChoice
+	}
+
+	// single choice polls always take exactly one option
+	minSelections, maxSelections := 1, 1
+	switch pollType {
+	case PollTypeMultiSelect:
+		minSelections, maxSelections = dbPoll.MinSelections, dbPoll.MaxSelections
+	case PollTypeRankedChoice:
+		// ballots can rank as few or as many of the options as the voter likes
+		maxSelections = len(dbPoll.Options)
+	}
+
+	votePolicy := VotePolicy(dbPoll.VotePolicy)
+	if votePolicy == "" {
+		votePolicy = VotePolicySingle
+	}
+
 	return Poll{
 		ID:                   dbPoll.ID,
+		Type:                 pollType,
 		Question:             dbPoll.Question,
 		Options:              opts,
+		MinSelections:        minSelections,
+		MaxSelections:        maxSelections,
+		ScaleMin:             dbPoll.ScaleMin,
+		ScaleMax:             dbPoll.ScaleMax,
 		ChannelARN:           dbPoll.ChannelARN,
+		SessionID:            dbPoll.SessionID,
+		Status:               status,
+		VotePolicy:           votePolicy,
+		Duration:             time.Duration(dbPoll.DurationSeconds) * time.Second,
+		OpenedAt:             parseOptionalTimestamp(dbPoll.OpenedAt),
+		ClosesAt:             parseOptionalTimestamp(dbPoll.ClosesAt),
+		AnswerRevealed:       dbPoll.AnswerRevealed,
+		Version:              dbPoll.Version,
+		HasVotes:             dbPoll.HasVotes,
+		PredictionPoints:     dbPoll.PredictionPoints,
+		WinningOptionID:      dbPoll.WinningOptionID,
+		ResolvedAt:           parseOptionalTimestamp(dbPoll.ResolvedAt),
+		AwardedAt:            parseOptionalTimestamp(dbPoll.AwardedAt),
+		DeletingAt:           parseOptionalTimestamp(dbPoll.DeletingAt),
 		AggregatedVoteTotals: dbPoll.AggregatedVoteTotals,
+		WeightRules:          dbPoll.WeightRules,
+		WeightedVoteTotals:   dbPoll.WeightedVoteTotals,
+		ScaleStats:           mapDatabaseScaleStats(dbPoll.ScaleStats),
+		TopTerms:             mapDatabaseTermCounts(dbPoll.TopTerms),
+		ExpiresAt:            parseOptionalExpiry(dbPoll.ExpiresAt),
+		CreatedAt:            parseOptionalTimestamp(dbPoll.CreatedAt),
+		CreatorID:            dbPoll.CreatorID,
+		VoteRateLimit:        mapDatabaseRateLimit(dbPoll.VoteRateLimit),
 	}
 }
+
+// parseOptionalTimestamp reads a stored timestamp, returning nil when it hasn't been set
+func parseOptionalTimestamp(t string) *time.Time {
+	if t == "" {
+		return nil
+	}
+
+	parsed, err := repository.ParseTimestamp(t)
+	if err != nil {
+		return nil
+	}
+
+	return &parsed
+}
+
+// parseOptionalExpiry reads a stored TTL, returning nil when the item never expires
+func parseOptionalExpiry(expiresAt int64) *time.Time {
+	if expiresAt == 0 {
+		return nil
+	}
+
+	t := time.Unix(expiresAt, 0).UTC()
+
+	return &t
+}
@@ -0,0 +1,633 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+// fakeRepo keeps polls in memory, any Repo methods a test relies on without overriding will panic
+type fakeRepo struct {
+	Repo
+	polls map[string]repository.DatabasePoll
+	votes []repository.NewPollVote
+}
+
+func newFakeRepo(polls ...repository.DatabasePoll) *fakeRepo {
+	r := &fakeRepo{polls: make(map[string]repository.DatabasePoll)}
+	for _, p := range polls {
+		r.polls[p.ID] = p
+	}
+
+	return r
+}
+
+func (r *fakeRepo) ListPollVotes(ctx context.Context, pollID string) ([]repository.DatabasePollVote, error) {
+	var votes []repository.DatabasePollVote
+	for _, v := range r.votes {
+		if v.PollID == pollID {
+			votes = append(votes, repository.DatabasePollVote{PollID: v.PollID, UserID: v.UserID, Answer: v.Answer, Ranking: v.Ranking, Correct: v.Correct})
+		}
+	}
+
+	return votes, nil
+}
+
+func (r *fakeRepo) GetPoll(ctx context.Context, pollID string) (repository.DatabasePoll, error) {
+	p, ok := r.polls[pollID]
+	if !ok {
+		return repository.DatabasePoll{}, repository.ErrPollNotFound
+	}
+
+	return p, nil
+}
+
+func (r *fakeRepo) UpdatePollStatus(ctx context.Context, pollID string, allowedFrom []string, update repository.PollStatusUpdate) (repository.DatabasePoll, error) {
+	p, ok := r.polls[pollID]
+	if !ok {
+		return repository.DatabasePoll{}, repository.ErrPollNotFound
+	}
+
+	for _, s := range allowedFrom {
+		if p.Status == s {
+			p.Status = update.Status
+			r.polls[pollID] = p
+			return p, nil
+		}
+	}
+
+	return repository.DatabasePoll{}, repository.ErrPollStatusConflict
+}
+
+func (r *fakeRepo) ListExpiredPollIDs(ctx context.Context, now time.Time) ([]string, error) {
+	var ids []string
+	for _, p := range r.polls {
+		closesAt, err := repository.ParseTimestamp(p.ClosesAt)
+		if err == nil && p.Status == repository.PollStatusOpen && !closesAt.After(now) {
+			ids = append(ids, p.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+func (r *fakeRepo) CreatePoll(ctx context.Context, poll repository.NewPoll) (repository.DatabasePoll, error) {
+	p := repository.DatabasePoll{
+		ID:            fmt.Sprintf("poll-%d", len(r.polls)+1),
+		Type:          poll.Type,
+		Question:      poll.Question,
+		ChannelARN:    poll.ChannelARN,
+		Status:        poll.Status,
+		VotePolicy:    poll.VotePolicy,
+		MinSelections: poll.MinSelections,
+		MaxSelections: poll.MaxSelections,
+	}
+	for i, label := range poll.Options {
+		p.Options = append(p.Options, repository.DatabasePollOption{ID: strconv.Itoa(i), Label: label})
+	}
+	r.polls[p.ID] = p
+
+	return p, nil
+}
+
+func (r *fakeRepo) CreatePollVote(ctx context.Context, v repository.NewPollVote) (repository.DatabasePollVote, bool, error) {
+	replaced := false
+
+	if v.FirstVote {
+		p := r.polls[v.PollID]
+		if p.Version != v.PollVersion {
+			return repository.DatabasePollVote{}, false, repository.ErrPollVersionConflict
+		}
+
+		p.HasVotes = true
+		r.polls[v.PollID] = p
+	}
+
+	if v.Policy != repository.VotePolicyUnlimited {
+		for i, existing := range r.votes {
+			if existing.PollID != v.PollID || existing.UserID != v.UserID {
+				continue
+			}
+
+			if v.Policy != repository.VotePolicyChangeable {
+				return repository.DatabasePollVote{}, false, repository.ErrVoteAlreadyExists
+			}
+
+			r.votes = append(r.votes[:i], r.votes[i+1:]...)
+			replaced = true
+			break
+		}
+	}
+
+	r.votes = append(r.votes, v)
+
+	return repository.DatabasePollVote{PollID: v.PollID, UserID: v.UserID, Answer: v.Answer}, replaced, nil
+}
+
+type fakeBroadcaster struct {
+	messages map[string][]string
+}
+
+func (b *fakeBroadcaster) Broadcast(ctx context.Context, channelARN string, data string) error {
+	if b.messages == nil {
+		b.messages = make(map[string][]string)
+	}
+
+	b.messages[channelARN] = append(b.messages[channelARN], data)
+
+	return nil
+}
+
+// failingBroadcaster fails every broadcast, as when IVS is unavailable
+type failingBroadcaster struct{}
+
+func (b failingBroadcaster) Broadcast(ctx context.Context, channelARN string, data string) error {
+	return errors.New("channel unavailable")
+}
+
+func fixedClock(t time.Time) func() time.Time {
+	return func() time.Time {
+		return t
+	}
+}
+
+func TestCloseExpiredPolls(t *testing.T) {
+	now := time.Date(2022, 7, 10, 12, 0, 0, 0, time.UTC)
+
+	repo := newFakeRepo(
+		repository.DatabasePoll{ID: "expired", ChannelARN: "channel-a", Status: repository.PollStatusOpen, ClosesAt: "2022-07-10T11:59:30Z"},
+		repository.DatabasePoll{ID: "running", ChannelARN: "channel-b", Status: repository.PollStatusOpen, ClosesAt: "2022-07-10T12:00:30Z"},
+	)
+	broadcaster := &fakeBroadcaster{}
+
+	svc := New(repo, broadcaster, WithClock(fixedClock(now)))
+
+	closed, err := svc.CloseExpiredPolls(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(closed) != 1 || closed[0].ID != "expired" {
+		t.Fatalf("expected only the expired poll to be closed, got %+v", closed)
+	}
+
+	if repo.polls["running"].Status != repository.PollStatusOpen {
+		t.Errorf("expected the running poll to stay open")
+	}
+
+	if len(broadcaster.messages["channel-a"]) != 1 {
+		t.Errorf("expected the final results to be broadcast to the expired poll's channel")
+	}
+}
+
+func TestStatusChangesSurviveBroadcastFailures(t *testing.T) {
+	repo := newFakeRepo(repository.DatabasePoll{ID: "poll", ChannelARN: "channel", Status: repository.PollStatusDraft})
+	svc := New(repo, failingBroadcaster{})
+
+	opened, err := svc.OpenPoll(context.Background(), "poll")
+	if err != nil || opened.Status != PollStatusOpen {
+		t.Fatalf("expected the poll to be opened despite the broadcast failing, got %+v, %v", opened, err)
+	}
+
+	closed, err := svc.ClosePoll(context.Background(), "poll")
+	if err != nil || closed.Status != PollStatusClosed {
+		t.Fatalf("expected the poll to be closed despite the broadcast failing, got %+v, %v", closed, err)
+	}
+}
+
+// fakeDeleteRepo deletes polls the same way the repository does, marking a poll as deleting, then
+// removing its votes before the poll itself
+type fakeDeleteRepo struct {
+	*fakeRepo
+	// deleteErr fails the next poll delete after its first vote has been removed
+	deleteErr error
+}
+
+func (r *fakeDeleteRepo) DeletePoll(ctx context.Context, pollID string, deletingAt time.Time) error {
+	found := false
+	if p, ok := r.polls[pollID]; ok {
+		found = true
+		if p.DeletingAt == "" {
+			p.DeletingAt = deletingAt.Format(time.RFC3339)
+			r.polls[pollID] = p
+		}
+	}
+
+	for i := len(r.votes) - 1; i >= 0; i-- {
+		if r.votes[i].PollID != pollID {
+			continue
+		}
+
+		found = true
+		r.votes = append(r.votes[:i], r.votes[i+1:]...)
+
+		if r.deleteErr != nil {
+			err := r.deleteErr
+			r.deleteErr = nil
+			return err
+		}
+	}
+
+	if !found {
+		return repository.ErrPollNotFound
+	}
+
+	delete(r.polls, pollID)
+
+	return nil
+}
+
+func TestDeletePollCanBeRetried(t *testing.T) {
+	repo := &fakeDeleteRepo{fakeRepo: newFakeRepo(repository.DatabasePoll{
+		ID:                   "poll",
+		ChannelARN:           "channel",
+		Status:               repository.PollStatusOpen,
+		Options:              []repository.DatabasePollOption{{ID: "a", Label: "A"}},
+		AggregatedVoteTotals: repository.DatabasePollTotals{"a": 2},
+	})}
+	repo.votes = []repository.NewPollVote{
+		{PollID: "poll", UserID: "viewer-1", Answer: "a"},
+		{PollID: "poll", UserID: "viewer-2", Answer: "a"},
+	}
+	repo.deleteErr = errors.New("throttled")
+
+	svc := New(repo, &fakeBroadcaster{})
+
+	if err := svc.DeletePoll(context.Background(), "poll"); err == nil {
+		t.Fatalf("expected the first delete to fail part way through")
+	}
+
+	if _, err := svc.GetPoll(context.Background(), "poll"); err != nil {
+		t.Fatalf("expected the partly deleted poll to still be found so its owner can retry, got %v", err)
+	}
+
+	if _, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "poll", UserID: "viewer-3", Answer: "a"}); err != ErrPollNotOpen {
+		t.Errorf("expected votes on a poll being deleted to be rejected, got %v", err)
+	}
+
+	err := svc.IncrementPollTotals(context.Background(), "poll", []string{"1"}, map[string]int{"a": -1}, nil)
+	if !errors.Is(err, repository.ErrPollNotFound) {
+		t.Errorf("expected the removed vote to be ignored by the aggregator, got %v", err)
+	}
+
+	if repo.polls["poll"].AggregatedVoteTotals["a"] != 2 {
+		t.Errorf("expected the removed vote not to be counted as a retraction, got %v", repo.polls["poll"].AggregatedVoteTotals)
+	}
+
+	if err := svc.DeletePoll(context.Background(), "poll"); err != nil {
+		t.Fatalf("expected the retried delete to finish, got %v", err)
+	}
+
+	if len(repo.polls) != 0 || len(repo.votes) != 0 {
+		t.Errorf("expected nothing to be left, got %d polls and %d votes", len(repo.polls), len(repo.votes))
+	}
+
+	if err := svc.DeletePoll(context.Background(), "poll"); err != ErrRecordNotFound {
+		t.Errorf("expected ErrRecordNotFound once there is nothing left, got %v", err)
+	}
+}
+
+func TestCreatePollVoteAfterDeadline(t *testing.T) {
+	repo := newFakeRepo(repository.DatabasePoll{
+		ID:       "poll",
+		Status:   repository.PollStatusOpen,
+		Options:  []repository.DatabasePollOption{{ID: "a", Label: "A"}},
+		ClosesAt: "2022-07-10T12:00:00Z",
+	})
+
+	t.Run("Before the deadline", func(t *testing.T) {
+		svc := New(repo, &fakeBroadcaster{}, WithClock(fixedClock(time.Date(2022, 7, 10, 11, 59, 59, 0, time.UTC))))
+
+		if _, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "poll", UserID: "user", Answer: "a"}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("After the deadline", func(t *testing.T) {
+		svc := New(repo, &fakeBroadcaster{}, WithClock(fixedClock(time.Date(2022, 7, 10, 12, 0, 0, 0, time.UTC))))
+
+		_, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "poll", UserID: "another-user", Answer: "a"})
+		if err != ErrPollNotOpen {
+			t.Fatalf("expected ErrPollNotOpen, got %v", err)
+		}
+	})
+}
+
+func TestCreatePollVoteUnknownAnswer(t *testing.T) {
+	repo := newFakeRepo(repository.DatabasePoll{
+		ID:      "poll",
+		Status:  repository.PollStatusOpen,
+		Options: []repository.DatabasePollOption{{ID: "a", Label: "A"}},
+	})
+
+	svc := New(repo, &fakeBroadcaster{})
+
+	_, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "poll", UserID: "user", Answer: "b"})
+	if err != ErrInvalidAnswer {
+		t.Fatalf("expected ErrInvalidAnswer, got %v", err)
+	}
+
+	if len(repo.votes) != 0 {
+		t.Errorf("expected the vote not to be stored")
+	}
+}
+
+func TestCreatePollSelectionLimits(t *testing.T) {
+	tests := []struct {
+		name          string
+		minSelections int
+		maxSelections int
+		expectedMin   int
+		expectedMax   int
+		err           error
+	}{
+		{name: "defaults to any number of options", expectedMin: 1, expectedMax: 3},
+		{name: "exact number of options", minSelections: 2, maxSelections: 2, expectedMin: 2, expectedMax: 2},
+		{name: "minimum only", minSelections: 2, expectedMin: 2, expectedMax: 3},
+		{name: "maximum only", maxSelections: 2, expectedMin: 1, expectedMax: 2},
+		{name: "more than there are options", maxSelections: 4, err: ErrInvalidSelectionLimits},
+		{name: "minimum above maximum", minSelections: 3, maxSelections: 2, err: ErrInvalidSelectionLimits},
+		{name: "minimum above the default maximum", minSelections: 4, err: ErrInvalidSelectionLimits},
+		{name: "negative minimum", minSelections: -1, maxSelections: 2, err: ErrInvalidSelectionLimits},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := New(newFakeRepo(), &fakeBroadcaster{})
+
+			poll, err := svc.CreatePoll(context.Background(), NewPoll{
+				Type:          PollTypeMultiSelect,
+				Question:      "Which toppings?",
+				Options:       []string{"Cheese", "Ham", "Pineapple"},
+				MinSelections: tt.minSelections,
+				MaxSelections: tt.maxSelections,
+				ChannelARN:    "channel",
+			})
+			if err != tt.err {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+
+			if err == nil && (poll.MinSelections != tt.expectedMin || poll.MaxSelections != tt.expectedMax) {
+				t.Errorf("expected between %d and %d selections, got %d and %d", tt.expectedMin, tt.expectedMax, poll.MinSelections, poll.MaxSelections)
+			}
+		})
+	}
+}
+
+func TestCreatePollVoteSelections(t *testing.T) {
+	tests := []struct {
+		name     string
+		answer   string
+		answers  []string
+		err      error
+		expected []string
+	}{
+		{name: "too few", answers: []string{"a"}, err: ErrInvalidSelectionCount},
+		{name: "fewest allowed", answers: []string{"a", "b"}, expected: []string{"a", "b"}},
+		{name: "most allowed", answers: []string{"a", "b", "c"}, expected: []string{"a", "b", "c"}},
+		{name: "too many", answers: []string{"a", "b", "c", "d"}, err: ErrInvalidSelectionCount},
+		{name: "single answer combined with answers", answer: "a", answers: []string{"b"}, expected: []string{"a", "b"}},
+		{name: "duplicate answers", answers: []string{"a", "a"}, err: ErrInvalidAnswer},
+		{name: "single answer repeated in answers", answer: "a", answers: []string{"a"}, err: ErrInvalidAnswer},
+		{name: "unknown answer", answers: []string{"a", "e"}, err: ErrInvalidAnswer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo(repository.DatabasePoll{
+				ID:            "poll",
+				Type:          repository.PollTypeMultiSelect,
+				Status:        repository.PollStatusOpen,
+				MinSelections: 2,
+				MaxSelections: 3,
+				Options: []repository.DatabasePollOption{
+					{ID: "a", Label: "A"}, {ID: "b", Label: "B"}, {ID: "c", Label: "C"}, {ID: "d", Label: "D"},
+				},
+			})
+
+			svc := New(repo, &fakeBroadcaster{})
+
+			_, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "poll", UserID: "user", Answer: tt.answer, Answers: tt.answers})
+			if err != tt.err {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+
+			if tt.err != nil {
+				if len(repo.votes) != 0 {
+					t.Errorf("expected the vote not to be stored")
+				}
+
+				return
+			}
+
+			if len(repo.votes) != 1 || !reflect.DeepEqual(repo.votes[0].Answers, tt.expected) {
+				t.Errorf("expected the vote to be stored with answers %v, got %+v", tt.expected, repo.votes)
+			}
+		})
+	}
+}
+
+func TestCreatePollVotePolicies(t *testing.T) {
+	tests := []struct {
+		policy         string
+		secondVoteErr  error
+		secondReplaced bool
+		storedVotes    int
+	}{
+		{policy: repository.VotePolicySingle, secondVoteErr: ErrAlreadyVoted, storedVotes: 1},
+		{policy: repository.VotePolicyChangeable, secondReplaced: true, storedVotes: 1},
+		{policy: repository.VotePolicyUnlimited, storedVotes: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			repo := newFakeRepo(repository.DatabasePoll{
+				ID:         "poll",
+				Status:     repository.PollStatusOpen,
+				VotePolicy: tt.policy,
+				Options:    []repository.DatabasePollOption{{ID: "a", Label: "A"}, {ID: "b", Label: "B"}},
+			})
+
+			svc := New(repo, &fakeBroadcaster{})
+
+			first, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "poll", UserID: "user", Answer: "a"})
+			if err != nil || first.Replaced {
+				t.Fatalf("expected the first vote to be new, got %+v %v", first, err)
+			}
+
+			second, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "poll", UserID: "user", Answer: "b"})
+			if err != tt.secondVoteErr {
+				t.Fatalf("expected %v, got %v", tt.secondVoteErr, err)
+			}
+
+			if second.Replaced != tt.secondReplaced {
+				t.Errorf("expected replaced to be %t", tt.secondReplaced)
+			}
+
+			if len(repo.votes) != tt.storedVotes {
+				t.Errorf("expected %d stored vote(s), got %d", tt.storedVotes, len(repo.votes))
+			}
+		})
+	}
+}
+
+func TestCreatePollVoteRecordsQuizCorrectness(t *testing.T) {
+	repo := newFakeRepo(repository.DatabasePoll{
+		ID:         "quiz",
+		Type:       repository.PollTypeQuiz,
+		Status:     repository.PollStatusOpen,
+		VotePolicy: repository.VotePolicyChangeable,
+		Options:    []repository.DatabasePollOption{{ID: "a", Label: "A", Correct: true}, {ID: "b", Label: "B"}},
+	})
+
+	svc := New(repo, &fakeBroadcaster{})
+
+	for _, answer := range []string{"a", "b"} {
+		if _, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "quiz", UserID: answer, Answer: answer}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if c := repo.votes[0].Correct; c == nil || !*c {
+		t.Errorf("expected the vote for a to be correct")
+	}
+
+	if c := repo.votes[1].Correct; c == nil || *c {
+		t.Errorf("expected the vote for b to be incorrect")
+	}
+}
+
+func TestCreatePollVoteAppliesTierWeight(t *testing.T) {
+	repo := newFakeRepo(repository.DatabasePoll{
+		ID:          "poll",
+		Status:      repository.PollStatusOpen,
+		Options:     []repository.DatabasePollOption{{ID: "a", Label: "A"}},
+		WeightRules: map[string]int{"subscriber": 3},
+	})
+
+	svc := New(repo, &fakeBroadcaster{})
+
+	for _, tier := range []string{"subscriber", "", "unknown"} {
+		if _, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "poll", UserID: "user-" + tier, Answer: "a", Tier: tier}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	for i, expected := range []int{3, 1, 1} {
+		if repo.votes[i].Weight != expected {
+			t.Errorf("expected vote %d to have a weight of %d, got %d", i, expected, repo.votes[i].Weight)
+		}
+	}
+}
+
+func TestCreatePollVoteExpiresWithPoll(t *testing.T) {
+	repo := newFakeRepo(repository.DatabasePoll{
+		ID:        "poll",
+		Status:    repository.PollStatusOpen,
+		Options:   []repository.DatabasePollOption{{ID: "a", Label: "A"}},
+		ExpiresAt: 1660132800,
+	})
+
+	svc := New(repo, &fakeBroadcaster{})
+
+	if _, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "poll", UserID: "user", Answer: "a"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expiresAt := repo.votes[0].ExpiresAt
+	if expiresAt == nil || expiresAt.Unix() != 1660132800 {
+		t.Errorf("expected the vote to expire with its poll, got %v", expiresAt)
+	}
+}
+
+func (r *fakeRepo) GetPollVote(ctx context.Context, pollID string, userID string) (repository.DatabasePollVote, error) {
+	for i := len(r.votes) - 1; i >= 0; i-- {
+		if v := r.votes[i]; v.PollID == pollID && v.UserID == userID {
+			return repository.DatabasePollVote{PollID: v.PollID, UserID: v.UserID, Answer: v.Answer}, nil
+		}
+	}
+
+	return repository.DatabasePollVote{}, repository.ErrVoteNotFound
+}
+
+func TestGetPollVote(t *testing.T) {
+	repo := newFakeRepo(repository.DatabasePoll{
+		ID:         "poll",
+		Status:     repository.PollStatusOpen,
+		VotePolicy: repository.VotePolicyChangeable,
+		Options:    []repository.DatabasePollOption{{ID: "a", Label: "A"}, {ID: "b", Label: "B"}},
+	})
+
+	svc := New(repo, &fakeBroadcaster{})
+
+	if _, err := svc.GetPollVote(context.Background(), "poll", "user"); err != ErrVoteNotFound {
+		t.Fatalf("expected ErrVoteNotFound before voting, got %v", err)
+	}
+
+	for _, answer := range []string{"a", "b"} {
+		if _, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: "poll", UserID: "user", Answer: answer}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	vote, err := svc.GetPollVote(context.Background(), "poll", "user")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if vote.Answer != "b" {
+		t.Errorf("expected the changed vote to be returned, got %s", vote.Answer)
+	}
+
+	if _, err := svc.GetPollVote(context.Background(), "missing", "user"); err != ErrRecordNotFound {
+		t.Errorf("expected ErrRecordNotFound for a missing poll, got %v", err)
+	}
+}
+
+func (r *fakeRepo) GetPolls(ctx context.Context, pollIDs []string) (map[string]repository.DatabasePoll, error) {
+	polls := make(map[string]repository.DatabasePoll)
+	for _, id := range pollIDs {
+		if p, ok := r.polls[id]; ok {
+			polls[id] = p
+		}
+	}
+
+	return polls, nil
+}
+
+func (r *fakeRepo) ListVotesByUser(ctx context.Context, userID string, limit int, cursor string) ([]repository.DatabasePollVote, string, error) {
+	var votes []repository.DatabasePollVote
+	for _, v := range r.votes {
+		if v.UserID == userID {
+			votes = append(votes, repository.DatabasePollVote{PollID: v.PollID, UserID: v.UserID, Answer: v.Answer})
+		}
+	}
+
+	return votes, "", nil
+}
+
+func TestListUserVotesSkipsDeletedPolls(t *testing.T) {
+	repo := newFakeRepo(repository.DatabasePoll{ID: "kept", Question: "Still here?"})
+	repo.votes = []repository.NewPollVote{
+		{PollID: "kept", UserID: "user", Answer: "a"},
+		{PollID: "deleted", UserID: "user", Answer: "b"},
+		{PollID: "kept", UserID: "someone-else", Answer: "a"},
+	}
+
+	svc := New(repo, &fakeBroadcaster{})
+
+	history, err := svc.ListUserVotes(context.Background(), "user", 10, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(history.Entries) != 1 || history.Entries[0].Poll.Question != "Still here?" {
+		t.Errorf("expected only the vote on the remaining poll, got %+v", history.Entries)
+	}
+}
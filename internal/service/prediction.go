@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+var ErrInvalidWinningOption = errors.New("winning option is not one of the prediction's options")
+var ErrPollAlreadyResolved = errors.New("prediction has already been resolved")
+
+const _defaultPredictionPoints = 100
+
+// PredictionResolution is the outcome of resolving a prediction
+type PredictionResolution struct {
+	Poll Poll
+	// Winners and Losers count the viewers who did and didn't predict the winning option
+	Winners int
+	Losers  int
+}
+
+type broadcastPredictionResolved struct {
+	ID                   string         `json:"id"`
+	WinningOptionID      string         `json:"winningOptionId"`
+	PointsPerWinner      int            `json:"pointsPerWinner"`
+	Winners              int            `json:"winners"`
+	Losers               int            `json:"losers"`
+	AggregatedVoteTotals map[string]int `json:"aggregatedVoteTotals"`
+}
+
+// ResolvePoll records the winning option of a closed prediction, pays out points to everyone who
+// predicted it and broadcasts the outcome to the channel. Only a viewer's latest vote counts
+// towards the outcome. A resolution that failed before everyone was paid out can be retried with
+// the same winning option to finish paying out.
+func (s *service) ResolvePoll(ctx context.Context, pollID string, winningOptionID string) (PredictionResolution, error) {
+	poll, err := s.GetPoll(ctx, pollID)
+	if err != nil {
+		return PredictionResolution{}, err
+	}
+
+	if poll.Type != PollTypePrediction {
+		return PredictionResolution{}, ErrUnsupportedPollType
+	}
+
+	if poll.WinningOptionID != "" && (poll.AwardedAt != nil || poll.WinningOptionID != winningOptionID) {
+		return PredictionResolution{}, ErrPollAlreadyResolved
+	}
+
+	if poll.WinningOptionID == "" {
+		if poll.Status != PollStatusClosed {
+			return PredictionResolution{}, ErrPollNotClosed
+		}
+
+		if !poll.HasOption(winningOptionID) {
+			return PredictionResolution{}, ErrInvalidWinningOption
+		}
+
+		resolved, err := s.repo.ResolvePoll(ctx, pollID, winningOptionID, s.now())
+		if err != nil {
+			if err == repository.ErrPollNotFound {
+				return PredictionResolution{}, ErrRecordNotFound
+			}
+
+			if err == repository.ErrPollAlreadyResolved {
+				return PredictionResolution{}, ErrPollAlreadyResolved
+			}
+
+			return PredictionResolution{}, fmt.Errorf("resolving prediction: %w", err)
+		}
+
+		poll = mapDatabasePollToPoll(resolved)
+	}
+
+	resolution := PredictionResolution{Poll: poll}
+
+	votes, err := s.latestVotePerUser(ctx, poll.ID)
+	if err != nil {
+		return PredictionResolution{}, err
+	}
+
+	for userID, vote := range votes {
+		won := vote.Answer == poll.WinningOptionID
+
+		points := 0
+		if won {
+			points = poll.PredictionPoints
+			resolution.Winners++
+		} else {
+			resolution.Losers++
+		}
+
+		// viewers paid out before an earlier attempt failed are skipped
+		if err := s.repo.AddUserPoints(ctx, poll.ID, userID, points, won, poll.ExpiresAt); err != nil && err != repository.ErrPointsAlreadyAwarded {
+			return PredictionResolution{}, fmt.Errorf("adding user points: %w", err)
+		}
+	}
+
+	if err := s.repo.CompletePollAwards(ctx, poll.ID, s.now()); err != nil {
+		return PredictionResolution{}, fmt.Errorf("completing prediction payouts: %w", err)
+	}
+
+	metadata := broadcast.CreateTypedMetadata(broadcast.EnvelopePredictionResolved, broadcastPredictionResolved{
+		ID:                   poll.ID,
+		WinningOptionID:      poll.WinningOptionID,
+		PointsPerWinner:      poll.PredictionPoints,
+		Winners:              resolution.Winners,
+		Losers:               resolution.Losers,
+		AggregatedVoteTotals: poll.AggregatedVoteTotals,
+	})
+
+	// everyone has been paid out, so the prediction is resolved even if viewers aren't told
+	if err := s.broadcastMetadata(ctx, poll.ChannelARN, metadata); err != nil {
+		log.Printf("error broadcasting prediction %s resolution: %s", poll.ID, err)
+	}
+
+	return resolution, nil
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+// fakePointsRepo adds viewer points and the polls they were awarded for to fakeRepo
+type fakePointsRepo struct {
+	*fakeRepo
+	points map[string]int
+	awards map[string]bool
+	// awardErr fails the next points award
+	awardErr error
+}
+
+func newFakePointsRepo(polls ...repository.DatabasePoll) *fakePointsRepo {
+	return &fakePointsRepo{
+		fakeRepo: newFakeRepo(polls...),
+		points:   make(map[string]int),
+		awards:   make(map[string]bool),
+	}
+}
+
+func (r *fakePointsRepo) ResolvePoll(ctx context.Context, pollID string, winningOptionID string, resolvedAt time.Time) (repository.DatabasePoll, error) {
+	p := r.polls[pollID]
+	if p.WinningOptionID != "" {
+		return repository.DatabasePoll{}, repository.ErrPollAlreadyResolved
+	}
+
+	p.WinningOptionID = winningOptionID
+	r.polls[pollID] = p
+
+	return p, nil
+}
+
+func (r *fakePointsRepo) AddUserPoints(ctx context.Context, pollID string, userID string, points int, won bool, expiresAt *time.Time) error {
+	if err := r.awardErr; err != nil {
+		r.awardErr = nil
+		return err
+	}
+
+	if r.awards[pollID+userID] {
+		return repository.ErrPointsAlreadyAwarded
+	}
+
+	r.awards[pollID+userID] = true
+	r.points[userID] += points
+
+	return nil
+}
+
+func (r *fakePointsRepo) CompletePollAwards(ctx context.Context, pollID string, awardedAt time.Time) error {
+	p := r.polls[pollID]
+	p.AwardedAt = awardedAt.Format(time.RFC3339)
+	r.polls[pollID] = p
+
+	return nil
+}
+
+func TestResolvePoll(t *testing.T) {
+	repo := newFakePointsRepo(repository.DatabasePoll{
+		ID:               "prediction",
+		Type:             repository.PollTypePrediction,
+		Status:           repository.PollStatusClosed,
+		PredictionPoints: 50,
+		Options:          []repository.DatabasePollOption{{ID: "win", Label: "Win"}, {ID: "lose", Label: "Lose"}},
+	})
+	repo.votes = []repository.NewPollVote{
+		{PollID: "prediction", UserID: "optimist", Answer: "win"},
+		{PollID: "prediction", UserID: "pessimist", Answer: "lose"},
+	}
+
+	broadcaster := &fakeBroadcaster{}
+	svc := New(repo, broadcaster)
+
+	if _, err := svc.ResolvePoll(context.Background(), "prediction", "draw"); err != ErrInvalidWinningOption {
+		t.Fatalf("expected ErrInvalidWinningOption, got %v", err)
+	}
+
+	resolution, err := svc.ResolvePoll(context.Background(), "prediction", "win")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if resolution.Winners != 1 || resolution.Losers != 1 {
+		t.Errorf("expected one winner and one loser, got %+v", resolution)
+	}
+
+	if repo.points["optimist"] != 50 || repo.points["pessimist"] != 0 {
+		t.Errorf("expected only the winner to be paid out, got %v", repo.points)
+	}
+
+	if _, err := svc.ResolvePoll(context.Background(), "prediction", "lose"); err != ErrPollAlreadyResolved {
+		t.Fatalf("expected ErrPollAlreadyResolved, got %v", err)
+	}
+}
+
+func TestResolvePollResumesPayouts(t *testing.T) {
+	repo := newFakePointsRepo(repository.DatabasePoll{
+		ID:               "prediction",
+		Type:             repository.PollTypePrediction,
+		Status:           repository.PollStatusClosed,
+		PredictionPoints: 50,
+		Options:          []repository.DatabasePollOption{{ID: "win", Label: "Win"}, {ID: "lose", Label: "Lose"}},
+	})
+	repo.votes = []repository.NewPollVote{
+		{PollID: "prediction", UserID: "optimist", Answer: "win"},
+		{PollID: "prediction", UserID: "another-optimist", Answer: "win"},
+		{PollID: "prediction", UserID: "pessimist", Answer: "lose"},
+	}
+
+	svc := New(repo, &fakeBroadcaster{})
+
+	// paying out fails part way through the voters
+	repo.awardErr = errors.New("throttled")
+	if _, err := svc.ResolvePoll(context.Background(), "prediction", "win"); err == nil {
+		t.Fatalf("expected the resolution to fail whilst paying out")
+	}
+
+	if _, err := svc.ResolvePoll(context.Background(), "prediction", "lose"); err != ErrPollAlreadyResolved {
+		t.Fatalf("expected a retry with another winner to be rejected, got %v", err)
+	}
+
+	resolution, err := svc.ResolvePoll(context.Background(), "prediction", "win")
+	if err != nil {
+		t.Fatalf("expected retrying the resolution to finish paying out, got %v", err)
+	}
+
+	if resolution.Winners != 2 || resolution.Losers != 1 {
+		t.Errorf("expected two winners and one loser, got %+v", resolution)
+	}
+
+	if repo.points["optimist"] != 50 || repo.points["another-optimist"] != 50 || repo.points["pessimist"] != 0 {
+		t.Errorf("expected each winner to be paid out exactly once, got %v", repo.points)
+	}
+
+	if _, err := svc.ResolvePoll(context.Background(), "prediction", "win"); err != ErrPollAlreadyResolved {
+		t.Errorf("expected ErrPollAlreadyResolved once everyone was paid out, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+var ErrPollNotClosed = errors.New("poll must be closed")
+var ErrAnswerAlreadyRevealed = errors.New("quiz answer has already been revealed")
+
+type broadcastQuizAnswer struct {
+	ID                   string         `json:"id"`
+	CorrectOptions       []string       `json:"correctOptions"`
+	AggregatedVoteTotals map[string]int `json:"aggregatedVoteTotals"`
+}
+
+// RevealPollAnswer makes a quiz's correct answers public, broadcasts them to the channel and scores
+// the quiz for its session. Quizzes must be closed first so nobody can vote once they know the
+// answer. A reveal that failed before everyone was scored can be retried to finish scoring.
+func (s *service) RevealPollAnswer(ctx context.Context, pollID string) (Poll, error) {
+	poll, err := s.GetPoll(ctx, pollID)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	if poll.Type != PollTypeQuiz {
+		return Poll{}, ErrUnsupportedPollType
+	}
+
+	if poll.AnswerRevealed && poll.AwardedAt != nil {
+		return Poll{}, ErrAnswerAlreadyRevealed
+	}
+
+	if !poll.AnswerRevealed {
+		if poll.Status != PollStatusClosed {
+			return Poll{}, ErrPollNotClosed
+		}
+
+		revealed, err := s.repo.RevealPollAnswer(ctx, pollID)
+		if err != nil {
+			if err == repository.ErrPollNotFound {
+				return Poll{}, ErrRecordNotFound
+			}
+
+			if err == repository.ErrAnswerAlreadyRevealed {
+				return Poll{}, ErrAnswerAlreadyRevealed
+			}
+
+			return Poll{}, fmt.Errorf("revealing quiz answer: %w", err)
+		}
+
+		poll = mapDatabasePollToPoll(revealed)
+	}
+
+	metadata := broadcast.CreateTypedMetadata(broadcast.EnvelopeQuizAnswer, broadcastQuizAnswer{
+		ID:                   poll.ID,
+		CorrectOptions:       poll.CorrectOptionIDs(),
+		AggregatedVoteTotals: poll.AggregatedVoteTotals,
+	})
+
+	// viewers missing the answer is no reason to hold back their scores
+	if err := s.broadcastMetadata(ctx, poll.ChannelARN, metadata); err != nil {
+		log.Printf("error broadcasting quiz %s answer: %s", poll.ID, err)
+	}
+
+	if poll.SessionID != "" {
+		if err := s.scoreSessionQuiz(ctx, poll); err != nil {
+			return Poll{}, fmt.Errorf("scoring session quiz: %w", err)
+		}
+	}
+
+	if err := s.repo.CompletePollAwards(ctx, poll.ID, s.now()); err != nil {
+		return Poll{}, fmt.Errorf("completing quiz scoring: %w", err)
+	}
+
+	return poll, nil
+}
+
+// CorrectOptionIDs lists the IDs of a quiz's correct answers
+func (p Poll) CorrectOptionIDs() []string {
+	var ids []string
+	for _, o := range p.Options {
+		if o.Correct {
+			ids = append(ids, o.ID)
+		}
+	}
+
+	return ids
+}
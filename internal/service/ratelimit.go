@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/ratelimit"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+// RateLimitError is returned when a vote is turned away for being one too many, RetryAfter is how
+// long until the next vote would be let through
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many votes, retry after %s", e.RetryAfter)
+}
+
+// VoteRateLimits are the limits that apply to every poll. Either can be left unset to not limit
+// votes that way.
+type VoteRateLimits struct {
+	PerUser ratelimit.Limit
+	// PerIP limits votes from the same address, which catches scripts cycling through users
+	PerIP ratelimit.Limit
+}
+
+// WithVoteRateLimits limits how quickly votes can be made on a poll by each user and from each IP
+// address. Polls with their own rate limit use it in place of the per user limit.
+func WithVoteRateLimits(limiter ratelimit.Limiter, limits VoteRateLimits) Option {
+	return func(s *service) {
+		s.rateLimiter = limiter
+		s.voteRateLimits = limits
+	}
+}
+
+// checkVoteRateLimit takes a token from the voter's buckets for the poll, returning a
+// RateLimitError when either of them is empty
+func (s *service) checkVoteRateLimit(ctx context.Context, poll Poll, v NewPollVote) error {
+	if s.rateLimiter == nil {
+		return nil
+	}
+
+	userLimit := s.voteRateLimits.PerUser
+	if poll.VoteRateLimit != nil {
+		userLimit = *poll.VoteRateLimit
+	}
+
+	if userLimit.IsValid() {
+		if err := s.takeVoteToken(ctx, fmt.Sprintf("POLL#%s#USER#%s", poll.ID, v.UserID), userLimit); err != nil {
+			return err
+		}
+	}
+
+	if s.voteRateLimits.PerIP.IsValid() && v.SourceIP != "" {
+		if err := s.takeVoteToken(ctx, fmt.Sprintf("POLL#%s#IP#%s", poll.ID, v.SourceIP), s.voteRateLimits.PerIP); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *service) takeVoteToken(ctx context.Context, key string, limit ratelimit.Limit) error {
+	result, err := s.rateLimiter.Allow(ctx, key, limit)
+	if err != nil {
+		return fmt.Errorf("checking vote rate limit: %w", err)
+	}
+
+	if !result.Allowed {
+		return &RateLimitError{RetryAfter: result.RetryAfter}
+	}
+
+	return nil
+}
+
+func mapDatabaseRateLimit(dbLimit *repository.DatabaseRateLimit) *ratelimit.Limit {
+	if dbLimit == nil {
+		return nil
+	}
+
+	return &ratelimit.Limit{
+		Requests: dbLimit.Requests,
+		Per:      time.Duration(dbLimit.PerSeconds) * time.Second,
+	}
+}
+
+func mapRateLimitToDatabase(limit *ratelimit.Limit) *repository.DatabaseRateLimit {
+	if limit == nil {
+		return nil
+	}
+
+	return &repository.DatabaseRateLimit{
+		Requests:   limit.Requests,
+		PerSeconds: int(limit.Per.Seconds()),
+	}
+}
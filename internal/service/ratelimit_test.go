@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/ratelimit"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+func TestCreatePollVoteRateLimit(t *testing.T) {
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	repo := newFakeRepo(
+		repository.DatabasePoll{
+			ID:         "poll",
+			Status:     repository.PollStatusOpen,
+			VotePolicy: repository.VotePolicyUnlimited,
+			Options:    []repository.DatabasePollOption{{ID: "a", Label: "A"}},
+		},
+		repository.DatabasePoll{
+			ID:            "strict-poll",
+			Status:        repository.PollStatusOpen,
+			VotePolicy:    repository.VotePolicyUnlimited,
+			Options:       []repository.DatabasePollOption{{ID: "a", Label: "A"}},
+			VoteRateLimit: &repository.DatabaseRateLimit{Requests: 1, PerSeconds: 60},
+		},
+	)
+
+	svc := New(repo, &fakeBroadcaster{},
+		WithClock(fixedClock(now)),
+		WithVoteRateLimits(ratelimit.NewMemory(fixedClock(now)), VoteRateLimits{
+			PerUser: ratelimit.Limit{Requests: 2, Per: time.Minute},
+			PerIP:   ratelimit.Limit{Requests: 3, Per: time.Minute},
+		}),
+	)
+
+	vote := func(pollID string, userID string, ip string) error {
+		_, err := svc.CreatePollVote(context.Background(), NewPollVote{PollID: pollID, UserID: userID, SourceIP: ip, Answer: "a"})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := vote("poll", "user-1", "10.0.0.1"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	var rateLimitErr *RateLimitError
+	if err := vote("poll", "user-1", "10.0.0.2"); !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter != 30*time.Second {
+		t.Fatalf("expected the user to be rate limited for 30s, got %v", err)
+	}
+
+	if err := vote("poll", "user-2", "10.0.0.1"); err != nil {
+		t.Fatalf("expected another user on the same address to be allowed, got %s", err)
+	}
+
+	if err := vote("poll", "user-3", "10.0.0.1"); !errors.As(err, &rateLimitErr) {
+		t.Errorf("expected the address to be rate limited, got %v", err)
+	}
+
+	if err := vote("strict-poll", "user-1", "10.0.0.3"); err != nil {
+		t.Fatalf("expected the user to have a separate bucket on another poll, got %s", err)
+	}
+
+	if err := vote("strict-poll", "user-1", "10.0.0.3"); !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter != time.Minute {
+		t.Errorf("expected the poll's own limit to be used, got %v", err)
+	}
+
+	if len(repo.votes) != 4 {
+		t.Errorf("expected only the allowed votes to be stored, got %d", len(repo.votes))
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+)
+
+// GetPollResults counts the stored ballots of a ranked-choice poll round by round
+func (s *service) GetPollResults(ctx context.Context, pollID string) (RunoffResult, error) {
+	poll, err := s.GetPoll(ctx, pollID)
+	if err != nil {
+		return RunoffResult{}, err
+	}
+
+	if poll.Type != PollTypeRankedChoice {
+		return RunoffResult{}, ErrUnsupportedPollType
+	}
+
+	return s.tallyPoll(ctx, poll)
+}
+
+func (s *service) tallyPoll(ctx context.Context, poll Poll) (RunoffResult, error) {
+	votes, err := s.repo.ListPollVotes(ctx, poll.ID)
+	if err != nil {
+		return RunoffResult{}, fmt.Errorf("listing poll votes: %w", err)
+	}
+
+	var optionIDs []string
+	for _, o := range poll.Options {
+		optionIDs = append(optionIDs, o.ID)
+	}
+
+	var ballots [][]string
+	for _, v := range votes {
+		ballots = append(ballots, v.Ranking)
+	}
+
+	return tallyInstantRunoff(optionIDs, ballots), nil
+}
+
+type broadcastRunoff struct {
+	ID     string                 `json:"id"`
+	Rounds []broadcastRunoffRound `json:"rounds"`
+	Winner string                 `json:"winner,omitempty"`
+	Tied   []string               `json:"tied,omitempty"`
+}
+
+type broadcastRunoffRound struct {
+	Round      int            `json:"round"`
+	Totals     map[string]int `json:"totals"`
+	Exhausted  int            `json:"exhausted"`
+	Eliminated []string       `json:"eliminated,omitempty"`
+}
+
+// tallying a ranked-choice poll reads every ballot, so whilst votes are coming in the results are
+// broadcast at most this often rather than after every change
+const _runoffBroadcastInterval = 10 * time.Second
+
+// rebroadcastRunoff sends the instant-runoff results of an open ranked-choice poll to its channel
+// if they haven't been sent recently
+func (s *service) rebroadcastRunoff(ctx context.Context, pollID string) error {
+	now := s.now()
+
+	dbPoll, ok, err := s.repo.ClaimRunoffBroadcast(ctx, pollID, now, now.Add(-_runoffBroadcastInterval))
+	if err != nil {
+		return fmt.Errorf("claiming poll runoff broadcast: %w", err)
+	}
+
+	if !ok {
+		return nil
+	}
+
+	return s.broadcastRunoff(ctx, mapDatabasePollToPoll(dbPoll))
+}
+
+func (s *service) broadcastRunoff(ctx context.Context, poll Poll) error {
+	result, err := s.tallyPoll(ctx, poll)
+	if err != nil {
+		return err
+	}
+
+	var rounds []broadcastRunoffRound
+	for _, r := range result.Rounds {
+		rounds = append(rounds, broadcastRunoffRound{
+			Round:      r.Round,
+			Totals:     r.Totals,
+			Exhausted:  r.Exhausted,
+			Eliminated: r.Eliminated,
+		})
+	}
+
+	metadata := broadcast.CreateTypedMetadata(broadcast.EnvelopePollRunoff, broadcastRunoff{
+		ID:     poll.ID,
+		Rounds: rounds,
+		Winner: result.Winner,
+		Tied:   result.Tied,
+	})
+
+	return s.broadcastMetadata(ctx, poll.ChannelARN, metadata)
+}
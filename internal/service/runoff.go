@@ -0,0 +1,104 @@
+package service
+
+// RunoffRound is the state of an instant-runoff count after each ballot has been given to its
+// highest ranked option that is still in the running
+type RunoffRound struct {
+	Round int
+	// Totals only includes options that were still in the running at the start of the round
+	Totals map[string]int
+	// Exhausted counts ballots that have no remaining options left to count towards
+	Exhausted int
+	// Eliminated are the options knocked out at the end of the round
+	Eliminated []string
+}
+
+// RunoffResult holds every round of an instant-runoff count. Winner is empty when the final
+// options are tied or nobody has voted, in which case Tied lists the options left standing.
+type RunoffResult struct {
+	Rounds []RunoffRound
+	Winner string
+	Tied   []string
+}
+
+// tallyInstantRunoff counts ranked ballots in rounds, eliminating the option(s) with the fewest
+// votes each round until one option holds a majority of the ballots still in play. Options that
+// tie for the fewest votes are eliminated together unless that would knock out every option.
+func tallyInstantRunoff(optionIDs []string, ballots [][]string) RunoffResult {
+	active := make(map[string]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		active[id] = true
+	}
+
+	var result RunoffResult
+
+	for round := 1; len(active) > 0; round++ {
+		totals := make(map[string]int, len(active))
+		for id := range active {
+			totals[id] = 0
+		}
+
+		exhausted := 0
+		for _, ballot := range ballots {
+			counted := false
+			for _, choice := range ballot {
+				if active[choice] {
+					totals[choice]++
+					counted = true
+					break
+				}
+			}
+
+			if !counted {
+				exhausted++
+			}
+		}
+
+		current := RunoffRound{
+			Round:     round,
+			Totals:    totals,
+			Exhausted: exhausted,
+		}
+
+		continuing := len(ballots) - exhausted
+		for _, id := range optionIDs {
+			if active[id] && continuing > 0 && totals[id]*2 > continuing {
+				result.Rounds = append(result.Rounds, current)
+				result.Winner = id
+				return result
+			}
+		}
+
+		fewest := -1
+		for id := range active {
+			if fewest == -1 || totals[id] < fewest {
+				fewest = totals[id]
+			}
+		}
+
+		var lowest []string
+		for _, id := range optionIDs {
+			if active[id] && totals[id] == fewest {
+				lowest = append(lowest, id)
+			}
+		}
+
+		if len(lowest) == len(active) {
+			result.Rounds = append(result.Rounds, current)
+			if len(lowest) == 1 {
+				result.Winner = lowest[0]
+			} else {
+				result.Tied = lowest
+			}
+			return result
+		}
+
+		for _, id := range lowest {
+			delete(active, id)
+		}
+
+		current.Eliminated = lowest
+		result.Rounds = append(result.Rounds, current)
+	}
+
+	return result
+}
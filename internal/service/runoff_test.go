@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+func (r *fakeRepo) ClaimRunoffBroadcast(ctx context.Context, pollID string, now time.Time, since time.Time) (repository.DatabasePoll, bool, error) {
+	p := r.polls[pollID]
+	if p.Status != repository.PollStatusOpen {
+		return repository.DatabasePoll{}, false, nil
+	}
+
+	if last, err := repository.ParseTimestamp(p.RunoffBroadcastAt); err == nil && last.After(since) {
+		return repository.DatabasePoll{}, false, nil
+	}
+
+	p.RunoffBroadcastAt = now.Format(time.RFC3339)
+	r.polls[pollID] = p
+
+	return p, true, nil
+}
+
+func TestRebroadcastRunoffIsThrottled(t *testing.T) {
+	now := time.Date(2022, 7, 10, 12, 0, 0, 0, time.UTC)
+
+	repo := newFakeRepo(repository.DatabasePoll{
+		ID:         "ranked",
+		Type:       repository.PollTypeRankedChoice,
+		ChannelARN: "channel",
+		Status:     repository.PollStatusOpen,
+		Options:    []repository.DatabasePollOption{{ID: "a", Label: "A"}, {ID: "b", Label: "B"}},
+	})
+	repo.votes = []repository.NewPollVote{{PollID: "ranked", UserID: "user", Ranking: []string{"b", "a"}}}
+	broadcaster := &fakeBroadcaster{}
+
+	for _, offset := range []time.Duration{0, 5 * time.Second, 11 * time.Second} {
+		svc := New(repo, broadcaster, WithClock(fixedClock(now.Add(offset))))
+
+		if err := svc.rebroadcastRunoff(context.Background(), "ranked"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if messages := broadcaster.messages["channel"]; len(messages) != 2 {
+		t.Fatalf("expected the runoff to be broadcast twice, got %d broadcasts", len(messages))
+	}
+}
+
+func TestTallyInstantRunoff(t *testing.T) {
+	options := []string{"a", "b", "c", "d"}
+
+	t.Run("Majority in the first round", func(t *testing.T) {
+		result := tallyInstantRunoff(options, [][]string{
+			{"a", "b"},
+			{"a"},
+			{"b", "a"},
+		})
+
+		if result.Winner != "a" || len(result.Rounds) != 1 {
+			t.Fatalf("expected a to win in one round, got %+v", result)
+		}
+	})
+
+	t.Run("Eliminations transfer votes", func(t *testing.T) {
+		result := tallyInstantRunoff(options, [][]string{
+			{"a", "b"},
+			{"a", "c"},
+			{"b", "a"},
+			{"b", "c"},
+			{"c", "b"},
+		})
+
+		if result.Winner != "b" {
+			t.Fatalf("expected b to win, got %+v", result)
+		}
+
+		expectedRounds := []RunoffRound{
+			{Round: 1, Totals: map[string]int{"a": 2, "b": 2, "c": 1, "d": 0}, Eliminated: []string{"d"}},
+			{Round: 2, Totals: map[string]int{"a": 2, "b": 2, "c": 1}, Eliminated: []string{"c"}},
+			{Round: 3, Totals: map[string]int{"a": 2, "b": 3}},
+		}
+
+		if !reflect.DeepEqual(result.Rounds, expectedRounds) {
+			t.Fatalf("expected rounds %+v, got %+v", expectedRounds, result.Rounds)
+		}
+	})
+
+	t.Run("Exhausted ballots stop counting", func(t *testing.T) {
+		result := tallyInstantRunoff([]string{"a", "b", "c"}, [][]string{
+			{"a"},
+			{"a"},
+			{"b"},
+			{"b"},
+			{"c"},
+		})
+
+		if len(result.Tied) != 2 || result.Winner != "" {
+			t.Fatalf("expected a tie between a and b, got %+v", result)
+		}
+
+		if last := result.Rounds[len(result.Rounds)-1]; last.Exhausted != 1 {
+			t.Errorf("expected the ballot for c to be exhausted, got %d", last.Exhausted)
+		}
+	})
+
+	t.Run("No ballots", func(t *testing.T) {
+		result := tallyInstantRunoff(options, nil)
+
+		if result.Winner != "" || !reflect.DeepEqual(result.Tied, options) {
+			t.Fatalf("expected every option to be tied, got %+v", result)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+var ErrInvalidScale = errors.New("scale polls need scaleMin below scaleMax")
+var ErrInvalidScaleValue = errors.New("value is outside the poll's scale")
+
+// scales are capped so the histogram stays a reasonable size, e.g. 0-100 is the largest range
+const _maxScaleSteps = 100
+
+// ScaleStats keeps the running count and sum of a scale poll's votes
+type ScaleStats struct {
+	Count int
+	Sum   int
+}
+
+// ScaleSummary describes the spread of votes on a scale poll. Mean and Median are nil until
+// somebody has voted.
+type ScaleSummary struct {
+	Count  int      `json:"count"`
+	Mean   *float64 `json:"mean"`
+	Median *float64 `json:"median"`
+	// Distribution is how many votes each value has received, keyed by the value
+	Distribution map[string]int `json:"distribution"`
+}
+
+type broadcastScaleRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+func newBroadcastScaleRange(p Poll) *broadcastScaleRange {
+	if p.Type != PollTypeScale {
+		return nil
+	}
+
+	return &broadcastScaleRange{Min: p.ScaleMin, Max: p.ScaleMax}
+}
+
+// AcceptsValue checks whether a vote's value is within the poll's scale
+func (p Poll) AcceptsValue(value int) bool {
+	return value >= p.ScaleMin && value <= p.ScaleMax
+}
+
+// ScaleSummary works out the statistics for a scale poll from its histogram and running totals,
+// it returns nil for any other type of poll
+func (p Poll) ScaleSummary() *ScaleSummary {
+	if p.Type != PollTypeScale {
+		return nil
+	}
+
+	summary := &ScaleSummary{
+		Count:        p.ScaleStats.Count,
+		Distribution: make(map[string]int, p.ScaleMax-p.ScaleMin+1),
+	}
+
+	for v := p.ScaleMin; v <= p.ScaleMax; v++ {
+		key := repository.ScaleTotalsKey(v)
+		summary.Distribution[key] = p.AggregatedVoteTotals[key]
+	}
+
+	if p.ScaleStats.Count > 0 {
+		mean := float64(p.ScaleStats.Sum) / float64(p.ScaleStats.Count)
+		summary.Mean = &mean
+	}
+
+	summary.Median = p.scaleMedian()
+
+	return summary
+}
+
+// scaleMedian walks the histogram to find the middle vote, averaging the two middle votes when
+// there is an even number of them
+func (p Poll) scaleMedian() *float64 {
+	count := 0
+	for v := p.ScaleMin; v <= p.ScaleMax; v++ {
+		count += p.AggregatedVoteTotals[repository.ScaleTotalsKey(v)]
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	lowerIdx, upperIdx := (count-1)/2, count/2
+	lower, upper := 0, 0
+	seen := 0
+	for v := p.ScaleMin; v <= p.ScaleMax; v++ {
+		n := p.AggregatedVoteTotals[repository.ScaleTotalsKey(v)]
+		if seen <= lowerIdx && lowerIdx < seen+n {
+			lower = v
+		}
+
+		if seen <= upperIdx && upperIdx < seen+n {
+			upper = v
+			break
+		}
+
+		seen += n
+	}
+
+	median := float64(lower+upper) / 2
+
+	return &median
+}
+
+// incrementScaleTotals moves a scale poll's histogram along with its count and sum, then broadcasts
+// the new distribution
+func (s *service) incrementScaleTotals(ctx context.Context, dbPoll repository.DatabasePoll, valueIncrements repository.DatabasePollTotals, counted repository.CountedVoteChanges) error {
+	var stats repository.DatabaseScaleStats
+	for key, incr := range valueIncrements {
+		value, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("parsing scale value %s: %w", key, err)
+		}
+
+		stats.Count += incr
+		stats.Sum += value * incr
+	}
+
+	newTotals, newStats, err := s.repo.IncrementScalePollTotals(ctx, dbPoll.ID, valueIncrements, stats, counted)
+	if err != nil {
+		return fmt.Errorf("incrementing scale totals: %w", err)
+	}
+
+	poll := mapDatabasePollToPoll(dbPoll)
+	poll.AggregatedVoteTotals = newTotals
+	poll.ScaleStats = mapDatabaseScaleStats(&newStats)
+
+	metadata := broadcast.CreateMetadata(broadcastPoll{
+		ID:                   poll.ID,
+		AggregatedVoteTotals: newTotals,
+		Scale:                poll.ScaleSummary(),
+	})
+
+	return s.broadcastMetadata(ctx, poll.ChannelARN, metadata)
+}
+
+func mapDatabaseScaleStats(dbStats *repository.DatabaseScaleStats) ScaleStats {
+	if dbStats == nil {
+		return ScaleStats{}
+	}
+
+	return ScaleStats{
+		Count: dbStats.Count,
+		Sum:   dbStats.Sum,
+	}
+}
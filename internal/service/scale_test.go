@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestScaleSummary(t *testing.T) {
+	t.Run("Odd number of votes", func(t *testing.T) {
+		poll := Poll{
+			Type:                 PollTypeScale,
+			ScaleMin:             1,
+			ScaleMax:             5,
+			AggregatedVoteTotals: map[string]int{"1": 1, "2": 0, "3": 0, "4": 1, "5": 1},
+			ScaleStats:           ScaleStats{Count: 3, Sum: 10},
+		}
+
+		summary := poll.ScaleSummary()
+		if summary.Count != 3 || summary.Mean == nil || *summary.Mean != 10.0/3 {
+			t.Fatalf("expected 3 votes with a mean of 3.33, got %+v", summary)
+		}
+
+		if summary.Median == nil || *summary.Median != 4 {
+			t.Errorf("expected a median of 4, got %v", summary.Median)
+		}
+	})
+
+	t.Run("Even number of votes", func(t *testing.T) {
+		poll := Poll{
+			Type:                 PollTypeScale,
+			ScaleMin:             0,
+			ScaleMax:             10,
+			AggregatedVoteTotals: map[string]int{"2": 1, "3": 1, "8": 2},
+			ScaleStats:           ScaleStats{Count: 4, Sum: 21},
+		}
+
+		summary := poll.ScaleSummary()
+		if summary.Median == nil || *summary.Median != 5.5 {
+			t.Errorf("expected a median of 5.5, got %v", summary.Median)
+		}
+
+		if len(summary.Distribution) != 11 || summary.Distribution["0"] != 0 {
+			t.Errorf("expected every value on the scale in the distribution, got %v", summary.Distribution)
+		}
+	})
+
+	t.Run("No votes", func(t *testing.T) {
+		summary := Poll{Type: PollTypeScale, ScaleMin: 1, ScaleMax: 5}.ScaleSummary()
+		if summary.Mean != nil || summary.Median != nil {
+			t.Errorf("expected no mean or median without votes, got %+v", summary)
+		}
+	})
+
+	t.Run("Other poll types", func(t *testing.T) {
+		if summary := (Poll{Type: PollTypeSingleChoice}).ScaleSummary(); summary != nil {
+			t.Errorf("expected no summary, got %+v", summary)
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+var ErrSessionNotFound = errors.New("could not find session")
+var ErrInvalidCursor = errors.New("cursor is not valid")
+
+const (
+	_defaultPointsPerCorrectAnswer = 100
+	// quizzes without a deadline award the speed bonus over this window after opening
+	_defaultSpeedBonusWindow = 30 * time.Second
+	// how many of the top scores are broadcast each time the leaderboard is
+	_broadcastLeaderboardSize = 10
+)
+
+type Session struct {
+	ID                     string
+	Name                   string
+	ChannelARN             string
+	PointsPerCorrectAnswer int
+	SpeedBonus             int
+	StartedAt              *time.Time
+}
+
+type NewSession struct {
+	Name       string
+	ChannelARN string
+	// PointsPerCorrectAnswer defaults to 100 when not set
+	PointsPerCorrectAnswer int
+	// SpeedBonus is the most extra points an answer can earn, it shrinks the longer a viewer takes
+	SpeedBonus int
+}
+
+type SessionScore struct {
+	UserID         string `json:"userId"`
+	Score          int    `json:"score"`
+	CorrectAnswers int    `json:"correctAnswers"`
+}
+
+type Leaderboard struct {
+	SessionID string
+	Scores    []SessionScore
+	// Cursor fetches the next page of scores, it is empty on the last page
+	Cursor string
+}
+
+func (s *service) GetSession(ctx context.Context, sessionID string) (Session, error) {
+	session, err := s.repo.GetSession(ctx, sessionID)
+	if err != nil {
+		if err == repository.ErrSessionNotFound {
+			return Session{}, ErrRecordNotFound
+		}
+
+		return Session{}, fmt.Errorf("getting session: %w", err)
+	}
+
+	return mapDatabaseSessionToSession(session), nil
+}
+
+func (s *service) CreateSession(ctx context.Context, session NewSession) (Session, error) {
+	points := session.PointsPerCorrectAnswer
+	if points == 0 {
+		points = _defaultPointsPerCorrectAnswer
+	}
+
+	newSession, err := s.repo.CreateSession(ctx, repository.NewSession{
+		Name:                   session.Name,
+		ChannelARN:             session.ChannelARN,
+		PointsPerCorrectAnswer: points,
+		SpeedBonus:             session.SpeedBonus,
+		StartedAt:              s.now(),
+	})
+	if err != nil {
+		return Session{}, fmt.Errorf("creating new session: %w", err)
+	}
+
+	return mapDatabaseSessionToSession(newSession), nil
+}
+
+// GetSessionLeaderboard pages through a session's scores, highest first
+func (s *service) GetSessionLeaderboard(ctx context.Context, sessionID string, limit int, cursor string) (Leaderboard, error) {
+	if _, err := s.GetSession(ctx, sessionID); err != nil {
+		return Leaderboard{}, err
+	}
+
+	scores, next, err := s.repo.ListSessionLeaderboard(ctx, sessionID, limit, cursor)
+	if err != nil {
+		if err == repository.ErrInvalidCursor {
+			return Leaderboard{}, ErrInvalidCursor
+		}
+
+		return Leaderboard{}, fmt.Errorf("listing session leaderboard: %w", err)
+	}
+
+	leaderboard := Leaderboard{
+		SessionID: sessionID,
+		Scores:    []SessionScore{},
+		Cursor:    next,
+	}
+
+	for _, score := range scores {
+		leaderboard.Scores = append(leaderboard.Scores, SessionScore{
+			UserID:         score.UserID,
+			Score:          score.Score,
+			CorrectAnswers: score.CorrectAnswers,
+		})
+	}
+
+	return leaderboard, nil
+}
+
+type broadcastLeaderboard struct {
+	SessionID string         `json:"sessionId"`
+	Scores    []SessionScore `json:"scores"`
+}
+
+// scoreSessionQuiz awards points to everyone whose latest vote answered a revealed quiz correctly
+// and broadcasts the updated top of the leaderboard. Viewers who have already been scored for the
+// quiz are skipped so a failed scoring run can be retried.
+func (s *service) scoreSessionQuiz(ctx context.Context, poll Poll) error {
+	session, err := s.GetSession(ctx, poll.SessionID)
+	if err != nil {
+		return err
+	}
+
+	latest, err := s.latestVotePerUser(ctx, poll.ID)
+	if err != nil {
+		return err
+	}
+
+	for userID, vote := range latest {
+		if vote.Correct == nil || !*vote.Correct {
+			continue
+		}
+
+		points := session.PointsPerCorrectAnswer + speedBonus(session.SpeedBonus, poll, vote.VotedAt)
+		if err := s.repo.AddSessionScore(ctx, session.ID, poll.ID, userID, points, poll.ExpiresAt); err != nil && err != repository.ErrPointsAlreadyAwarded {
+			return fmt.Errorf("adding session score: %w", err)
+		}
+	}
+
+	// the scores are in, a leaderboard that fails to broadcast now goes out with the next one
+	if err := s.broadcastSessionLeaderboard(ctx, session.ID, session.ChannelARN); err != nil {
+		log.Printf("error broadcasting session %s leaderboard: %s", session.ID, err)
+	}
+
+	return nil
+}
+
+// broadcastSessionLeaderboard sends the top of a session's leaderboard to its channel
+func (s *service) broadcastSessionLeaderboard(ctx context.Context, sessionID string, channelARN string) error {
+	leaderboard, err := s.GetSessionLeaderboard(ctx, sessionID, _broadcastLeaderboardSize, "")
+	if err != nil {
+		return err
+	}
+
+	metadata := broadcast.CreateTypedMetadata(broadcast.EnvelopeLeaderboard, broadcastLeaderboard{
+		SessionID: sessionID,
+		Scores:    leaderboard.Scores,
+	})
+
+	return s.broadcastMetadata(ctx, channelARN, metadata)
+}
+
+// latestVotePerUser loads a poll's votes keeping only the most recent vote from each user, so
+// repeat votes on unlimited polls can't be counted more than once when they are scored
+func (s *service) latestVotePerUser(ctx context.Context, pollID string) (map[string]PollVote, error) {
+	votes, err := s.repo.ListPollVotes(ctx, pollID)
+	if err != nil {
+		return nil, fmt.Errorf("listing poll votes: %w", err)
+	}
+
+	latest := make(map[string]PollVote, len(votes))
+	for _, v := range votes {
+		vote := mapDatabasePollVoteToVote(v)
+
+		existing, ok := latest[vote.UserID]
+		if !ok || (vote.VotedAt != nil && existing.VotedAt != nil && vote.VotedAt.After(*existing.VotedAt)) {
+			latest[vote.UserID] = vote
+		}
+	}
+
+	return latest, nil
+}
+
+// speedBonus scales the maximum bonus down linearly from the moment the quiz opened until it closes,
+// so an instant answer earns the full bonus and one on the deadline earns nothing
+func speedBonus(maxBonus int, poll Poll, votedAt *time.Time) int {
+	if maxBonus == 0 || poll.OpenedAt == nil || votedAt == nil {
+		return 0
+	}
+
+	window := _defaultSpeedBonusWindow
+	if poll.ClosesAt != nil && poll.ClosesAt.After(*poll.OpenedAt) {
+		window = poll.ClosesAt.Sub(*poll.OpenedAt)
+	}
+
+	remaining := 1 - float64(votedAt.Sub(*poll.OpenedAt))/float64(window)
+	remaining = math.Max(0, math.Min(1, remaining))
+
+	return int(math.Round(float64(maxBonus) * remaining))
+}
+
+func mapDatabaseSessionToSession(dbSession repository.DatabaseSession) Session {
+	return Session{
+		ID:                     dbSession.ID,
+		Name:                   dbSession.Name,
+		ChannelARN:             dbSession.ChannelARN,
+		PointsPerCorrectAnswer: dbSession.PointsPerCorrectAnswer,
+		SpeedBonus:             dbSession.SpeedBonus,
+		StartedAt:              parseOptionalTimestamp(dbSession.StartedAt),
+	}
+}
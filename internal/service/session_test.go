@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+// fakeSessionRepo adds quiz sessions and the points awarded in them to fakeRepo
+type fakeSessionRepo struct {
+	*fakeRepo
+	sessions map[string]repository.DatabaseSession
+	scores   map[string]int
+	awards   map[string]bool
+	// awardErr fails the next points award
+	awardErr error
+}
+
+func newFakeSessionRepo(polls ...repository.DatabasePoll) *fakeSessionRepo {
+	return &fakeSessionRepo{
+		fakeRepo: newFakeRepo(polls...),
+		sessions: make(map[string]repository.DatabaseSession),
+		scores:   make(map[string]int),
+		awards:   make(map[string]bool),
+	}
+}
+
+func (r *fakeSessionRepo) GetSession(ctx context.Context, sessionID string) (repository.DatabaseSession, error) {
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return repository.DatabaseSession{}, repository.ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+func (r *fakeSessionRepo) ListSessionLeaderboard(ctx context.Context, sessionID string, limit int, cursor string) ([]repository.DatabaseSessionScore, string, error) {
+	var scores []repository.DatabaseSessionScore
+	for userID, score := range r.scores {
+		scores = append(scores, repository.DatabaseSessionScore{SessionID: sessionID, UserID: userID, Score: score})
+	}
+
+	return scores, "", nil
+}
+
+// award records a points award for a user on a poll, failing with awardErr if it is set
+func (r *fakeSessionRepo) award(pollID string, userID string) error {
+	if err := r.awardErr; err != nil {
+		r.awardErr = nil
+		return err
+	}
+
+	if r.awards[pollID+userID] {
+		return repository.ErrPointsAlreadyAwarded
+	}
+
+	r.awards[pollID+userID] = true
+
+	return nil
+}
+
+func (r *fakeSessionRepo) AddSessionScore(ctx context.Context, sessionID string, pollID string, userID string, points int, expiresAt *time.Time) error {
+	if err := r.award(pollID, userID); err != nil {
+		return err
+	}
+
+	r.scores[userID] += points
+
+	return nil
+}
+
+func (r *fakeSessionRepo) CompletePollAwards(ctx context.Context, pollID string, awardedAt time.Time) error {
+	p := r.polls[pollID]
+	p.AwardedAt = awardedAt.Format(time.RFC3339)
+	r.polls[pollID] = p
+
+	return nil
+}
+
+func (r *fakeSessionRepo) RevealPollAnswer(ctx context.Context, pollID string) (repository.DatabasePoll, error) {
+	p := r.polls[pollID]
+	if p.AnswerRevealed {
+		return repository.DatabasePoll{}, repository.ErrAnswerAlreadyRevealed
+	}
+
+	p.AnswerRevealed = true
+	r.polls[pollID] = p
+
+	return p, nil
+}
+
+func TestRevealPollAnswerResumesScoring(t *testing.T) {
+	correct, incorrect := true, false
+
+	repo := newFakeSessionRepo(repository.DatabasePoll{
+		ID:        "quiz",
+		Type:      repository.PollTypeQuiz,
+		SessionID: "session",
+		Status:    repository.PollStatusClosed,
+		Options:   []repository.DatabasePollOption{{ID: "a", Label: "A", Correct: true}, {ID: "b", Label: "B"}},
+	})
+	repo.sessions["session"] = repository.DatabaseSession{ID: "session", PointsPerCorrectAnswer: 100}
+	repo.votes = []repository.NewPollVote{
+		{PollID: "quiz", UserID: "first", Answer: "a", Correct: &correct},
+		{PollID: "quiz", UserID: "second", Answer: "a", Correct: &correct},
+		{PollID: "quiz", UserID: "third", Answer: "b", Correct: &incorrect},
+	}
+
+	// the answer can't be broadcast, which mustn't stop anyone being scored
+	svc := New(repo, failingBroadcaster{})
+
+	// scoring fails part way through the voters
+	repo.awardErr = errors.New("throttled")
+	if _, err := svc.RevealPollAnswer(context.Background(), "quiz"); err == nil {
+		t.Fatalf("expected the reveal to fail whilst scoring")
+	}
+
+	if _, err := svc.RevealPollAnswer(context.Background(), "quiz"); err != nil {
+		t.Fatalf("expected retrying the reveal to finish scoring, got %v", err)
+	}
+
+	if repo.scores["first"] != 100 || repo.scores["second"] != 100 || repo.scores["third"] != 0 {
+		t.Errorf("expected each correct answer to be scored exactly once, got %v", repo.scores)
+	}
+
+	if _, err := svc.RevealPollAnswer(context.Background(), "quiz"); err != ErrAnswerAlreadyRevealed {
+		t.Errorf("expected ErrAnswerAlreadyRevealed once everyone was scored, got %v", err)
+	}
+}
+
+func TestSpeedBonus(t *testing.T) {
+	openedAt := time.Date(2022, 7, 10, 12, 0, 0, 0, time.UTC)
+	closesAt := openedAt.Add(20 * time.Second)
+
+	tests := []struct {
+		name     string
+		poll     Poll
+		votedAt  time.Time
+		expected int
+	}{
+		{name: "Instant answer", poll: Poll{OpenedAt: &openedAt, ClosesAt: &closesAt}, votedAt: openedAt, expected: 50},
+		{name: "Halfway to the deadline", poll: Poll{OpenedAt: &openedAt, ClosesAt: &closesAt}, votedAt: openedAt.Add(10 * time.Second), expected: 25},
+		{name: "On the deadline", poll: Poll{OpenedAt: &openedAt, ClosesAt: &closesAt}, votedAt: closesAt, expected: 0},
+		{name: "Default window without a deadline", poll: Poll{OpenedAt: &openedAt}, votedAt: openedAt.Add(15 * time.Second), expected: 25},
+		{name: "Never opened", poll: Poll{}, votedAt: openedAt, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			votedAt := tt.votedAt
+			if bonus := speedBonus(50, tt.poll, &votedAt); bonus != tt.expected {
+				t.Errorf("expected a bonus of %d, got %d", tt.expected, bonus)
+			}
+		})
+	}
+}
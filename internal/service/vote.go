@@ -2,45 +2,271 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"log"
+	"time"
 
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
 	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
 )
 
 type PollVote struct {
-	ID     string
-	PollID string
-	UserID string
-	Answer string
+	ID      string
+	PollID  string
+	UserID  string
+	Answer  string
+	Answers []string
+	Ranking []string
+	// Correct is set for quiz votes
+	Correct *bool
+	Value   *int
+	Tier    string
+	Weight  int
+	VotedAt *time.Time
+	// Replaced is set when the vote changed the user's earlier vote
+	Replaced bool
+	// IPHash and Fingerprint are hashes of where the vote came from
+	IPHash      string
+	Fingerprint string
+	// FraudStatus is set once a vote has been quarantined, FraudSignals are why it was
+	FraudStatus  string
+	FraudSignals []string
 }
 
 type NewPollVote struct {
 	PollID string
 	UserID string
+	// Answer is the chosen option for a single choice poll
 	Answer string
+	// Answers are the chosen options for a multi-select poll
+	Answers []string
+	// Ranking orders the options of a ranked-choice poll, most preferred first
+	Ranking []string
+	// Value is the number chosen on a scale poll
+	Value *int
+	// Tier is the viewer's tier, it decides the weight of the vote on polls with weight rules
+	Tier string
+	// SourceIP is the address the vote came from, votes from the same address are rate limited
+	// together
+	SourceIP string
+	// UserAgent is the client the vote came from, lots of votes from one client look like stuffing
+	UserAgent string
+}
+
+// selections combines the single and multiple answer fields so votes can be validated the same
+// way regardless of which one the voter used. Ranked-choice polls only take the ranking so the
+// order of preference is never ambiguous.
+func (v NewPollVote) selections(pollType PollType) []string {
+	if pollType == PollTypeRankedChoice {
+		return v.Ranking
+	}
+
+	var selected []string
+	if v.Answer != "" {
+		selected = append(selected, v.Answer)
+	}
+
+	return append(selected, v.Answers...)
 }
 
 func (s *service) CreatePollVote(ctx context.Context, v NewPollVote) (PollVote, error) {
-	newVote, err := s.repo.CreatePollVote(ctx, repository.NewPollVote{
-		PollID: v.PollID,
-		UserID: v.UserID,
-		Answer: v.Answer,
-	})
+	poll, err := s.GetPoll(ctx, v.PollID)
+	if err != nil {
+		return PollVote{}, err
+	}
+
+	if !s.isAcceptingVotes(poll) {
+		return PollVote{}, ErrPollNotOpen
+	}
+
+	// invalid votes count towards the limit too so they can't be used to probe the poll for free
+	if err := s.checkVoteRateLimit(ctx, poll, v); err != nil {
+		return PollVote{}, err
+	}
+
+	selected := v.selections(poll.Type)
+	switch poll.Type {
+	case PollTypeScale:
+		if v.Value == nil || len(selected) > 0 || !poll.AcceptsValue(*v.Value) {
+			return PollVote{}, ErrInvalidScaleValue
+		}
+	case PollTypeFreeText:
+		if v.Value != nil || len(v.Answers) > 0 || len(v.Ranking) > 0 {
+			return PollVote{}, ErrInvalidAnswer
+		}
+
+		term, err := freeTextTerm(v.Answer)
+		if err != nil {
+			return PollVote{}, err
+		}
+
+		selected = []string{term}
+	default:
+		if v.Value != nil {
+			return PollVote{}, ErrInvalidAnswer
+		}
+
+		if len(selected) < poll.MinSelections || len(selected) > poll.MaxSelections {
+			return PollVote{}, ErrInvalidSelectionCount
+		}
+
+		seen := make(map[string]bool, len(selected))
+		for _, answer := range selected {
+			if !poll.HasOption(answer) || seen[answer] {
+				return PollVote{}, ErrInvalidAnswer
+			}
+			seen[answer] = true
+		}
+	}
+
+	newPollVote := repository.NewPollVote{
+		PollID:  v.PollID,
+		UserID:  v.UserID,
+		Policy:  string(poll.VotePolicy),
+		VotedAt: s.now(),
+		// votes are kept for as long as their poll
+		ExpiresAt:   poll.ExpiresAt,
+		IPHash:      hashVoteSource(poll.ID, v.SourceIP),
+		Fingerprint: hashVoteSource(poll.ID, v.UserAgent),
+		// the vote was validated against this version of the poll, which mustn't be edited before
+		// the vote is stored
+		FirstVote:   !poll.HasVotes,
+		PollVersion: poll.Version,
+	}
+
+	if len(poll.WeightRules) > 0 {
+		newPollVote.Tier = v.Tier
+		newPollVote.Weight = poll.WeightFor(v.Tier)
+	}
+
+	switch poll.Type {
+	case PollTypeMultiSelect:
+		newPollVote.Answers = selected
+	case PollTypeRankedChoice:
+		newPollVote.Ranking = selected
+	case PollTypeScale:
+		newPollVote.Value = v.Value
+	case PollTypeQuiz:
+		correct := poll.IsCorrect(selected[0])
+		newPollVote.Answer = selected[0]
+		newPollVote.Correct = &correct
+	default:
+		newPollVote.Answer = selected[0]
+	}
+
+	newVote, replaced, err := s.repo.CreatePollVote(ctx, newPollVote)
 	if err != nil {
+		if err == repository.ErrVoteAlreadyExists {
+			return PollVote{}, ErrAlreadyVoted
+		}
+
+		if err == repository.ErrPollVersionConflict {
+			return PollVote{}, ErrPollVersionConflict
+		}
+
+		if err == repository.ErrPollNotFound {
+			return PollVote{}, ErrRecordNotFound
+		}
+
 		return PollVote{}, fmt.Errorf("creating new poll vote: %w", err)
 	}
 
-	return mapDatabasePollVoteToVote(newVote), nil
+	vote := mapDatabasePollVoteToVote(newVote)
+	vote.Replaced = replaced
+
+	return vote, nil
+}
+
+// GetPollVote finds a user's current vote on a poll so clients can show what they chose
+func (s *service) GetPollVote(ctx context.Context, pollID string, userID string) (PollVote, error) {
+	if _, err := s.GetPoll(ctx, pollID); err != nil {
+		return PollVote{}, err
+	}
+
+	vote, err := s.repo.GetPollVote(ctx, pollID, userID)
+	if err != nil {
+		if err == repository.ErrVoteNotFound {
+			return PollVote{}, ErrVoteNotFound
+		}
+
+		return PollVote{}, fmt.Errorf("getting poll vote: %w", err)
+	}
+
+	return mapDatabasePollVoteToVote(vote), nil
+}
+
+// DeletePollVote retracts a user's vote whilst the poll is still accepting votes. The totals are
+// adjusted when the removal flows through the vote stream.
+func (s *service) DeletePollVote(ctx context.Context, pollID string, userID string) error {
+	poll, err := s.GetPoll(ctx, pollID)
+	if err != nil {
+		return err
+	}
+
+	if !s.isAcceptingVotes(poll) {
+		return ErrPollNotOpen
+	}
+
+	if poll.VotePolicy != VotePolicyChangeable {
+		return ErrVoteNotChangeable
+	}
+
+	if _, err := s.repo.DeletePollVote(ctx, pollID, userID); err != nil {
+		if err == repository.ErrVoteNotFound {
+			return ErrRecordNotFound
+		}
+
+		return fmt.Errorf("deleting poll vote: %w", err)
+	}
+
+	return nil
 }
 
 type broadcastPoll struct {
 	ID                   string         `json:"id"`
 	AggregatedVoteTotals map[string]int `json:"aggregatedVoteTotals"`
+	WeightedVoteTotals   map[string]int `json:"weightedVoteTotals,omitempty"`
+	Scale                *ScaleSummary  `json:"scale,omitempty"`
 }
 
-func (s *service) IncrementPollTotals(ctx context.Context, pollID string, answerIncrements map[string]int) error {
+// _countedVoteChangeRetention is how long counted changes to votes are remembered, the vote stream
+// keeps changes for a day so any change it redelivers is recognised
+const _countedVoteChangeRetention = 48 * time.Hour
+
+// CountedVoteChanges reports which of the changes to votes on a poll have already been counted
+func (s *service) CountedVoteChanges(ctx context.Context, pollID string, changeIDs []string) (map[string]bool, error) {
+	ids, err := s.repo.ListCountedVoteChanges(ctx, pollID, changeIDs)
+	if err != nil {
+		return nil, fmt.Errorf("listing counted vote changes: %w", err)
+	}
+
+	counted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		counted[id] = true
+	}
+
+	return counted, nil
+}
+
+// IncrementPollTotals moves a poll's totals by the change in votes, answerIncrements counts each
+// vote once and weightedIncrements counts each vote by its weight. changeIDs identify the changes
+// to votes behind the increments, they are recorded along with the new totals and the increment
+// fails if any of them have been counted before. Every so often the full poll is broadcast along
+// with the totals for viewers who missed it being opened.
+func (s *service) IncrementPollTotals(ctx context.Context, pollID string, changeIDs []string, answerIncrements map[string]int, weightedIncrements map[string]int) error {
+	counted := repository.CountedVoteChanges{
+		IDs:       changeIDs,
+		ExpiresAt: s.now().Add(_countedVoteChangeRetention),
+	}
+
+	if err := s.incrementPollTotals(ctx, pollID, answerIncrements, weightedIncrements, counted); err != nil {
+		return err
+	}
+
+	return s.rebroadcastPollDefinition(ctx, pollID)
+}
+
+func (s *service) incrementPollTotals(ctx context.Context, pollID string, answerIncrements map[string]int, weightedIncrements map[string]int, counted repository.CountedVoteChanges) error {
 	poll, err := s.repo.GetPoll(ctx, pollID)
 	if err != nil {
 		if err == repository.ErrPollNotFound {
@@ -50,7 +276,66 @@ func (s *service) IncrementPollTotals(ctx context.Context, pollID string, answer
 		return fmt.Errorf("getting poll: %w", err)
 	}
 
-	newTotals, err := s.repo.IncrementPollTotals(ctx, pollID, answerIncrements)
+	if poll.DeletingAt != "" {
+		// the votes are being removed along with the poll, they aren't retractions
+		return fmt.Errorf("poll is being deleted: %w", repository.ErrPollNotFound)
+	}
+
+	if PollType(poll.Type) == PollTypeFreeText {
+		// free-text answers are counted as terms rather than against a fixed set of totals
+		return s.incrementTermCounts(ctx, poll, answerIncrements, counted)
+	}
+
+	// votes are validated when they are submitted so this should never happen, but dropping unknown
+	// answers here means one bad vote cannot block the totals for everyone else
+	knownIncrements := make(repository.DatabasePollTotals, len(answerIncrements))
+	for answerID, incr := range answerIncrements {
+		if _, ok := poll.AggregatedVoteTotals[answerID]; !ok {
+			log.Printf("ignoring %d vote(s) for unknown answer %s on poll %s", incr, answerID, pollID)
+			continue
+		}
+
+		knownIncrements[answerID] = incr
+	}
+
+	if PollType(poll.Type) == PollTypeRankedChoice {
+		if len(knownIncrements) > 0 {
+			if _, err := s.repo.IncrementPollTotals(ctx, pollID, knownIncrements, counted); err != nil {
+				return fmt.Errorf("incrementing totals: %w", err)
+			}
+		} else if err := s.repo.RecordCountedVoteChanges(ctx, pollID, counted); err != nil {
+			return fmt.Errorf("recording counted vote changes: %w", err)
+		}
+
+		// first preferences alone don't say who is winning a ranked-choice poll and lower
+		// preferences can change without moving them
+		return s.rebroadcastRunoff(ctx, pollID)
+	}
+
+	if len(poll.WeightedVoteTotals) > 0 {
+		knownWeighted := make(repository.DatabasePollTotals, len(weightedIncrements))
+		for answerID, incr := range weightedIncrements {
+			if _, ok := poll.WeightedVoteTotals[answerID]; ok {
+				knownWeighted[answerID] = incr
+			}
+		}
+
+		return s.incrementWeightedTotals(ctx, poll, knownIncrements, knownWeighted, counted)
+	}
+
+	if len(knownIncrements) == 0 {
+		if err := s.repo.RecordCountedVoteChanges(ctx, pollID, counted); err != nil {
+			return fmt.Errorf("recording counted vote changes: %w", err)
+		}
+
+		return nil
+	}
+
+	if PollType(poll.Type) == PollTypeScale {
+		return s.incrementScaleTotals(ctx, poll, knownIncrements, counted)
+	}
+
+	newTotals, err := s.repo.IncrementPollTotals(ctx, pollID, knownIncrements, counted)
 	if err != nil {
 		return fmt.Errorf("incrementing totals: %w", err)
 	}
@@ -60,24 +345,35 @@ func (s *service) IncrementPollTotals(ctx context.Context, pollID string, answer
 		AggregatedVoteTotals: newTotals,
 	})
 
-	jsonMetadata, err := json.Marshal(metadata)
-	if err != nil {
-		return fmt.Errorf("error marhsalling broadcast metadata: %w", err)
-	}
+	return s.broadcastMetadata(ctx, poll.ChannelARN, metadata)
+}
 
-	s.broadcaster.Broadcast(ctx, poll.ChannelARN, string(jsonMetadata))
-	if err != nil {
-		return fmt.Errorf("error sending metadata to channel: %w", err)
+// isAcceptingVotes checks the poll is open, votes arriving after the deadline are rejected even
+// if the poll has not been closed yet, as are votes on a poll that is being deleted
+func (s *service) isAcceptingVotes(poll Poll) bool {
+	if poll.Status != PollStatusOpen || poll.DeletingAt != nil {
+		return false
 	}
 
-	return nil
+	return poll.ClosesAt == nil || s.now().Before(*poll.ClosesAt)
 }
 
 func mapDatabasePollVoteToVote(dbVote repository.DatabasePollVote) PollVote {
 	return PollVote{
-		ID:     dbVote.ID,
-		PollID: dbVote.PollID,
-		UserID: dbVote.UserID,
-		Answer: dbVote.Answer,
+		ID:           dbVote.ID,
+		PollID:       dbVote.PollID,
+		UserID:       dbVote.UserID,
+		Answer:       dbVote.Answer,
+		Answers:      dbVote.Answers,
+		Ranking:      dbVote.Ranking,
+		Correct:      dbVote.Correct,
+		Value:        dbVote.Value,
+		Tier:         dbVote.Tier,
+		Weight:       dbVote.Weight,
+		VotedAt:      parseOptionalTimestamp(dbVote.VotedAt),
+		IPHash:       dbVote.IPHash,
+		Fingerprint:  dbVote.Fingerprint,
+		FraudStatus:  dbVote.FraudStatus,
+		FraudSignals: dbVote.FraudSignals,
 	}
 }
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/broadcast"
+	"github.com/alexdunne/interactive-live-stream-poll-service/internal/repository"
+)
+
+var ErrInvalidWeightRules = errors.New("weight rules must be positive and are only supported by choice polls")
+
+// WeightFor is how many votes a vote from a viewer in the tier counts as, viewers without a
+// matching rule count once
+func (p Poll) WeightFor(tier string) int {
+	if weight, ok := p.WeightRules[tier]; ok && tier != "" {
+		return weight
+	}
+
+	return 1
+}
+
+// validateWeightRules only allows weights on polls whose totals are a simple count per option,
+// ranked-choice, scale and free-text polls are tallied in ways a weight can't be applied to
+func validateWeightRules(pollType PollType, rules map[string]int) error {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	switch pollType {
+	case PollTypeSingleChoice, PollTypeMultiSelect, PollTypeQuiz, PollTypePrediction:
+	default:
+		return ErrInvalidWeightRules
+	}
+
+	for tier, weight := range rules {
+		if tier == "" || weight < 1 {
+			return ErrInvalidWeightRules
+		}
+	}
+
+	return nil
+}
+
+// incrementWeightedTotals moves a weighted poll's raw counts and weighted totals together and
+// broadcasts both
+func (s *service) incrementWeightedTotals(ctx context.Context, poll repository.DatabasePoll, answerIncrements repository.DatabasePollTotals, weightedIncrements repository.DatabasePollTotals, counted repository.CountedVoteChanges) error {
+	if len(answerIncrements) == 0 && len(weightedIncrements) == 0 {
+		if err := s.repo.RecordCountedVoteChanges(ctx, poll.ID, counted); err != nil {
+			return fmt.Errorf("recording counted vote changes: %w", err)
+		}
+
+		return nil
+	}
+
+	newTotals, newWeightedTotals, err := s.repo.IncrementWeightedPollTotals(ctx, poll.ID, answerIncrements, weightedIncrements, counted)
+	if err != nil {
+		return fmt.Errorf("incrementing weighted totals: %w", err)
+	}
+
+	metadata := broadcast.CreateMetadata(broadcastPoll{
+		ID:                   poll.ID,
+		AggregatedVoteTotals: newTotals,
+		WeightedVoteTotals:   newWeightedTotals,
+	})
+
+	return s.broadcastMetadata(ctx, poll.ChannelARN, metadata)
+}